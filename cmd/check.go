@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelconfig"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+	"github.com/olekukonko/tablewriter/tw"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// NewCheckCmd creates the `driverkit check` command.
+func NewCheckCmd(configOpts *ConfigOptions, rootOpts *RootOptions, rootFlags *pflag.FlagSet) *cobra.Command {
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check which Falco driver can work on a kernel, given its config",
+		// it does not build anything, hence it validates the few options it needs by itself
+		Annotations: map[string]string{skipBuildValidation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			kr, err := kernelrelease.Parse(rootOpts.KernelRelease)
+			if err != nil {
+				return err
+			}
+			if _, ok := kernelrelease.SupportedArchs[kernelrelease.Architecture(rootOpts.Architecture)]; !ok {
+				return fmt.Errorf("unsupported architecture %q", rootOpts.Architecture)
+			}
+			kr.Architecture = kernelrelease.Architecture(rootOpts.Architecture)
+			if err := rootOpts.loadKernelConfigFile(); err != nil {
+				return err
+			}
+			if len(rootOpts.KernelConfigData) == 0 {
+				return errors.New("kernel config data or file is required")
+			}
+			cfg, err := kernelconfig.FromBase64(rootOpts.KernelConfigData)
+			if err != nil {
+				return err
+			}
+			if cfg.Empty() {
+				return errors.New("kernel config data does not contain any option")
+			}
+
+			results := kernelconfig.CheckAll(kr, cfg)
+
+			table := tablewriter.NewTable(os.Stdout,
+				tablewriter.WithRendition(tw.Rendition{
+					Symbols: tw.NewSymbols(tw.StyleMarkdown),
+					Borders: tw.Border{Left: tw.On, Right: tw.On, Top: tw.Off, Bottom: tw.Off}, // Markdown needs left/right borders
+				}),
+				tablewriter.WithHeaderAlignment(tw.AlignCenter), // Center align headers
+				tablewriter.WithRowAlignment(tw.AlignLeft),      // Common for Markdown
+				tablewriter.WithHeaderAutoWrap(tw.WrapNone),
+				tablewriter.WithRowAutoWrap(tw.WrapNone),
+				tablewriter.WithHeader([]string{"Driver", "Supported", "Reason"}),
+			)
+			for _, res := range results {
+				table.Append([]string{res.Driver.String(), fmt.Sprintf("%t", res.Supported()), strings.Join(res.Problems, ", ")})
+			}
+			table.Render() // Send output
+
+			driver, ok := kernelconfig.Recommend(results)
+			if !ok {
+				return fmt.Errorf("no Falco driver can work on kernel %s", kr.String())
+			}
+			configOpts.Printer.Logger.Info("recommended driver",
+				configOpts.Printer.Logger.Args("driver", driver.String(), "kernelrelease", kr.String()))
+			return nil
+		},
+	}
+	// Add root flags
+	checkCmd.PersistentFlags().AddFlagSet(rootFlags)
+
+	return checkCmd
+}
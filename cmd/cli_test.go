@@ -31,6 +31,7 @@ import (
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 
 	"github.com/acarl005/stripansi"
+	"github.com/spf13/viper"
 	"gotest.tools/assert"
 )
 
@@ -187,6 +188,18 @@ var tests = []testCase{
 			out: "testdata/docker-override-urls-from-config-debug.txt",
 		},
 	},
+	{
+		descr: "docker/invalid-gcc-rule-from-config-file",
+		args: []string{
+			"docker",
+			"-c",
+			"testdata/configs/3.yaml",
+		},
+		expect: expect{
+			out: "testdata/docker-invalid-gcc-rule-from-config.txt",
+			err: "exiting for validation errors",
+		},
+	},
 	{
 		descr: "docker/override-from-config-file",
 		env: map[string]string{
@@ -245,6 +258,24 @@ var tests = []testCase{
 			err: "exiting for validation errors",
 		},
 	},
+	{
+		descr: "docker/invalid-kernelrelease-validation",
+		args: []string{
+			"docker",
+			"--kernelrelease",
+			"foo",
+			"--target",
+			"ubuntu",
+			"--kernelversion",
+			"1",
+			"--output-module",
+			"/tmp/falco-ubuntu.ko",
+		},
+		expect: expect{
+			out: "testdata/docker-invalid-kernelrelease-validation-error.txt",
+			err: "exiting for validation errors",
+		},
+	},
 	{
 		descr: "complete/docker/targets",
 		args: []string{
@@ -353,6 +384,25 @@ func run(t *testing.T, test testCase) {
 	}
 }
 
+func TestImageMirrorsFromConfigFile(t *testing.T) {
+	// The config file is loaded into the global viper instance
+	t.Cleanup(viper.Reset)
+	configOpts, err := NewConfigOptions()
+	assert.NilError(t, err)
+	rootOpts, err := NewRootOptions()
+	assert.NilError(t, err)
+	var buf bytes.Buffer
+	configOpts.setOutput(&buf, true)
+	c := NewRootCmd(configOpts, rootOpts)
+	c.SetOutput(&buf)
+	c.SetArgs([]string{"docker", "-c", "testdata/configs/4.yaml", "--dryrun"})
+	assert.NilError(t, c.Execute())
+	assert.DeepEqual(t, rootOpts.ImageMirrors, []string{
+		"docker.io/=registry.internal/dockerhub/",
+		"ghcr.io/=registry.internal/ghcr/",
+	})
+}
+
 // Simple explanation:
 // basically we leverage text.template to execute templates for test data.
 // testTemplateData is the actual template structure used to fill the test requested output.
@@ -49,11 +49,15 @@ func NewDockerCmd(configOpts *ConfigOptions, rootOpts *RootOptions, rootFlags *p
 						configOpts.Printer.DefaultText.Print(buf.String())
 					}()
 				}
-				return driverbuilder.NewDockerBuildProcessor(configOpts.Timeout, configOpts.ProxyURL).Start(b)
+				return driverbuilder.NewDockerBuildProcessor(configOpts.Timeout, configOpts.ProxyURL, dockerOptions.EmulatorImage).Start(b)
 			}
 			return nil
 		},
 	}
+	// Add docker options flags
+	flags := dockerCmd.Flags()
+	addDockerFlags(flags)
+	dockerCmd.PersistentFlags().AddFlagSet(flags)
 	// Add root flags
 	dockerCmd.PersistentFlags().AddFlagSet(rootFlags)
 
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	flag "github.com/spf13/pflag"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder"
+)
+
+var dockerOptions = &DockerOptions{}
+
+type DockerOptions struct {
+	EmulatorImage string `validate:"omitempty" name:"emulator-image" default:"tonistiigi/binfmt"`
+}
+
+func addDockerFlags(flags *flag.FlagSet) {
+	flags.StringVar(&dockerOptions.EmulatorImage, "emulator-image", driverbuilder.DefaultEmulatorImage, "image registering the qemu binfmt handlers for cross-architecture builds, when not already registered on the host (e.g. tonistiigi/binfmt or multiarch/qemu-user-static)")
+}
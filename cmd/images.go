@@ -16,14 +16,25 @@ package cmd
 
 import (
 	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"runtime"
+	"strings"
 
+	"github.com/blang/semver/v4"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder"
 	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 	"github.com/olekukonko/tablewriter/tw"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
 )
 
 // NewImagesCmd creates the `driverkit images` command.
@@ -31,44 +42,32 @@ func NewImagesCmd(configOpts *ConfigOptions, rootOpts *RootOptions, rootFlags *p
 	imagesCmd := &cobra.Command{
 		Use:   "images",
 		Short: "List builder images",
+		// the build options are validated by loadImages, unless listing the images of all targets and architectures
+		Annotations: map[string]string{skipBuildValidation: "true"},
 		RunE: func(c *cobra.Command, args []string) error {
-			configOpts.Printer.Logger.Info("starting loading images",
-				configOpts.Printer.Logger.Args("processor", c.Name()))
-			// Since we use a spinner, cache log data to a bytesbuffer;
-			// we will later print it once we stop the spinner.
-			var (
-				buf bytes.Buffer
-				b   *builder.Build
-			)
-			if configOpts.disableStyling {
-				b = rootOpts.ToBuild(configOpts.Printer)
-			} else {
-				b = rootOpts.ToBuild(configOpts.Printer.WithWriter(&buf))
-				configOpts.Printer.Spinner, _ = configOpts.Printer.Spinner.Start("listing images, it will take a few seconds")
-			}
-			b.LoadImages()
-			if !configOpts.disableStyling {
-				_ = configOpts.Printer.Spinner.Stop()
-				configOpts.Printer.DefaultText.Print(buf.String())
-			}
-
-			table := tablewriter.NewTable(os.Stdout,
-				tablewriter.WithRendition(tw.Rendition{
-					Symbols: tw.NewSymbols(tw.StyleMarkdown),
-					Borders: tw.Border{Left: tw.On, Right: tw.On, Top: tw.Off, Bottom: tw.Off}, // Markdown needs left/right borders
-				}),
-				tablewriter.WithHeaderAlignment(tw.AlignCenter), // Center align headers
-				tablewriter.WithRowAlignment(tw.AlignLeft),      // Common for Markdown
-				tablewriter.WithHeaderAutoWrap(tw.WrapNone),
-				tablewriter.WithRowAutoWrap(tw.WrapNone),
-				tablewriter.WithHeader([]string{"Image", "Target", "Arch", "GCC"}),
-			)
-
-			for _, img := range b.Images {
+			_, images, err := loadImages(c, configOpts, rootOpts, imagesOptions.Output != "table")
+			if err != nil {
+				return err
+			}
+
+			switch imagesOptions.Output {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(toImagesOutput(images))
+			case "yaml":
+				enc := yaml.NewEncoder(os.Stdout)
+				enc.SetIndent(2)
+				return enc.Encode(toImagesOutput(images))
+			}
+
+			table := newImagesTable([]string{"Image", "Target", "Arch", "GCC"})
+
+			for _, img := range images {
 				data := make([]string, 4)
 				data[0] = img.Name
 				data[1] = img.Target.String()
-				data[2] = b.Architecture
+				data[2] = img.Arch
 				data[3] = img.GCCVersion.String()
 				table.Append(data)
 			}
@@ -78,6 +77,359 @@ func NewImagesCmd(configOpts *ConfigOptions, rootOpts *RootOptions, rootFlags *p
 	}
 	// Add root flags
 	imagesCmd.PersistentFlags().AddFlagSet(rootFlags)
+	addImagesFlags(imagesCmd.PersistentFlags())
 
+	imagesCmd.AddCommand(NewImagesExportCmd(configOpts, rootOpts))
+	imagesCmd.AddCommand(NewImagesRefreshCmd(configOpts, rootOpts))
+	imagesCmd.AddCommand(NewImagesBuildCmd(configOpts, rootOpts))
+	imagesCmd.AddCommand(NewImagesCheckCmd(configOpts, rootOpts))
 	return imagesCmd
 }
+
+// NewImagesExportCmd creates the `driverkit images export` command.
+func NewImagesExportCmd(configOpts *ConfigOptions, rootOpts *RootOptions) *cobra.Command {
+	var file string
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the builder images to a local images index, usable as builder repo",
+		// the build options are validated by loadImages, unless exporting the images of all targets and architectures
+		Annotations: map[string]string{skipBuildValidation: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			b, images, err := loadImages(c, configOpts, rootOpts, file == "")
+			if err != nil {
+				return err
+			}
+
+			out := os.Stdout
+			if file != "" {
+				if out, err = os.Create(file); err != nil {
+					return err
+				}
+				defer out.Close()
+			}
+			enc := yaml.NewEncoder(out)
+			enc.SetIndent(2)
+			return enc.Encode(b.ImagesIndex(images))
+		},
+	}
+	exportCmd.Flags().StringVarP(&file, "file", "f", "", "file to write the images index to (default to stdout)")
+	return exportCmd
+}
+
+// NewImagesRefreshCmd creates the `driverkit images refresh` command.
+func NewImagesRefreshCmd(configOpts *ConfigOptions, rootOpts *RootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Refresh the cached builder images listings of the builder repos",
+		// refreshing the cached listings does not need any build option
+		Annotations: map[string]string{skipBuildValidation: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			b := rootOpts.toBuild(configOpts.Printer, true)
+			if b.ImagesCache == nil {
+				return errors.New("no user cache directory available for the builder images listings")
+			}
+			return b.RefreshImagesCache()
+		},
+	}
+}
+
+// NewImagesBuildCmd creates the `driverkit images build` command.
+func NewImagesBuildCmd(configOpts *ConfigOptions, rootOpts *RootOptions) *cobra.Command {
+	opts := driverbuilder.ImagesBuildOptions{
+		Repo:         "docker.io/falcosecurity/driverkit-builder",
+		CMakeVersion: driverbuilder.DefaultCMakeVersion,
+	}
+	var (
+		dir         string
+		generateGCC string
+	)
+	buildCmd := &cobra.Command{
+		Use:   "build [dockerfile...]",
+		Short: "Build the builder images from their Dockerfiles, optionally pushing them",
+		Long: `Build the builder images from their Dockerfiles through the Docker API, tagging them as <repo>:<target>-<arch>_gcc<version>...-<tag>.
+The Dockerfiles can be passed as arguments; otherwise the ones under --dockerfiles matching the images filters are built,
+defaulting to the host architecture ones.`,
+		// building the images does not need any build option
+		Annotations: map[string]string{skipBuildValidation: "true"},
+		RunE: func(c *cobra.Command, args []string) error {
+			printer := configOpts.Printer
+			if errs := imagesOptions.Validate(); errs != nil {
+				for _, err := range errs {
+					printer.Logger.Error("error validating images options",
+						printer.Logger.Args("err", err.Error()))
+				}
+				return errors.New("exiting for validation errors")
+			}
+			b := rootOpts.toBuild(printer, true)
+			if opts.Tag == "" {
+				opts.Tag = b.BuilderImageTag()
+			}
+			arch := kernelrelease.Architecture(cmp.Or(imagesOptions.FilterArch, runtime.GOARCH))
+
+			dockerfiles, err := selectDockerfiles(dir, args, arch)
+			if err != nil {
+				return err
+			}
+			if generateGCC != "" {
+				gcc, err := semver.ParseTolerant(generateGCC)
+				if err != nil {
+					return fmt.Errorf("invalid gcc version %q: %w", generateGCC, err)
+				}
+				d, err := builder.GenerateGCCDockerfile(dir, gcc, arch)
+				if err != nil {
+					return err
+				}
+				printer.Logger.Info("generated builder Dockerfile",
+					printer.Logger.Args("dockerfile", d.Path))
+				dockerfiles = []builder.BuilderDockerfile{d}
+			}
+			if len(dockerfiles) == 0 {
+				return errors.New("no builder Dockerfile selected")
+			}
+
+			refs, err := driverbuilder.BuildBuilderImages(printer, b, dockerfiles, opts)
+			for _, ref := range refs {
+				printer.DefaultText.Println(ref)
+			}
+			return err
+		},
+	}
+	flags := buildCmd.Flags()
+	flags.StringVar(&dir, "dockerfiles", "docker/builders", "directory containing the builder images Dockerfiles")
+	flags.StringVar(&opts.Repo, "repo", opts.Repo, "repository to tag, and optionally push, the built images for")
+	flags.StringVar(&opts.Tag, "tag", "", "tag suffix of the built images (default to the builder image tag, e.g. latest)")
+	flags.BoolVar(&opts.Push, "push", false, "push the built images to the repository, authenticating with the registries credentials")
+	flags.StringVar(&opts.CMakeVersion, "cmake-version", opts.CMakeVersion, "cmake version installed in the built images")
+	flags.StringVar(&generateGCC, "generate-gcc", "", "generate, and build, a Dockerfile under --dockerfiles for an image providing the given gcc version (e.g. 13.2.0), based on the official gcc image")
+	return buildCmd
+}
+
+// NewImagesCheckCmd creates the `driverkit images check` command.
+func NewImagesCheckCmd(configOpts *ConfigOptions, rootOpts *RootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Check that the builder images provide the advertised compilers and the tools needed by the builders",
+		Long: `Start each listed builder image, verifying that it provides every advertised gcc version, cross-compilation gcc and clang version,
+and every tool run by the builder templates of the image target, or of every target for "any" target images, reporting the missing ones.`,
+		// the build options are validated by loadImages, unless checking the images of all targets and architectures
+		Annotations: map[string]string{skipBuildValidation: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			b, images, err := loadImages(c, configOpts, rootOpts, imagesOptions.Output != "table")
+			if err != nil {
+				return err
+			}
+			printer := configOpts.Printer
+			if imagesOptions.Output != "table" {
+				printer = printer.WithWriter(os.Stderr)
+			}
+			results, err := driverbuilder.CheckBuilderImages(printer, b, images)
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, res := range results {
+				if res.Failed() {
+					failed++
+				}
+			}
+			switch imagesOptions.Output {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(toImageChecksOutput(results))
+			case "yaml":
+				enc := yaml.NewEncoder(os.Stdout)
+				enc.SetIndent(2)
+				err = enc.Encode(toImageChecksOutput(results))
+			default:
+				table := newImagesTable([]string{"Image", "Arch", "GCC", "Clang", "Tools"})
+				for _, res := range results {
+					table.Append([]string{res.Image, res.Arch,
+						imageCheckCell(res, builder.ImageCheckGCC),
+						imageCheckCell(res, builder.ImageCheckClang),
+						imageCheckCell(res, builder.ImageCheckTool),
+					})
+				}
+				err = table.Render()
+			}
+			if err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d builder images failed the check", failed, len(results))
+			}
+			return nil
+		},
+	}
+}
+
+// imageCheckCell returns the checks table cell of the given kind: ok, or the missing binaries.
+func imageCheckCell(res driverbuilder.ImageCheckResult, kind string) string {
+	if res.Err != nil {
+		if kind == builder.ImageCheckGCC {
+			return "error: " + res.Err.Error()
+		}
+		return "-"
+	}
+	var missing []string
+	for _, check := range res.Missing {
+		if check.Kind == kind {
+			missing = append(missing, check.Binary)
+		}
+	}
+	if len(missing) == 0 {
+		return "ok"
+	}
+	return "missing " + strings.Join(missing, ", ")
+}
+
+type imageCheckOutput struct {
+	Image   string   `json:"image" yaml:"image"`
+	Arch    string   `json:"arch" yaml:"arch"`
+	Checked []string `json:"checked" yaml:"checked"`
+	Missing []string `json:"missing,omitempty" yaml:"missing,omitempty"`
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func toImageChecksOutput(results []driverbuilder.ImageCheckResult) []imageCheckOutput {
+	res := make([]imageCheckOutput, 0, len(results))
+	for _, r := range results {
+		out := imageCheckOutput{Image: r.Image, Arch: r.Arch}
+		for _, check := range r.Checks {
+			out.Checked = append(out.Checked, check.Binary)
+		}
+		for _, check := range r.Missing {
+			out.Missing = append(out.Missing, check.Binary)
+		}
+		if r.Err != nil {
+			out.Error = r.Err.Error()
+		}
+		res = append(res, out)
+	}
+	return res
+}
+
+// selectDockerfiles returns the builder Dockerfiles given as paths,
+// or the ones under dir running on arch and matching the images filters.
+func selectDockerfiles(dir string, paths []string, arch kernelrelease.Architecture) ([]builder.BuilderDockerfile, error) {
+	if len(paths) > 0 {
+		var res []builder.BuilderDockerfile
+		for _, path := range paths {
+			d, err := builder.LoadBuilderDockerfile(path)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, d)
+		}
+		return res, nil
+	}
+
+	all, err := builder.LoadBuilderDockerfiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []builder.BuilderDockerfile
+	for _, d := range all {
+		if d.Arch() == arch && len(imagesOptions.filter(d.Images)) > 0 {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+// loadImages loads the builder images, sorted and filtered according to imagesOptions.
+// Logs go to stderr when stdout is used for machine-readable output.
+func loadImages(c *cobra.Command, configOpts *ConfigOptions, rootOpts *RootOptions, logToStderr bool) (*builder.Build, []builder.Image, error) {
+	printer := configOpts.Printer
+	if logToStderr {
+		printer = printer.WithWriter(os.Stderr)
+	}
+	if errs := imagesOptions.Validate(); errs != nil {
+		for _, err := range errs {
+			printer.Logger.Error("error validating images options",
+				printer.Logger.Args("err", err.Error()))
+		}
+		return nil, nil, errors.New("exiting for validation errors")
+	}
+
+	if !imagesOptions.All {
+		if err := validateBuildOptions(printer, rootOpts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	printer.Logger.Info("starting loading images",
+		printer.Logger.Args("processor", c.Name()))
+	// Since we use a spinner, cache log data to a bytesbuffer;
+	// we will later print it once we stop the spinner.
+	var (
+		buf    bytes.Buffer
+		b      *builder.Build
+		images []builder.Image
+	)
+	if configOpts.disableStyling {
+		b = rootOpts.toBuild(printer, imagesOptions.All)
+	} else {
+		b = rootOpts.toBuild(printer.WithWriter(&buf), imagesOptions.All)
+		printer.Spinner, _ = printer.Spinner.Start("listing images, it will take a few seconds")
+	}
+	if imagesOptions.All {
+		images = b.ListImages()
+	} else {
+		b.LoadImages()
+		for _, img := range b.Images {
+			images = append(images, img)
+		}
+		builder.SortImages(images)
+	}
+	if !configOpts.disableStyling {
+		_ = printer.Spinner.Stop()
+		printer.DefaultText.Print(buf.String())
+	}
+	return b, imagesOptions.filter(images), nil
+}
+
+func newImagesTable(header []string) *tablewriter.Table {
+	return tablewriter.NewTable(os.Stdout,
+		tablewriter.WithRendition(tw.Rendition{
+			Symbols: tw.NewSymbols(tw.StyleMarkdown),
+			Borders: tw.Border{Left: tw.On, Right: tw.On, Top: tw.Off, Bottom: tw.Off}, // Markdown needs left/right borders
+		}),
+		tablewriter.WithHeaderAlignment(tw.AlignCenter), // Center align headers
+		tablewriter.WithRowAlignment(tw.AlignLeft),      // Common for Markdown
+		tablewriter.WithHeaderAutoWrap(tw.WrapNone),
+		tablewriter.WithRowAutoWrap(tw.WrapNone),
+		tablewriter.WithHeader(header),
+	)
+}
+
+type imageOutput struct {
+	Name          string   `json:"name" yaml:"name"`
+	Target        string   `json:"target" yaml:"target"`
+	Arch          string   `json:"arch" yaml:"arch"`
+	GCCVersion    string   `json:"gcc_version" yaml:"gcc_version"`
+	ClangVersions []string `json:"clang_versions,omitempty" yaml:"clang_versions,omitempty"`
+	CrossArchs    []string `json:"cross_archs,omitempty" yaml:"cross_archs,omitempty"`
+}
+
+func toImagesOutput(images []builder.Image) []imageOutput {
+	res := make([]imageOutput, 0, len(images))
+	for _, img := range images {
+		out := imageOutput{
+			Name:       img.Name,
+			Target:     img.Target.String(),
+			Arch:       img.Arch,
+			GCCVersion: img.GCCVersion.String(),
+			CrossArchs: img.CrossArchs,
+		}
+		for _, clang := range img.ClangVersions {
+			out.ClangVersions = append(out.ClangVersions, clang.String())
+		}
+		res = append(res, out)
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/go-playground/validator/v10"
+	flag "github.com/spf13/pflag"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+	"github.com/falcosecurity/driverkit/validate"
+)
+
+var imagesOptions = &ImagesOptions{}
+
+type ImagesOptions struct {
+	Output       string `validate:"oneof=table json yaml" name:"output" default:"table"`
+	All          bool
+	FilterTarget string
+	FilterArch   string `validate:"omitempty,architecture" name:"filter-arch"`
+	FilterGCC    string `validate:"omitempty,semvertolerant" name:"filter-gcc"`
+}
+
+func addImagesFlags(flags *flag.FlagSet) {
+	flags.StringVarP(&imagesOptions.Output, "output", "o", "table", "output format of the listed images, one of [table,json,yaml]")
+	flags.BoolVar(&imagesOptions.All, "all", false, "list the images for all targets and architectures, disregarding the build options")
+	flags.StringVar(&imagesOptions.FilterTarget, "filter-target", "", "only list the images for the given target, including the 'any' target ones")
+	flags.StringVar(&imagesOptions.FilterArch, "filter-arch", "", "only list the images running on the given architecture, one of "+kernelrelease.SupportedArchs.String())
+	flags.StringVar(&imagesOptions.FilterGCC, "filter-gcc", "", "only list the images providing the given gcc version (e.g. 12 or 12.2.0)")
+}
+
+// Validate validates the ImagesOptions fields.
+func (opts *ImagesOptions) Validate() []error {
+	if err := validate.V.Struct(opts); err != nil {
+		var errs validator.ValidationErrors
+		errors.As(err, &errs)
+		var errArr []error
+		for _, e := range errs {
+			// Translate each error one at a time
+			errArr = append(errArr, errors.New(e.Translate(validate.T)))
+		}
+		return errArr
+	}
+	return nil
+}
+
+// filter returns the images matching the filters, keeping their order.
+func (opts *ImagesOptions) filter(images []builder.Image) []builder.Image {
+	var (
+		gcc   semver.Version
+		parts int
+	)
+	if opts.FilterGCC != "" {
+		gcc, _ = semver.ParseTolerant(opts.FilterGCC)
+		parts = len(strings.Split(opts.FilterGCC, "."))
+	}
+
+	var res []builder.Image
+	for _, img := range images {
+		if opts.FilterTarget != "" && img.Target.String() != opts.FilterTarget && img.Target.String() != "any" {
+			continue
+		}
+		if opts.FilterArch != "" && img.Arch != kernelrelease.Architecture(opts.FilterArch).ToNonDeb() {
+			continue
+		}
+		// A partial gcc version, eg: 12, matches any 12.x.y one
+		if opts.FilterGCC != "" && (img.GCCVersion.Major != gcc.Major ||
+			(parts > 1 && img.GCCVersion.Minor != gcc.Minor) ||
+			(parts > 2 && img.GCCVersion.Patch != gcc.Patch)) {
+			continue
+		}
+		res = append(res, img)
+	}
+	return res
+}
@@ -107,6 +107,7 @@ func kubernetesRun(kubefactory factory.Factory,
 		kubernetesOptions.RunAsUser,
 		kubernetesOptions.Namespace,
 		kubernetesOptions.ImagePullSecret,
+		kubernetesOptions.CreatePullSecret,
 		configOpts.Timeout,
 		configOpts.ProxyURL)
 	return buildProcessor.Start(b)
@@ -88,6 +88,7 @@ func kubernetesInClusterRun(b *builder.Build, configOpts *ConfigOptions) error {
 		kubernetesOptions.RunAsUser,
 		kubernetesOptions.Namespace,
 		kubernetesOptions.ImagePullSecret,
+		kubernetesOptions.CreatePullSecret,
 		configOpts.Timeout,
 		configOpts.ProxyURL)
 	return buildProcessor.Start(b)
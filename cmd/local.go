@@ -58,10 +58,24 @@ func NewLocalCmd(configOpts *ConfigOptions, rootOpts *RootOptions, rootFlags *pf
 		"kernelurls":          {},
 		"builderrepo":         {},
 		"builderimage":        {},
+		"gcc-rule":            {},
 		"gccversion":          {},
-		"kernelconfigdata":    {},
+		"image-mirror":        {},
+		"image-policy":        {},
+		"image-target-only":   {},
+		"image-tie-break":     {},
+		"images-lock":         {},
+		"images-offline":      {},
+		"images-sig-key":      {},
+		"images-sig-policy":   {},
+		"images-ttl":          {},
+		"crossbuild":          {},
+		"compiler":            {},
 		"proxy":               {},
+		"registry-config":     {},
 		"registry-name":       {},
+		"registry-pass-stdin": {},
+		"registry-passfile":   {},
 		"registry-password":   {},
 		"registry-plain-http": {},
 		"registry-user":       {},
@@ -25,19 +25,38 @@ import (
 	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 	"github.com/falcosecurity/driverkit/pkg/version"
+	"github.com/falcosecurity/falcoctl/pkg/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
 	"github.com/spf13/viper"
 )
 
+// skipBuildValidation is the annotation marking the commands that do not build anything,
+// hence do not need the build options to be validated, or validate the few options they need by themselves.
+const skipBuildValidation = "skipBuildValidation"
+
+var errValidation = errors.New("exiting for validation errors")
+
+// validateBuildOptions validates the build options, logging them when valid, or the validation errors otherwise.
+func validateBuildOptions(printer *output.Printer, rootOpts *RootOptions) error {
+	if errs := rootOpts.Validate(); errs != nil {
+		for _, err := range errs {
+			printer.Logger.Error("error validating build options",
+				printer.Logger.Args("err", err.Error()))
+		}
+		return errValidation
+	}
+	rootOpts.Log(printer)
+	return nil
+}
+
 func persistentValidateFunc(rootCommand *RootCmd, configOpts *ConfigOptions, rootOpts *RootOptions) func(c *cobra.Command, args []string) error {
 	return func(c *cobra.Command, args []string) error {
-		var validationError = errors.New("exiting for validation errors")
 		configErr := configOpts.Init()
 		// Early exit if detect some error into config flags
 		if configErr {
-			return validationError
+			return errValidation
 		}
 		// Merge environment variables or config file values into the RootOptions instance
 		skip := map[string]bool{ // do not merge these
@@ -49,10 +68,32 @@ func persistentValidateFunc(rootCommand *RootCmd, configOpts *ConfigOptions, roo
 		}
 		nested := map[string]string{ // handle nested options in config file
 			"output-module": "output.module",
+			"output-probe":  "output.probe",
 		}
 		rootCommand.c.Flags().VisitAll(func(f *pflag.Flag) {
 			if name := f.Name; !skip[name] {
-				if name == "kernelurls" {
+				if name == "gcc-rule" {
+					// gcc rules can also be given as {target, kernel, gcc} objects in config file
+					if cliRules, err := rootCommand.c.Flags().GetStringSlice(name); err == nil && len(cliRules) != 0 {
+						return
+					}
+					var rules []string
+					var objRules []builder.GCCRule
+					if err := viper.UnmarshalKey(name, &objRules); err == nil {
+						for _, rule := range objRules {
+							rules = append(rules, rule.String())
+						}
+					} else {
+						rules = viper.GetStringSlice(name)
+					}
+					for _, rule := range rules {
+						if err := rootCommand.c.Flags().Set(name, rule); err != nil {
+							configOpts.Printer.Logger.Error("error setting gcc rule from config",
+								configOpts.Printer.Logger.Args("rule", rule, "err", err.Error()))
+							configErr = true
+						}
+					}
+				} else if name == "kernelurls" || name == "image-tie-break" || name == "image-mirror" {
 					// Slice types need special treatment when used as flags. If we call 'Set(name, value)',
 					// rather than replace, it appends. Since viper will already have the cli options set
 					// if supplied, we only need this step if rootCommand doesn't already have them e.g.
@@ -81,19 +122,17 @@ func persistentValidateFunc(rootCommand *RootCmd, configOpts *ConfigOptions, roo
 			}
 		})
 
+		if configErr {
+			return errValidation
+		}
+
 		// Avoid sensitive info into default values help line
 		rootCommand.StripSensitive()
 
-		// Do not block root or help command to exec disregarding the root flags validity
-		if c.Root() != c && c.Name() != "help" && c.Name() != "__complete" && c.Name() != "__completeNoDesc" && c.Name() != "completion" {
-			if errs := rootOpts.Validate(); errs != nil {
-				for _, err := range errs {
-					configOpts.Printer.Logger.Error("error validating build options",
-						configOpts.Printer.Logger.Args("err", err.Error()))
-				}
-				return validationError
-			}
-			rootOpts.Log(configOpts.Printer)
+		// Do not block root or help command to exec disregarding the root flags validity;
+		// neither the commands annotated to skip the build options validation.
+		if c.Root() != c && c.Name() != "help" && c.Name() != "__complete" && c.Name() != "__completeNoDesc" && c.Name() != "completion" && c.Annotations[skipBuildValidation] == "" {
+			return validateBuildOptions(configOpts.Printer, rootOpts)
 		}
 		return nil
 	}
@@ -146,7 +185,7 @@ func NewRootCmd(configOpts *ConfigOptions, rootOpts *RootOptions) *RootCmd {
 	// Flag annotations and custom completions
 	_ = rootCmd.MarkFlagFilename("config", viper.SupportedExts...)
 	_ = rootCmd.RegisterFlagCompletionFunc("target", func(c *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return targets, cobra.ShellCompDirectiveDefault
+		return append(targets, builder.TargetTypeAuto.String()), cobra.ShellCompDirectiveDefault
 	})
 	_ = rootCmd.RegisterFlagCompletionFunc("architecture", func(c *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return kernelrelease.SupportedArchs.Strings(), cobra.ShellCompDirectiveDefault
@@ -158,6 +197,7 @@ func NewRootCmd(configOpts *ConfigOptions, rootOpts *RootOptions) *RootCmd {
 	rootCmd.AddCommand(NewDockerCmd(configOpts, rootOpts, flags))
 	rootCmd.AddCommand(NewLocalCmd(configOpts, rootOpts, flags))
 	rootCmd.AddCommand(NewImagesCmd(configOpts, rootOpts, flags))
+	rootCmd.AddCommand(NewCheckCmd(configOpts, rootOpts, flags))
 	rootCmd.AddCommand(NewCompletionCmd(configOpts, rootOpts, flags))
 
 	ret.StripSensitive()
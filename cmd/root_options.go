@@ -15,28 +15,34 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/falcosecurity/falcoctl/pkg/output"
 	"github.com/spf13/pflag"
 
 	"github.com/creasty/defaults"
 	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/kernelconfig"
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 	"github.com/falcosecurity/driverkit/validate"
 	"github.com/go-playground/validator/v10"
 )
 
-// OutputOptions wraps the driver that driverkit builds.
+// OutputOptions wraps the two drivers that driverkit builds.
 type OutputOptions struct {
-	Module string `validate:"required,filepath,omitempty,endswith=.ko" name:"output module path"`
+	Module string `validate:"required_without=Probe,filepath,omitempty,endswith=.ko" name:"output module path"`
+	Probe  string `validate:"required_without=Module,filepath,omitempty,endswith=.o" name:"output probe path"`
 }
 
 func (oo *OutputOptions) HasOutputs() bool {
-	return oo.Module != ""
+	return oo.Module != "" || oo.Probe != ""
 }
 
 type RepoOptions struct {
@@ -44,11 +50,26 @@ type RepoOptions struct {
 	Name string `default:"libs" name:"repo name"`
 }
 
+type ImagesCacheOptions struct {
+	TTL     time.Duration `default:"1h" validate:"gte=0" name:"images cache ttl"`
+	Offline bool          `default:"false" name:"images offline"`
+}
+
+type ImagesSignatureOptions struct {
+	Key    string `validate:"omitempty,file" name:"images signature key"`
+	Policy string `default:"enforce" validate:"oneof=warn enforce" name:"images signature policy"`
+}
+
 type Registry struct {
 	Name      string `validate:"required_with=Username Password" name:"registry name"`
 	Username  string `validate:"required_with=Registry Password" name:"registry username"`
 	Password  string `validate:"required_with=Username Registry" name:"registry password"`
 	PlainHTTP bool   `default:"false" name:"registry plain http"`
+	// Config is the docker config.json holding the credentials of any registry;
+	// PasswordFile and PasswordStdin are alternatives to Password, see loadRegistryPassword.
+	Config        string `validate:"omitempty,file" name:"registry config"`
+	PasswordFile  string `validate:"omitempty,file" name:"registry password file"`
+	PasswordStdin bool   `default:"false" name:"registry password stdin"`
 }
 
 // RootOptions ...
@@ -58,16 +79,27 @@ type RootOptions struct {
 	KernelVersion    string   `default:"1" validate:"omitempty" name:"kernel version"`
 	ModuleDriverName string   `default:"falco" validate:"max=60" name:"kernel module driver name"`
 	ModuleDeviceName string   `default:"falco" validate:"excludes=/,max=255" name:"kernel module device name"`
-	KernelRelease    string   `validate:"required,ascii" name:"kernel release"`
+	KernelRelease    string   `validate:"required,ascii,kernelrelease" name:"kernel release"`
 	Target           string   `validate:"required,target" name:"target"`
 	KernelConfigData string   `validate:"omitempty,base64" name:"kernel config data"` // fixme > tag "name" does not seem to work when used at struct level, but works when used at inner level
+	KernelConfigFile string   `validate:"omitempty,file,excluded_with=KernelConfigData" name:"kernel config file"`
 	BuilderImage     string   `validate:"omitempty,imagename" name:"builder image"`
 	BuilderRepos     []string `default:"[\"docker.io/falcosecurity/driverkit-builder\"]" validate:"omitempty" name:"docker repositories to look for builder images or absolute path pointing to a yaml file containing builder images index"`
 	GCCVersion       string   `validate:"omitempty,semvertolerant" name:"gcc version"`
+	CrossBuild       string   `default:"auto" validate:"oneof=auto cross-compile qemu" name:"cross build mode"`
+	Compiler         string   `default:"auto" validate:"oneof=auto gcc clang" name:"compiler"`
 	KernelUrls       []string `name:"kernel header urls"`
 	Repo             RepoOptions
 	Output           OutputOptions
 	Registry         Registry
+	ImagesCache      ImagesCacheOptions
+	ImagesLock       string `name:"images lock file"`
+	ImagesSignature  ImagesSignatureOptions
+	ImageMirrors     []string `validate:"dive,imagemirror" name:"image mirror"`
+	ImagePolicy      string   `default:"nearest-lower" validate:"oneof=nearest-lower nearest exact newest" name:"image policy"`
+	ImageTieBreaks   []string `validate:"dive,oneof=higher" name:"image tie break"`
+	ImageTargetOnly  bool
+	GCCRules         []string `validate:"dive,gccrule" name:"gcc rule"`
 }
 
 func init() {
@@ -85,6 +117,20 @@ func NewRootOptions() (*RootOptions, error) {
 
 // Validate validates the RootOptions fields.
 func (ro *RootOptions) Validate() []error {
+	// read the registry password before validating, since it is required with the username.
+	if err := ro.loadRegistryPassword(); err != nil {
+		return []error{err}
+	}
+
+	// resolve the auto target before validating, so that the real one is checked.
+	if ro.Target == builder.TargetTypeAuto.String() && ro.KernelRelease != "" {
+		target, err := builder.DetectTarget(ro.KernelRelease)
+		if err != nil {
+			return []error{err}
+		}
+		ro.Target = target.String()
+	}
+
 	if err := validate.V.Struct(ro); err != nil {
 		var errs validator.ValidationErrors
 		errors.As(err, &errs)
@@ -96,29 +142,83 @@ func (ro *RootOptions) Validate() []error {
 		return errArr
 	}
 
-	// check that the kernel versions supports the module.
-	kr := kernelrelease.FromString(ro.KernelRelease)
+	if err := ro.loadKernelConfigFile(); err != nil {
+		return []error{err}
+	}
+
+	// check that the kernel versions supports at least one of probe and module.
+	kr, err := kernelrelease.Parse(ro.KernelRelease)
+	if err != nil {
+		return []error{err}
+	}
 	kr.Architecture = kernelrelease.Architecture(ro.Architecture)
-	if !kr.SupportsModule() {
+	if ro.Output.Module != "" && !kr.SupportsModule() {
 		return []error{errors.New("module is not supported by given options")}
 	}
+	if ro.Output.Probe != "" && !kr.SupportsProbe() {
+		return []error{errors.New("probe is not supported by given options")}
+	}
+
+	return nil
+}
 
+// loadKernelConfigFile fills KernelConfigData with the kernel config found in KernelConfigFile, if any.
+func (ro *RootOptions) loadKernelConfigFile() error {
+	if len(ro.KernelConfigFile) == 0 {
+		return nil
+	}
+	data, err := kernelconfig.ReadFile(ro.KernelConfigFile)
+	if err != nil {
+		return err
+	}
+	ro.KernelConfigData = base64.StdEncoding.EncodeToString(data)
+	return nil
+}
+
+// loadRegistryPassword fills the registry Password reading it from PasswordFile or stdin, if requested.
+// It only reads it once, so that it can be called multiple times.
+func (ro *RootOptions) loadRegistryPassword() error {
+	if ro.Registry.PasswordFile == "" && !ro.Registry.PasswordStdin {
+		return nil
+	}
+	if ro.Registry.Password != "" || (ro.Registry.PasswordFile != "" && ro.Registry.PasswordStdin) {
+		return errors.New("only one of registry password, password file and password stdin can be used")
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if ro.Registry.PasswordStdin {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(ro.Registry.PasswordFile)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read the registry password: %w", err)
+	}
+	ro.Registry.Password = strings.TrimRight(string(data), "\r\n")
+	ro.Registry.PasswordFile = ""
+	ro.Registry.PasswordStdin = false
 	return nil
 }
 
 func (ro *RootOptions) AddFlags(flags *pflag.FlagSet, targets []string) {
 	flags.StringVar(&ro.Output.Module, "output-module", ro.Output.Module, "filepath where to save the resulting kernel module")
+	flags.StringVar(&ro.Output.Probe, "output-probe", ro.Output.Probe, "filepath where to save the resulting eBPF probe")
 	flags.StringVar(&ro.Architecture, "architecture", runtime.GOARCH, "target architecture for the built driver, one of "+kernelrelease.SupportedArchs.String())
 	flags.StringVar(&ro.DriverVersion, "driverversion", ro.DriverVersion, "driver version as a git commit hash or as a git tag")
-	flags.StringVar(&ro.KernelVersion, "kernelversion", ro.KernelVersion, "kernel version to build the module for, it's the numeric value after the hash when you execute 'uname -v'")
+	flags.StringVar(&ro.KernelVersion, "kernelversion", ro.KernelVersion, "kernel version to build the module for, it's the numeric value after the hash when you execute 'uname -v'. The full 'uname -v' output is accepted too, to pick the exact distro package (eg: debian and ubuntu)")
 	flags.StringVar(&ro.KernelRelease, "kernelrelease", ro.KernelRelease, "kernel release to build the module for, it can be found by executing 'uname -v'")
-	flags.StringVarP(&ro.Target, "target", "t", ro.Target, "the system to target the build for, one of ["+strings.Join(targets, ",")+"]")
+	flags.StringVarP(&ro.Target, "target", "t", ro.Target, "the system to target the build for, one of ["+strings.Join(targets, ",")+"] or '"+builder.TargetTypeAuto.String()+"' to detect it from the kernel release")
 	flags.StringVar(&ro.KernelConfigData, "kernelconfigdata", ro.KernelConfigData, "base64 encoded kernel config data: in some systems it can be found under the /boot directory, in other it is gzip compressed under /proc")
+	flags.StringVar(&ro.KernelConfigFile, "kernelconfig-file", ro.KernelConfigFile, "path to the kernel config, alternative to kernelconfigdata: either a plain or gzip compressed config (eg: a copy of /proc/config.gz), or a vmlinux/vmlinuz kernel image built with CONFIG_IKCONFIG")
 	flags.StringVar(&ro.ModuleDeviceName, "moduledevicename", ro.ModuleDeviceName, "kernel module device name (the default is falco, so the device will be under /dev/falco*)")
 	flags.StringVar(&ro.ModuleDriverName, "moduledrivername", ro.ModuleDriverName, "kernel module driver name, i.e. the name you see when you check installed modules via lsmod")
 	flags.StringVar(&ro.BuilderImage, "builderimage", ro.BuilderImage, "docker image to be used to build the kernel module. If not provided, an automatically selected image will be used.")
-	flags.StringSliceVar(&ro.BuilderRepos, "builderrepo", ro.BuilderRepos, "list of docker repositories or yaml file (absolute path) containing builder images index with the format 'images: [ { target:<target>, name:<image-name>, arch: <arch>, tag: <imagetag>, gcc_versions: [ <gcc-tag> ] },...]', in descending priority order. Used to search for builder images. eg: --builderrepo myorg/driverkit-builder --builderrepo falcosecurity/driverkit-builder --builderrepo '/path/to/my/index.yaml'.")
+	flags.StringSliceVar(&ro.BuilderRepos, "builderrepo", ro.BuilderRepos, "list of docker repositories or yaml file (absolute path or file:// URL) containing builder images index with the format 'images: [ { target:<target>, name:<image-name>, arch: <arch>, tag: <imagetag>, gcc_versions: [ <gcc-tag> ] },...]', in descending priority order. Used to search for builder images. The index can also be fetched from an http(s):// URL, optionally pinning its checksum with a '#sha256=<hex>' fragment, while docker://[<repository>] lists the images in the local docker daemon. eg: --builderrepo myorg/driverkit-builder --builderrepo falcosecurity/driverkit-builder --builderrepo '/path/to/my/index.yaml' --builderrepo 'https://example.com/index.yaml'.")
 	flags.StringVar(&ro.GCCVersion, "gccversion", ro.GCCVersion, "enforce a specific gcc version for the build")
+	flags.StringVar(&ro.Compiler, "compiler", ro.Compiler, "compiler family for the kernel module build: 'gcc', 'clang' (LLVM=1) or 'auto' to use the one the kernel was built with, as stated by the kernel config or headers")
+	flags.StringVar(&ro.CrossBuild, "crossbuild", ro.CrossBuild, "how to build for an architecture other than the host one: 'cross-compile' with a cross toolchain, 'qemu' emulating the target architecture, or 'auto' to cross-compile when a suitable builder image exists")
 
 	flags.StringSliceVar(&ro.KernelUrls, "kernelurls", nil, "list of kernel header urls (e.g. --kernelurls <URL1> --kernelurls <URL2> --kernelurls \"<URL3>,<URL4>\")")
 
@@ -129,6 +229,20 @@ func (ro *RootOptions) AddFlags(flags *pflag.FlagSet, targets []string) {
 	flags.StringVar(&ro.Registry.Username, "registry-user", ro.Registry.Username, "registry username")
 	flags.StringVar(&ro.Registry.Password, "registry-password", ro.Registry.Password, "registry password")
 	flags.BoolVar(&ro.Registry.PlainHTTP, "registry-plain-http", ro.Registry.PlainHTTP, "allows interacting with remote registry via plain http requests")
+	flags.StringVar(&ro.Registry.PasswordFile, "registry-passfile", ro.Registry.PasswordFile, "file holding the registry password, alternative to --registry-password")
+	flags.BoolVar(&ro.Registry.PasswordStdin, "registry-pass-stdin", ro.Registry.PasswordStdin, "read the registry password from stdin, alternative to --registry-password")
+	flags.StringVar(&ro.Registry.Config, "registry-config", ro.Registry.Config, "docker config.json holding the credentials of any registry, including credential helpers ones, used for the registries other than --registry-name (default $DOCKER_CONFIG/config.json or $HOME/.docker/config.json)")
+
+	flags.DurationVar(&ro.ImagesCache.TTL, "images-ttl", ro.ImagesCache.TTL, "how long the builder images listed from the builder repos are cached for, 0 to always list them again; the labels of their tags are only fetched again when the tags point to new manifests")
+	flags.BoolVar(&ro.ImagesCache.Offline, "images-offline", ro.ImagesCache.Offline, "do not list the builder images from the builder repos, using the cached listings regardless of their age")
+	flags.StringVar(&ro.ImagesLock, "images-lock", ro.ImagesLock, "file pinning the builder images to their manifest digests, for repeatable builds; the digests of the images not pinned yet are resolved and written to it")
+	flags.StringVar(&ro.ImagesSignature.Key, "images-sig-key", ro.ImagesSignature.Key, "PEM public key verifying the cosign signatures of the builder images before using them")
+	flags.StringVar(&ro.ImagesSignature.Policy, "images-sig-policy", ro.ImagesSignature.Policy, "what to do when a builder image signature cannot be verified: 'warn' and use it anyway, or 'enforce' refusing it")
+	flags.StringSliceVar(&ro.ImageMirrors, "image-mirror", ro.ImageMirrors, "rewrite the images references starting with a prefix, to pull them from a registry mirror, as <from>=<to>; applied to the builder repos, the builder images and the emulator image, eg: --image-mirror docker.io/=registry.internal/dockerhub/")
+	flags.StringVar(&ro.ImagePolicy, "image-policy", ro.ImagePolicy, "how the builder image gcc version is selected for the target one: 'nearest-lower' than or equal to it, otherwise the lowest one, 'nearest' either lower or higher, 'exact' only, or 'newest'")
+	flags.StringSliceVar(&ro.ImageTieBreaks, "image-tie-break", ro.ImageTieBreaks, "rules breaking the ties between the gcc versions equally suiting the image policy: 'higher' prefers the higher gcc over an equally near lower one")
+	flags.BoolVar(&ro.ImageTargetOnly, "image-target-only", ro.ImageTargetOnly, "only select the gcc version among the target-specific builder images, even when an 'any' image provides a nearer one; the 'any' images are still used for the targets without specific images")
+	flags.StringSliceVar(&ro.GCCRules, "gcc-rule", ro.GCCRules, "gcc version to target for the kernels of a target in a semver range, as [<target>:]<kernel range>=<gcc>, taking precedence over the kernel config data and the builders built-in ones; the first matching rule wins, eg: --gcc-rule 'debian:>=6.1 <6.2=12'")
 }
 
 // Log emits a log line containing the receiving RootOptions for debugging purposes.
@@ -138,6 +252,7 @@ func (ro *RootOptions) Log(printer *output.Printer) {
 	printer.Logger.Debug("running with options",
 		printer.Logger.Args(
 			"output-module", ro.Output.Module,
+			"output-probe", ro.Output.Probe,
 			"driverversion", ro.DriverVersion,
 			"kernelrelease", ro.KernelRelease,
 			"kernelversion", ro.KernelVersion,
@@ -150,6 +265,11 @@ func (ro *RootOptions) Log(printer *output.Printer) {
 }
 
 func (ro *RootOptions) ToBuild(printer *output.Printer) *builder.Build {
+	return ro.toBuild(printer, false)
+}
+
+// toBuild builds the Build, with images listers loading the images of any target and architecture when allImages is set.
+func (ro *RootOptions) toBuild(printer *output.Printer, allImages bool) *builder.Build {
 	kernelConfigData := ro.KernelConfigData
 	if len(kernelConfigData) == 0 {
 		kernelConfigData = "bm8tZGF0YQ==" // no-data
@@ -161,8 +281,12 @@ func (ro *RootOptions) ToBuild(printer *output.Printer) *builder.Build {
 		KernelVersion:     ro.KernelVersion,
 		KernelRelease:     ro.KernelRelease,
 		Architecture:      ro.Architecture,
+		HostArchitecture:  runtime.GOARCH,
+		CrossBuildMode:    builder.CrossBuildMode(ro.CrossBuild),
+		Compiler:          builder.Compiler(ro.Compiler),
 		KernelConfigData:  kernelConfigData,
 		ModuleFilePath:    ro.Output.Module,
+		ProbeFilePath:     ro.Output.Probe,
 		ModuleDriverName:  ro.ModuleDriverName,
 		ModuleDeviceName:  ro.ModuleDeviceName,
 		GCCVersion:        ro.GCCVersion,
@@ -176,20 +300,82 @@ func (ro *RootOptions) ToBuild(printer *output.Printer) *builder.Build {
 		RegistryUser:      ro.Registry.Username,
 		RegistryPassword:  ro.Registry.Password,
 		RegistryPlainHTTP: ro.Registry.PlainHTTP,
+		AllImages:         allImages,
+		ImagesLockFile:    ro.ImagesLock,
 		Printer:           printer,
 	}
 
+	build.RegistryConfig = ro.Registry.Config
+	if err := ro.loadRegistryPassword(); err != nil {
+		printer.Logger.Warn("skipping registry credentials",
+			printer.Logger.Args("err", err.Error()))
+	}
+	build.RegistryPassword = ro.Registry.Password
+
+	// rewrite the images references to the mirrors, before listing the builder repos
+	for _, rule := range ro.ImageMirrors {
+		mirror, err := builder.ParseImageMirror(rule)
+		if err != nil {
+			printer.Logger.Warn("skipping image mirror",
+				printer.Logger.Args("mirror", rule, "err", err.Error()))
+			continue
+		}
+		build.ImageMirrors = append(build.ImageMirrors, mirror)
+	}
+
+	// select the builder images gcc version according to the image policy
+	build.ImagePolicy = builder.ImagePolicy(ro.ImagePolicy)
+	for _, tieBreak := range ro.ImageTieBreaks {
+		build.ImageTieBreaks = append(build.ImageTieBreaks, builder.ImageTieBreak(tieBreak))
+	}
+	build.ImageTargetOnly = ro.ImageTargetOnly
+
+	// state the target gcc of some targets and kernels
+	for _, rule := range ro.GCCRules {
+		gccRule, err := builder.ParseGCCRule(rule)
+		if err != nil {
+			printer.Logger.Warn("skipping gcc rule",
+				printer.Logger.Args("rule", rule, "err", err.Error()))
+			continue
+		}
+		build.GCCRules = append(build.GCCRules, gccRule)
+	}
+
+	// verify the builder images signatures, when a public key is given
+	if ro.ImagesSignature.Key != "" {
+		build.ImageSignatureKey = ro.ImagesSignature.Key
+		build.ImageSignaturePolicy = builder.SignaturePolicy(ro.ImagesSignature.Policy)
+	}
+
+	// cache the builder repos listings, when a user cache directory is available
+	if dir := builder.DefaultImagesCacheDir(); dir != "" {
+		build.ImagesCache = &builder.ImagesCache{
+			Dir:     dir,
+			TTL:     ro.ImagesCache.TTL,
+			Offline: ro.ImagesCache.Offline,
+		}
+	}
+
 	// loop over BuilderRepos to build the list ImagesListers based on the value of the builderRepo:
-	// if it's a local path use FileImagesLister, otherwise use RepoImagesLister
+	// select the lister by URL scheme, otherwise if it's a local path use FileImagesLister, else use RepoImagesLister
 	var (
 		imageLister builder.ImagesLister
 		err         error
 	)
 	for _, builderRepo := range build.BuilderRepos {
-		if _, err = os.Stat(builderRepo); err == nil {
-			imageLister, err = builder.NewFileImagesLister(builderRepo, build)
-		} else {
-			imageLister, err = builder.NewRepoImagesLister(builderRepo, build)
+		switch {
+		case strings.HasPrefix(builderRepo, "http://") || strings.HasPrefix(builderRepo, "https://"):
+			imageLister, err = builder.NewHTTPImagesLister(builderRepo, build)
+		case strings.HasPrefix(builderRepo, "docker://"):
+			imageLister, err = builder.NewDaemonImagesLister(strings.TrimPrefix(builderRepo, "docker://"), build)
+		case strings.HasPrefix(builderRepo, "file://"):
+			imageLister, err = builder.NewFileImagesLister(strings.TrimPrefix(builderRepo, "file://"), build)
+		default:
+			if _, err = os.Stat(builderRepo); err == nil {
+				imageLister, err = builder.NewFileImagesLister(builderRepo, build)
+			} else {
+				imageLister, err = builder.NewRepoImagesLister(build.MirroredImage(builderRepo), build)
+			}
 		}
 		if err != nil {
 			printer.Logger.Warn("skipping repo",
@@ -199,9 +385,9 @@ func (ro *RootOptions) ToBuild(printer *output.Printer) *builder.Build {
 		}
 	}
 
-	// attempt the build in case it comes from an invalid config
-	kr := build.KernelReleaseFromBuildConfig()
-	if len(build.ModuleFilePath) > 0 && !kr.SupportsModule() {
+	// attempt the build in case it comes from an invalid config;
+	// an invalid kernel release is reported by the validation
+	if kr, err := build.KernelReleaseFromBuildConfig(); err == nil && len(build.ModuleFilePath) > 0 && !kr.SupportsModule() {
 		build.ModuleFilePath = ""
 		printer.Logger.Warn("skipping build attempt of module for unsupported kernel release",
 			printer.Logger.Args("kernelrelease", kr.String()))
@@ -211,14 +397,14 @@ func (ro *RootOptions) ToBuild(printer *output.Printer) *builder.Build {
 
 // RootOptionsLevelValidation validates KernelConfigData and Target at the same time.
 //
-// It reports an error when `KernelConfigData` is empty and `Target` is `vanilla`.
+// It reports an error when both `KernelConfigData` and `KernelConfigFile` are empty and `Target` is `vanilla`.
 func RootOptionsLevelValidation(level validator.StructLevel) {
 	opts := level.Current().Interface().(RootOptions)
 
 	if opts.Target == builder.TargetTypeVanilla.String() ||
 		opts.Target == builder.TargetTypeMinikube.String() ||
 		opts.Target == builder.TargetTypeFlatcar.String() {
-		if len(opts.KernelConfigData) == 0 {
+		if len(opts.KernelConfigData) == 0 && len(opts.KernelConfigFile) == 0 {
 			level.ReportError(opts.KernelConfigData, "kernelConfigData", "KernelConfigData", "required_kernelconfigdata_with_target_vanilla", "")
 		}
 	}
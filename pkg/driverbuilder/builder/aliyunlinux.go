@@ -17,6 +17,7 @@ package builder
 import (
 	_ "embed"
 	"fmt"
+	"regexp"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 )
@@ -54,7 +55,11 @@ func (c *alinux) TemplateScript() string {
 }
 
 func (c *alinux) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
-	return fetchAlinuxKernelURLS(kr), nil
+	return fetchRPMKernelURLs(c, kr)
+}
+
+func (c *alinux) rpmPackages(_ kernelrelease.KernelRelease) []string {
+	return []string{"kernel-devel"}
 }
 
 func (c *alinux) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -63,22 +68,26 @@ func (c *alinux) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string
 	}
 }
 
-func fetchAlinuxKernelURLS(kr kernelrelease.KernelRelease) []string {
+func (c *alinux) rpmRepos(kr kernelrelease.KernelRelease) []string {
 	alinuxReleases := []string{
 		"2",
 		"2.1903",
 		"3",
 	}
 
-	urls := []string{}
+	repos := []string{}
 	for _, r := range alinuxReleases {
-		urls = append(urls, fmt.Sprintf(
-			"http://mirrors.aliyun.com/alinux/%s/os/%s/Packages/kernel-devel-%s%s.rpm",
+		repos = append(repos, fmt.Sprintf(
+			"http://mirrors.aliyun.com/alinux/%s/os/%s",
 			r,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		))
 	}
-	return urls
+	return repos
+}
+
+var alinuxReleaseRegex = regexp.MustCompile(`\.al[78]\.`)
+
+func (c *alinux) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return alinuxReleaseRegex.MatchString(kr.FullExtraversion)
 }
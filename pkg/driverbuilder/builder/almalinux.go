@@ -55,7 +55,11 @@ func (c *alma) TemplateScript() string {
 }
 
 func (c *alma) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
-	return fetchAlmaKernelURLS(kr), nil
+	return fetchRPMKernelURLs(c, kr)
+}
+
+func (c *alma) rpmPackages(_ kernelrelease.KernelRelease) []string {
+	return []string{"kernel-devel"}
 }
 
 func (c *alma) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -64,7 +68,7 @@ func (c *alma) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string)
 	}
 }
 
-func fetchAlmaKernelURLS(kr kernelrelease.KernelRelease) []string {
+func (c *alma) rpmRepos(kr kernelrelease.KernelRelease) []string {
 	almaReleases := []string{
 		"8",
 		"8.6",
@@ -72,25 +76,26 @@ func fetchAlmaKernelURLS(kr kernelrelease.KernelRelease) []string {
 		"9.0",
 	}
 
-	urls := []string{}
+	repos := []string{}
 	for _, r := range almaReleases {
 		if r >= "9" {
-			urls = append(urls, fmt.Sprintf(
-				"https://repo.almalinux.org/almalinux/%s/AppStream/%s/os/Packages/kernel-devel-%s%s.rpm",
+			repos = append(repos, fmt.Sprintf(
+				"https://repo.almalinux.org/almalinux/%s/AppStream/%s/os",
 				r,
 				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
 			))
 		} else {
-			urls = append(urls, fmt.Sprintf(
-				"https://repo.almalinux.org/almalinux/%s/BaseOS/%s/os/Packages/kernel-devel-%s%s.rpm",
+			repos = append(repos, fmt.Sprintf(
+				"https://repo.almalinux.org/almalinux/%s/BaseOS/%s/os",
 				r,
 				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
 			))
 		}
 	}
-	return urls
+	return repos
+}
+
+func (c *alma) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	el, ok := kr.EnterpriseLinux()
+	return ok && !el.UEK && el.HasMinor && el.Major >= 8
 }
@@ -16,21 +16,12 @@ package builder
 
 import (
 	"bufio"
-	"bytes"
-	"compress/bzip2"
-	"compress/gzip"
-	"database/sql"
 	_ "embed"
 	"fmt"
 	"github.com/blang/semver/v4"
-	"io"
-	"log"
 	"net/http"
-	"os"
 	"strings"
 
-	_ "modernc.org/sqlite"
-
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 )
 
@@ -44,7 +35,6 @@ type amazonBuilder interface {
 	Builder
 	repos() []string
 	baseUrl() string
-	ext() string
 }
 
 type amazonlinux struct {
@@ -122,10 +112,6 @@ func (a *amazonlinux) baseUrl() string {
 	return "http://repo.us-east-1.amazonaws.com"
 }
 
-func (a *amazonlinux) ext() string {
-	return "bz2"
-}
-
 func (a *amazonlinux2022) Name() string {
 	return TargetTypeAmazonLinux2022.String()
 }
@@ -145,10 +131,6 @@ func (a *amazonlinux2022) baseUrl() string {
 	return "https://al2022-repos-us-east-1-9761ab97.s3.dualstack.us-east-1.amazonaws.com/core/mirrors"
 }
 
-func (a *amazonlinux2022) ext() string {
-	return "gz"
-}
-
 func (a *amazonlinux2023) Name() string {
 	return TargetTypeAmazonLinux2023.String()
 }
@@ -167,10 +149,6 @@ func (a *amazonlinux2023) baseUrl() string {
 	return "https://cdn.amazonlinux.com/al2023/core/mirrors"
 }
 
-func (a *amazonlinux2023) ext() string {
-	return "gz"
-}
-
 func (a *amazonlinux2) Name() string {
 	return TargetTypeAmazonLinux2.String()
 }
@@ -201,10 +179,6 @@ func (a *amazonlinux2) baseUrl() string {
 	return "http://amazonlinux.us-east-1.amazonaws.com/2"
 }
 
-func (a *amazonlinux2) ext() string {
-	return "gz"
-}
-
 func buildMirror(a amazonBuilder, r string, kv kernelrelease.KernelRelease) (string, error) {
 	var baseURL string
 	switch a.(type) {
@@ -220,33 +194,21 @@ func buildMirror(a amazonBuilder, r string, kv kernelrelease.KernelRelease) (str
 	return mirror, nil
 }
 
-type unzipFunc func(io.Reader) ([]byte, error)
-
-func unzipFuncFromBuilder(a amazonBuilder) (unzipFunc, error) {
-	switch a.ext() {
-	case "gz":
-		return gunzip, nil
-	case "bz2":
-		return bunzip, nil
-	}
-	return nil, fmt.Errorf("unsupported extension: %s", a.ext())
-}
-
+// fetchAmazonLinuxPackagesURLs resolves the repositories from their mirror lists,
+// then looks for the kernel headers packages into their metadata.
 func fetchAmazonLinuxPackagesURLs(a amazonBuilder, kv kernelrelease.KernelRelease) ([]string, error) {
-	urls := []string{}
-	visited := make(map[string]struct{})
-
+	repos := []string{}
 	for _, v := range a.repos() {
-		err := func() error {
+		repo, err := func() (string, error) {
 			mirror, err := buildMirror(a, v, kv)
 			if err != nil {
-				return err
+				return "", err
 			}
 
 			// Obtain the repo URL by getting mirror URL content
 			mirrorRes, err := http.Get(mirror)
 			if err != nil {
-				return err
+				return "", err
 			}
 			defer mirrorRes.Body.Close()
 
@@ -256,111 +218,39 @@ func fetchAmazonLinuxPackagesURLs(a amazonBuilder, kv kernelrelease.KernelReleas
 				repo = scanner.Text()
 			}
 			if repo == "" {
-				return fmt.Errorf("repository not found")
+				return "", fmt.Errorf("repository not found")
 			}
 			repo = strings.ReplaceAll(strings.TrimSuffix(repo, "\n"), "$basearch", kv.Architecture.ToNonDeb())
-			repo = strings.TrimSuffix(repo, "/")
-			repoDatabaseURL := fmt.Sprintf("%s/repodata/primary.sqlite.%s", repo, a.ext())
-			if _, ok := visited[repoDatabaseURL]; ok {
-				return nil
-			}
-			// Download the repo database
-			repoRes, err := http.Get(repoDatabaseURL)
-			if err != nil {
-				return err
-			}
-			defer repoRes.Body.Close()
-			visited[repoDatabaseURL] = struct{}{}
-
-			unzip, err := unzipFuncFromBuilder(a)
-			if err != nil {
-				return err
-			}
-
-			dbBytes, err := unzip(repoRes.Body)
-			if err != nil {
-				return err
-			}
-			// Create the temporary database file
-			dbFile, err := os.CreateTemp(os.TempDir(), fmt.Sprintf("%s-*.sqlite", a.Name()))
-			if err != nil {
-				return err
-			}
-			defer os.Remove(dbFile.Name())
-			if _, err := dbFile.Write(dbBytes); err != nil {
-				return err
-			}
-			// Open the database
-			db, err := sql.Open("sqlite", dbFile.Name())
-			if err != nil {
-				return err
-			}
-			defer db.Close()
-			// Query the database
-			rel := strings.TrimPrefix(strings.TrimSuffix(kv.FullExtraversion, fmt.Sprintf(".%s", kv.Architecture.ToNonDeb())), "-")
-			q := fmt.Sprintf("SELECT location_href FROM packages WHERE name LIKE 'kernel-devel%%' AND version='%s' AND release='%s'", kv.Fullversion, rel)
-			stmt, err := db.Prepare(q)
-			if err != nil {
-				return err
-			}
-			defer stmt.Close()
-			rows, err := stmt.Query()
-			if err != nil {
-				return err
-			}
-			defer rows.Close()
-			for rows.Next() {
-				var href string
-				err = rows.Scan(&href)
-				if err != nil {
-					log.Fatal(err)
-				}
-				urls = append(urls, fmt.Sprintf("%s/%s", repo, href))
-			}
-			return dbFile.Close()
+			return strings.TrimSuffix(repo, "/"), nil
 		}()
 		if err != nil {
 			return nil, err
 		}
-
-		// Found, do not continue
-		if len(urls) > 0 {
-			break
-		}
+		repos = append(repos, repo)
 	}
 
-	return urls, nil
+	return resolveRPMKernelURLs(repos, []string{"kernel-devel"}, kv)
 }
 
-func gunzip(data io.Reader) (res []byte, err error) {
-	var r io.Reader
-	r, err = gzip.NewReader(data)
-	if err != nil {
-		return
-	}
-
-	var b bytes.Buffer
-	_, err = b.ReadFrom(r)
-	if err != nil {
-		return
-	}
-
-	res = b.Bytes()
-
-	return
+// recognizeAmazonLinux returns true if the kernel release
+// belongs to the requested Amazon Linux generation, eg: "4.14.171-136.231.amzn2.x86_64".
+func recognizeAmazonLinux(kr kernelrelease.KernelRelease, generation uint64) bool {
+	gen, ok := kr.AmazonLinux()
+	return ok && gen == generation
 }
 
-func bunzip(data io.Reader) (res []byte, err error) {
-	var r io.Reader
-	r = bzip2.NewReader(data)
+func (a *amazonlinux) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return recognizeAmazonLinux(kr, 1)
+}
 
-	var b bytes.Buffer
-	_, err = b.ReadFrom(r)
-	if err != nil {
-		return
-	}
+func (a *amazonlinux2) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return recognizeAmazonLinux(kr, 2)
+}
 
-	res = b.Bytes()
+func (a *amazonlinux2022) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return recognizeAmazonLinux(kr, 2022)
+}
 
-	return
+func (a *amazonlinux2023) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return recognizeAmazonLinux(kr, 2023)
 }
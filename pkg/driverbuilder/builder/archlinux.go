@@ -17,6 +17,7 @@ package builder
 import (
 	_ "embed"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
@@ -155,3 +156,11 @@ func (c *archlinux) KernelTemplateData(_ kernelrelease.KernelRelease, urls []str
 		KernelDownloadURL: urls[0],
 	}
 }
+
+// Matches stable, hardened and zen kernels (eg: "6.8.1-arch1-1")
+// as well as LTS ones (eg: "6.6.22-1-lts").
+var archlinuxReleaseRegex = regexp.MustCompile(`^-((arch|hardened|zen)\d+-\d+|\d+-lts)$`)
+
+func (c *archlinux) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return archlinuxReleaseRegex.MatchString(kr.FullExtraversion)
+}
@@ -41,3 +41,8 @@ func (b *bottlerocket) KernelTemplateData(kr kernelrelease.KernelRelease, urls [
 		KernelLocalVersion: kr.FullExtraversion,
 	}
 }
+
+// Bottlerocket kernels cannot be told apart from vanilla ones.
+func (b *bottlerocket) RecognizeKernelRelease(_ kernelrelease.KernelRelease) bool {
+	return false
+}
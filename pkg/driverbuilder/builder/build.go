@@ -21,28 +21,40 @@ import (
 
 	"github.com/falcosecurity/falcoctl/pkg/output"
 
+	"github.com/falcosecurity/driverkit/pkg/kernelconfig"
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
 var defaultImageTag = "latest" // This is overwritten when using the Makefile to build
 
 // Build contains the info about the on-going build.
 type Build struct {
-	TargetType        Type
-	KernelConfigData  string
-	KernelRelease     string
-	KernelVersion     string
-	DriverVersion     string
-	Architecture      string
-	ModuleFilePath    string
-	ModuleDriverName  string
-	ModuleDeviceName  string
-	BuilderImage      string
-	BuilderRepos      []string
-	ImagesListers     []ImagesLister
-	KernelUrls        []string
-	GCCVersion        string
+	TargetType       Type
+	KernelConfigData string
+	KernelRelease    string
+	KernelVersion    string
+	DriverVersion    string
+	Architecture     string
+	HostArchitecture string
+	CrossBuildMode   CrossBuildMode
+	// CrossCompile is true when the drivers get cross-compiled
+	// instead of being built under qemu emulation, see setCrossCompile.
+	CrossCompile     bool
+	ModuleFilePath   string
+	ProbeFilePath    string
+	ModuleDriverName string
+	ModuleDeviceName string
+	BuilderImage     string
+	BuilderRepos     []string
+	ImagesListers    []ImagesLister
+	KernelUrls       []string
+	GCCVersion       string
+	ClangVersion     string
+	// Compiler is the compiler family used to build the kernel module, see setCompiler.
+	Compiler          Compiler
 	RepoOrg           string
 	RepoName          string
 	Images            ImagesMap
@@ -50,15 +62,73 @@ type Build struct {
 	RegistryUser      string
 	RegistryPassword  string
 	RegistryPlainHTTP bool
+	// RegistryConfig is the docker config.json holding the registries credentials, default to the docker one.
+	RegistryConfig string
+	// AllImages makes the images listers load the images of any target and architecture, see ListImages.
+	AllImages bool
+	// ImagesCache caches the builder images repositories listings on disk, when set.
+	ImagesCache *ImagesCache
+	// ImagesLockFile pins the builder images to their digests, when set, see PinnedBuilderImage.
+	ImagesLockFile string
+	// ImageSignatureKey is the path of the public key verifying the builder images signatures, when set, see VerifyBuilderImage.
+	ImageSignatureKey    string
+	ImageSignaturePolicy SignaturePolicy
+	// ImageMirrors rewrite the builder images references, see MirroredImage.
+	ImageMirrors []ImageMirror
+	// ImagePolicy selects the builder image gcc version, breaking the ties with ImageTieBreaks, see selectGCCVersion.
+	ImagePolicy    ImagePolicy
+	ImageTieBreaks []ImageTieBreak
+	// ImageTargetOnly restricts the gcc selection to the target-specific images, when there are any.
+	ImageTargetOnly bool
+	// GCCRules state the target gcc version of some targets and kernels, see ruleGCCVersion.
+	GCCRules []GCCRule
+
+	// gccAutoSelected is true when the gcc version was selected by setGCCVersion, instead of being forced by user.
+	gccAutoSelected bool
+	// imagesLock holds the builder images digests pinned so far.
+	imagesLock *ImagesLock
+	// credentials holds the registries credentials, see credentialsStore.
+	credentials credentials.Store
 
 	*output.Printer
 }
 
-func (b *Build) KernelReleaseFromBuildConfig() kernelrelease.KernelRelease {
-	kv := kernelrelease.FromString(b.KernelRelease)
+// KernelReleaseFromBuildConfig returns the kernel release to build for,
+// failing when the configured one cannot be parsed.
+func (b *Build) KernelReleaseFromBuildConfig() (kernelrelease.KernelRelease, error) {
+	kv, err := kernelrelease.Parse(b.KernelRelease)
+	if err != nil {
+		return kernelrelease.KernelRelease{}, err
+	}
 	kv.Architecture = kernelrelease.Architecture(b.Architecture)
-	kv.KernelVersion = b.KernelVersion
-	return kv
+	uv := kernelrelease.ParseUnameVersion(b.KernelVersion)
+	kv.KernelVersion = uv.Build
+	kv.PackageVersion = uv.PackageVersion
+	return kv, nil
+}
+
+// CheckKernelConfig fails fast, explaining why, when the kernel config data
+// is available and the requested drivers cannot work with it.
+func (b *Build) CheckKernelConfig(kr kernelrelease.KernelRelease) error {
+	cfg, err := kernelconfig.FromBase64(b.KernelConfigData)
+	if err != nil {
+		return err
+	}
+	// Nothing to check without kernel config data
+	if cfg.Empty() {
+		return nil
+	}
+	if len(b.ModuleFilePath) > 0 {
+		if err := kernelconfig.Check(kr, cfg, kernelconfig.DriverKmod).Err(); err != nil {
+			return err
+		}
+	}
+	if len(b.ProbeFilePath) > 0 {
+		if err := kernelconfig.Check(kr, cfg, kernelconfig.DriverEBPF).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *Build) toGithubRepoArchive() string {
@@ -83,8 +153,8 @@ func (b *Build) hasCustomBuilderImage() bool {
 	return false
 }
 
-// builderImageTag returns the tag(latest, master or hash) to be used for the builder image.
-func (b *Build) builderImageTag() string {
+// BuilderImageTag returns the tag(latest, master or hash) to be used for the builder image.
+func (b *Build) BuilderImageTag() string {
 	if len(b.BuilderImage) > 0 {
 		customNames := strings.Split(b.BuilderImage, ":")
 		// Updated image tag if "auto:tag" is passed
@@ -95,18 +165,22 @@ func (b *Build) builderImageTag() string {
 	return defaultImageTag
 }
 
+// ClientForRegistry returns a client authenticating to registry, see RegistryCredential.
 func (b *Build) ClientForRegistry(registry string) *auth.Client {
-	client := auth.DefaultClient
+	client := &auth.Client{
+		Client: retry.DefaultClient,
+		Cache:  auth.DefaultCache,
+	}
 	client.SetUserAgent("driverkit")
 	client.Credential = func(ctx context.Context, reg string) (auth.Credential, error) {
-		if b.RegistryName == registry {
+		if b.RegistryName == registry && b.RegistryUser != "" {
 			return auth.Credential{
 				Username: b.RegistryUser,
 				Password: b.RegistryPassword,
 			}, nil
 		}
-
-		return auth.EmptyCredential, nil
+		// reg is the registry host, eg: registry-1.docker.io for docker.io
+		return b.credentialsStore().Get(ctx, credentials.ServerAddressFromHostname(reg))
 	}
 
 	return client
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestCheckKernelConfig(t *testing.T) {
+	withModules := base64.StdEncoding.EncodeToString([]byte("CONFIG_MODULES=y\nCONFIG_TRACEPOINTS=y\nCONFIG_HAVE_SYSCALL_TRACEPOINTS=y\n"))
+	withoutModules := base64.StdEncoding.EncodeToString([]byte("# CONFIG_MODULES is not set\nCONFIG_TRACEPOINTS=y\nCONFIG_HAVE_SYSCALL_TRACEPOINTS=y\n"))
+
+	tests := map[string]struct {
+		build   Build
+		wantErr string
+	}{
+		"no kernel config data": {
+			build: Build{KernelConfigData: "bm8tZGF0YQ==", ModuleFilePath: "/tmp/falco.ko"},
+		},
+		"kmod supported": {
+			build: Build{KernelConfigData: withModules, ModuleFilePath: "/tmp/falco.ko"},
+		},
+		"kmod not supported": {
+			build:   Build{KernelConfigData: withoutModules, ModuleFilePath: "/tmp/falco.ko"},
+			wantErr: "kmod cannot work on this kernel: CONFIG_MODULES is not enabled",
+		},
+		"kmod not requested": {
+			build: Build{KernelConfigData: withoutModules},
+		},
+		"probe not supported": {
+			build:   Build{KernelConfigData: withModules, ProbeFilePath: "/tmp/probe.o"},
+			wantErr: "ebpf cannot work on this kernel: CONFIG_BPF_SYSCALL is not enabled",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.build.KernelRelease = "6.1.0-13-amd64"
+			test.build.Architecture = "amd64"
+			kr, err := test.build.KernelReleaseFromBuildConfig()
+			assert.NilError(t, err)
+			err = test.build.CheckKernelConfig(kr)
+			if test.wantErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.wantErr)
+			}
+		})
+	}
+}
@@ -39,6 +39,7 @@ const (
   -DCREATE_TEST_TARGETS=Off \
   -DBUILD_LIBSCAP_GVISOR=Off \
   -DBUILD_LIBSCAP_MODERN_BPF=Off \
+  -DBUILD_BPF=%s \
   -DENABLE_DRIVERS_TESTS=Off \
   -DDRIVER_NAME=%s \
   -DPROBE_NAME=%s \
@@ -54,6 +55,9 @@ const (
 //go:embed templates/libs_download.sh
 var libsDownloadTemplate string
 
+//go:embed templates/make_driver.sh
+var makeDriverTemplate string
+
 var HeadersNotFoundErr = errors.New("kernel headers not found")
 
 // Config contains all the configurations needed to build the kernel module.
@@ -68,13 +72,28 @@ func (c Config) ToDriverFullPath() string {
 	return path.Join(DriverDirectory, "build", "driver", fmt.Sprintf("%s.ko", c.DriverName))
 }
 
+func (c Config) ToProbeFullPath() string {
+	return path.Join(DriverDirectory, "build", "driver", "bpf", "probe.o")
+}
+
 type commonTemplateData struct {
 	DriverBuildDir   string
 	ModuleDriverName string
 	ModuleFullPath   string
 	BuildModule      bool
+	BuildProbe       bool
 	GCCVersion       string
+	ClangVersion     string
 	CmakeCmd         string
+	// DetectGCC is true when GCCVersion is a guess, so that the script
+	// prefers the gcc found in the kernel headers, if provided by the image.
+	DetectGCC bool
+	// Compiler is the compiler family used to build the kernel module,
+	// "auto" meaning that the script detects it from the kernel headers.
+	Compiler string
+	// CrossCompilePrefix is the CROSS_COMPILE prefix of the toolchain, eg: "aarch64-linux-gnu-",
+	// empty when not cross-compiling.
+	CrossCompilePrefix string
 }
 
 // Builder represents a builder capable of generating a script for a driverkit target.
@@ -131,7 +150,8 @@ func KernelDownloadScript(b Builder,
 	kr kernelrelease.KernelRelease,
 	printer *output.Printer,
 ) (string, error) {
-	t := template.New("download-kernel")
+	// Provide "verifyChecksum", verifying the kernel headers packages checksums, when known
+	t := template.New("download-kernel").Funcs(template.FuncMap{"verifyChecksum": verifyChecksum})
 	parsed, err := t.Parse(b.TemplateKernelUrlsScript())
 	if err != nil {
 		return "", err
@@ -185,6 +205,10 @@ func KernelDownloadScript(b Builder,
 // Script retrieves the actually drivers building script
 func Script(b Builder, c Config, kr kernelrelease.KernelRelease) (string, error) {
 	t := template.New(b.Name())
+	// Provide the "make-driver" template, building the kernel module with the right compiler
+	if _, err := t.Parse(makeDriverTemplate); err != nil {
+		return "", err
+	}
 	parsed, err := t.Parse(b.TemplateScript())
 	if err != nil {
 		return "", err
@@ -194,7 +218,10 @@ func Script(b Builder, c Config, kr kernelrelease.KernelRelease) (string, error)
 	if bb, ok := b.(TemplateDataSpecifier); ok {
 		td = bb.TemplateData(c, kr)
 	} else {
-		td = c.toTemplateData(b, kr)
+		td, err = c.toTemplateData(b, kr)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	buf := bytes.NewBuffer(nil)
@@ -241,6 +268,22 @@ func defaultGCC(kr kernelrelease.KernelRelease) semver.Version {
 	}
 }
 
+func defaultClang(kr kernelrelease.KernelRelease) semver.Version {
+	switch kr.Major {
+	case 6:
+		return semver.Version{Major: 14}
+	case 5:
+		if kr.Minor >= 15 {
+			return semver.Version{Major: 12}
+		}
+		return semver.Version{Major: 10}
+	case 4:
+		return semver.Version{Major: 7}
+	default:
+		return semver.Version{Major: 14}
+	}
+}
+
 func mustParseTolerant(gccStr string) semver.Version {
 	g, err := semver.ParseTolerant(gccStr)
 	if err != nil {
@@ -252,26 +295,42 @@ func mustParseTolerant(gccStr string) semver.Version {
 // Algorithm.
 // * always load images (note that it loads only images that provide gccversion, if set by user)
 // * if user set a fixed gccversion, we are good to go
-// * otherwise, try to fix the best-match gcc version provided by any of the loaded images;
-// see below for algorithm explanation
-func (b *Build) setGCCVersion(builder Builder, kr kernelrelease.KernelRelease) {
+// * the target gcc is the one stated by the first matching user gcc rule, if any;
+// otherwise the one the kernel was built with, when known from the kernel config data;
+// otherwise it is guessed, and the build script will prefer the one found in the kernel headers, if provided by the image
+// * otherwise, try to fix the best-match gcc version provided by any of the loaded images,
+// according to the image policy, nearest-lower by default; see selectGCCVersion;
+// it fails when none of them suits the policy
+func (b *Build) setGCCVersion(builder Builder, kr kernelrelease.KernelRelease) error {
 	if !b.hasCustomBuilderImage() {
-		b.LoadImages()
+		// Images are only loaded once, subsequent scripts (eg: gcc fallbacks) reuse them
+		if len(b.Images) == 0 {
+			b.LoadImages()
+		}
+	} else {
+		// Custom builder images are only used to cross-compile when explicitly requested
+		b.setCrossCompile(false)
 	}
 
 	if len(b.GCCVersion) > 0 {
-		// If set from user, go on
-		return
+		// If set from user, or already selected, go on
+		return nil
 	}
 
+	b.gccAutoSelected = true
 	b.GCCVersion = "8" // default value
 
-	// if builder implements "GCCVersionRequestor" interface -> use it
+	// if any of the user gcc rules matches the target and kernelrelease -> use it
+	// Else, if the kernel config data states the gcc the kernel was built with -> use it
+	// Else, if builder implements "GCCVersionRequestor" interface -> use it
 	// Else, fetch the best builder available from the kernelrelease version
 	// using the deadly simple defaultGCC() algorithm
 	// Always returns the nearest one
-	var targetGCC semver.Version
-	if bb, ok := builder.(GCCVersionRequestor); ok {
+	targetGCC, _ := b.ruleGCCVersion(kr)
+	if targetGCC.EQ(semver.Version{}) {
+		targetGCC, _ = b.kernelGCCVersion()
+	}
+	if bb, ok := builder.(GCCVersionRequestor); ok && targetGCC.EQ(semver.Version{}) {
 		targetGCC = bb.GCCVersion(kr)
 	}
 	// If builder implements GCCVersionRequestor but returns an empty semver.Version
@@ -283,44 +342,57 @@ func (b *Build) setGCCVersion(builder Builder, kr kernelrelease.KernelRelease) {
 
 	if b.hasCustomBuilderImage() {
 		b.GCCVersion = targetGCC.String()
+		return nil
+	}
+
+	// Select, among the gcc versions provided by the loaded images,
+	// the one suiting the target gcc according to the image policy
+	gcc, ok := b.selectGCCVersion(targetGCC)
+	if !ok {
+		// Do not leave the default value behind, as if it was selected
+		b.GCCVersion = ""
+		b.gccAutoSelected = false
+		return fmt.Errorf("no builder image provides a gcc version suiting the %s image policy for gcc %s",
+			b.imagePolicy(), targetGCC)
+	}
+	b.GCCVersion = gcc.String()
+	b.Logger.Debug("found GCC",
+		b.Logger.Args("targetGCC", targetGCC.String(), "version", b.GCCVersion, "policy", b.imagePolicy().String()))
+	return nil
+}
+
+// Algorithm.
+// * only needed when the eBPF probe is requested or the kernel module may be built with clang
+// * if user set a fixed clang version or a custom builder image, we are good to go
+// * otherwise, try to fix the best-match clang version provided by the image
+// selected for the gcc version, using the nearest-lower logic, the default one of setGCCVersion;
+// images not declaring any clang version only provide the default distro clang.
+func (b *Build) setClangVersion(kr kernelrelease.KernelRelease) {
+	needsClang := len(b.ProbeFilePath) > 0 || b.Compiler == CompilerClang || b.Compiler == CompilerAuto
+	if !needsClang || len(b.ClangVersion) > 0 || b.hasCustomBuilderImage() {
 		return
 	}
 
-	// Step 1:
-	// If we are able to either find a specific-target image,
-	// or "any" target image that provide desired gcc,
-	// we are over.
-	image, ok := b.Images.findImage(b.TargetType, targetGCC)
-	if ok {
-		b.GCCVersion = image.GCCVersion.String()
-	} else {
-		// Step 2:
-		// Build the list of "proposed" GCC versions,
-		// that is, the list of available gccs from images
-		// for each builder image
-		proposedGCCs := make([]semver.Version, 0)
-		for _, img := range b.Images {
-			proposedGCCs = append(proposedGCCs, img.GCCVersion)
-			b.Logger.Debug("proposed GCC",
-				b.Logger.Args("image", img.Name,
-					"targetGCC", targetGCC.String(),
-					"proposedGCC", img.GCCVersion.String()))
-		}
+	image, ok := b.Images.findImage(b.TargetType, mustParseTolerant(b.GCCVersion))
+	if !ok || len(image.ClangVersions) == 0 {
+		b.Logger.Debug("using default clang", b.Logger.Args("image", image.Name))
+		return
+	}
 
-		// Now, sort versions and fetch
-		// the nearest gcc, that is also < targetGCC
-		semver.Sort(proposedGCCs)
-		lastGCC := proposedGCCs[0]
-		for _, gcc := range proposedGCCs {
-			if gcc.GT(targetGCC) {
-				break
-			}
-			lastGCC = gcc
+	targetClang := defaultClang(kr)
+	proposedClangs := make([]semver.Version, len(image.ClangVersions))
+	copy(proposedClangs, image.ClangVersions)
+	semver.Sort(proposedClangs)
+	lastClang := proposedClangs[0]
+	for _, clang := range proposedClangs {
+		if clang.GT(targetClang) {
+			break
 		}
-		b.GCCVersion = lastGCC.String()
+		lastClang = clang
 	}
-	b.Logger.Debug("found GCC",
-		b.Logger.Args("targetGCC", targetGCC.String(), "version", b.GCCVersion))
+	b.ClangVersion = lastClang.String()
+	b.Logger.Debug("found clang",
+		b.Logger.Args("targetClang", targetClang.String(), "version", b.ClangVersion))
 }
 
 type BuilderImageNetworkMode interface {
@@ -331,7 +403,7 @@ type BuilderImageNetworkMode interface {
 func (b *Build) GetBuilderImage() string {
 	if b.hasCustomBuilderImage() {
 		// BuilderImage MUST have requested GCC installed inside
-		return b.BuilderImage
+		return b.MirroredImage(b.BuilderImage)
 	}
 
 	// NOTE: here below we are already sure that we are going
@@ -339,7 +411,7 @@ func (b *Build) GetBuilderImage() string {
 	// has already set an existent gcc version
 	// (ie: one provided by an image) for us
 	image, _ := b.Images.findImage(b.TargetType, mustParseTolerant(b.GCCVersion))
-	return image.Name
+	return b.MirroredImage(image.Name)
 }
 
 // Factory returns a builder for the given target.
@@ -371,15 +443,31 @@ func Targets() []string {
 	return res
 }
 
-func (c Config) toTemplateData(b Builder, kr kernelrelease.KernelRelease) commonTemplateData {
-	c.setGCCVersion(b, kr)
+func (c Config) toTemplateData(b Builder, kr kernelrelease.KernelRelease) (commonTemplateData, error) {
+	// neither the gcc stated by a user gcc rule nor the one from the kernel config data are a guess
+	_, gccKnown := c.ruleGCCVersion(kr)
+	if !gccKnown {
+		_, gccKnown = c.kernelGCCVersion()
+	}
+	detectGCC := len(c.GCCVersion) == 0 && !gccKnown
+	if err := c.setGCCVersion(b, kr); err != nil {
+		return commonTemplateData{}, err
+	}
+	c.setCompiler(kr)
+	c.setClangVersion(kr)
 	return commonTemplateData{
-		DriverBuildDir:   DriverDirectory,
-		ModuleDriverName: c.DriverName,
-		ModuleFullPath:   c.ToDriverFullPath(),
-		BuildModule:      len(c.ModuleFilePath) > 0,
-		GCCVersion:       c.GCCVersion,
+		DriverBuildDir:     DriverDirectory,
+		ModuleDriverName:   c.DriverName,
+		ModuleFullPath:     c.ToDriverFullPath(),
+		BuildModule:        len(c.ModuleFilePath) > 0,
+		BuildProbe:         len(c.ProbeFilePath) > 0,
+		GCCVersion:         c.GCCVersion,
+		DetectGCC:          detectGCC,
+		ClangVersion:       c.ClangVersion,
+		Compiler:           c.Compiler.String(),
+		CrossCompilePrefix: c.crossCompilePrefix(),
 		CmakeCmd: fmt.Sprintf(cmakeCmdFmt,
+			c.cmakeBuildBPF(),
 			c.DriverName,
 			c.DriverName,
 			c.DriverVersion,
@@ -388,7 +476,15 @@ func (c Config) toTemplateData(b Builder, kr kernelrelease.KernelRelease) common
 			c.DeviceName,
 			c.DeviceName,
 			c.DriverVersion),
+	}, nil
+}
+
+// cmakeBuildBPF returns the value of the BUILD_BPF cmake option.
+func (c Config) cmakeBuildBPF() string {
+	if len(c.ProbeFilePath) > 0 {
+		return "On"
 	}
+	return "Off"
 }
 
 func resolveURLReference(u string) string {
@@ -15,10 +15,15 @@ limitations under the License.
 package builder
 
 import (
+	"encoding/base64"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/blang/semver/v4"
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
 )
 
 var gccTests = []struct {
@@ -105,3 +110,213 @@ func TestDefaultGCC(t *testing.T) {
 		}
 	}
 }
+
+func TestSetClangVersion(t *testing.T) {
+	kr := kernelrelease.FromString("5.10.0-20-amd64")
+	clangs := []semver.Version{
+		semver.MustParse("14.0.0"),
+		semver.MustParse("7.0.0"),
+		semver.MustParse("9.0.0"),
+	}
+	b := &Build{
+		TargetType:    TargetTypeDebian,
+		GCCVersion:    "10.0.0",
+		ProbeFilePath: "/tmp/probe.o",
+		Images: ImagesMap{
+			"any_10.0.0": Image{
+				Target:        "any",
+				GCCVersion:    semver.MustParse("10.0.0"),
+				ClangVersions: clangs,
+				Name:          "foo/test:any-x86_64_gcc10.0.0_clang14.0.0_clang7.0.0_clang9.0.0-latest",
+			},
+		},
+		Printer: output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+	}
+
+	// defaultClang for 5.10 is 10: the nearest lower one is 9
+	b.setClangVersion(kr)
+	if b.ClangVersion != "9.0.0" {
+		t.Fatalf("ClangVersion (%s) != expected (9.0.0)", b.ClangVersion)
+	}
+	// the images list must not be reordered
+	if !b.Images["any_10.0.0"].ClangVersions[0].EQ(clangs[0]) {
+		t.Fatalf("image clang versions were modified")
+	}
+}
+
+func compilerTestBuild(kernelConfig string) *Build {
+	return &Build{
+		TargetType:       TargetTypeUbuntu,
+		Architecture:     kernelrelease.ArchitectureAmd64,
+		KernelConfigData: base64.StdEncoding.EncodeToString([]byte(kernelConfig)),
+		ModuleFilePath:   "/tmp/falco.ko",
+		Images: ImagesMap{
+			"ubuntu_12.0.0": Image{
+				Target:        TargetTypeUbuntu,
+				GCCVersion:    semver.MustParse("12.0.0"),
+				ClangVersions: []semver.Version{semver.MustParse("14.0.0"), semver.MustParse("16.0.0")},
+				Name:          "foo/test:ubuntu-x86_64_gcc12.0.0_clang14.0.0_clang16.0.0-latest",
+			},
+			"any_11.0.0": Image{
+				Target:        "any",
+				GCCVersion:    semver.MustParse("11.0.0"),
+				ClangVersions: []semver.Version{semver.MustParse("15.0.0")},
+				Name:          "foo/test:any-x86_64_gcc11.0.0_clang15.0.0-latest",
+			},
+			"any_8.0.0": Image{
+				Target:     "any",
+				GCCVersion: semver.MustParse("8.0.0"),
+				Name:       "foo/test:any-x86_64_gcc8.0.0-latest",
+			},
+		},
+		Printer: output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+	}
+}
+
+func TestSetCompiler(t *testing.T) {
+	kr := kernelrelease.FromString("6.1.0-generic")
+
+	// clang-built kernel: the nearest lower clang of the target-specific image is picked
+	b := compilerTestBuild("CONFIG_CC_IS_CLANG=y\nCONFIG_CLANG_VERSION=150007\n")
+	b.GCCVersion = "8.0.0"
+	b.setCompiler(kr)
+	if b.Compiler != CompilerClang || b.ClangVersion != "14.0.0" || b.GCCVersion != "12.0.0" {
+		t.Fatalf("unexpected clang build: compiler %s, clang %s, gcc %s", b.Compiler, b.ClangVersion, b.GCCVersion)
+	}
+	if img := b.GetBuilderImage(); img != b.Images["ubuntu_12.0.0"].Name {
+		t.Fatalf("unexpected builder image %s", img)
+	}
+
+	// gcc-built kernel: nothing changes
+	b = compilerTestBuild("CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=120200\n")
+	b.GCCVersion = "8.0.0"
+	b.setCompiler(kr)
+	if b.Compiler != CompilerGCC || b.ClangVersion != "" || b.GCCVersion != "8.0.0" {
+		t.Fatalf("unexpected gcc build: compiler %s, clang %s, gcc %s", b.Compiler, b.ClangVersion, b.GCCVersion)
+	}
+
+	// no kernel config: left to the build script
+	b = compilerTestBuild("no-data")
+	b.setCompiler(kr)
+	if b.Compiler != CompilerAuto {
+		t.Fatalf("unexpected compiler %s", b.Compiler)
+	}
+
+	// forced by user
+	b = compilerTestBuild("CONFIG_CC_IS_CLANG=y\nCONFIG_CLANG_VERSION=150007\n")
+	b.Compiler = CompilerGCC
+	b.setCompiler(kr)
+	if b.Compiler != CompilerGCC || b.ClangVersion != "" {
+		t.Fatalf("unexpected forced gcc build: compiler %s, clang %s", b.Compiler, b.ClangVersion)
+	}
+}
+
+func TestScriptCompiler(t *testing.T) {
+	kr := kernelrelease.FromString("6.1.0-generic")
+	ubuntuBuilder, err := Factory(TargetTypeUbuntu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kernelConfig string
+		expected     []string
+	}{
+		{
+			kernelConfig: "CONFIG_CC_IS_CLANG=y\nCONFIG_CLANG_VERSION=160006\n",
+			expected:     []string{"make LLVM=1 CC=/usr/bin/clang-16.0.0 LD=/usr/bin/ld.lld-16.0.0 driver"},
+		},
+		{
+			kernelConfig: "CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=110400\n",
+			expected:     []string{"make CC=/usr/bin/gcc-11.0.0 driver"},
+		},
+		{
+			kernelConfig: "no-data",
+			expected: []string{
+				"GCC=/usr/bin/gcc-12.0.0",
+				"if grep -qs '^CONFIG_CC_IS_CLANG=y'",
+				"make LLVM=1 CC=/usr/bin/clang-14.0.0 LD=/usr/bin/ld.lld-14.0.0 driver",
+				"make CC=${GCC} driver",
+			},
+		},
+	}
+	for _, test := range tests {
+		b := compilerTestBuild(test.kernelConfig)
+		script, err := Script(ubuntuBuilder, b.ToConfig(), kr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(script, expected) {
+				t.Fatalf("script for kernel config %q does not contain %q:\n%s", test.kernelConfig, expected, script)
+			}
+		}
+	}
+}
+
+func TestScriptProbe(t *testing.T) {
+	kr := kernelrelease.FromString("6.1.0-generic")
+	for _, target := range []Type{TargetTypeUbuntu, TargetTypeCentos, TargetTypeVanilla} {
+		builder, err := Factory(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := compilerTestBuild("CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=120200\n")
+		b.TargetType = target
+		b.ProbeFilePath = "/tmp/probe.o"
+		b.ClangVersion = "14.0.0"
+		script, err := Script(builder, b.ToConfig(), kr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "make CLANG=/usr/bin/clang-14.0.0 LLC=/usr/bin/llc-14.0.0\nls -l probe.o"
+		if !strings.Contains(script, expected) {
+			t.Fatalf("script for target %s does not contain %q:\n%s", target, expected, script)
+		}
+	}
+}
+
+func TestSetGCCVersionFromKernelConfig(t *testing.T) {
+	kr := kernelrelease.FromString("6.1.0-generic")
+	ubuntuBuilder, err := Factory(TargetTypeUbuntu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// defaultGCC for 6.1 is 12, but the kernel was built with 11.4
+	b := compilerTestBuild("CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=110400\n")
+	if err := b.setGCCVersion(ubuntuBuilder, kr); err != nil {
+		t.Fatal(err)
+	}
+	if b.GCCVersion != "11.0.0" {
+		t.Fatalf("GCCVersion (%s) != expected (11.0.0)", b.GCCVersion)
+	}
+
+	// only the compiler version text is known
+	b = compilerTestBuild("CONFIG_CC_VERSION_TEXT=\"gcc (GCC) 8.5.0 20210514 (Red Hat 8.5.0-18)\"\n")
+	if err := b.setGCCVersion(ubuntuBuilder, kr); err != nil {
+		t.Fatal(err)
+	}
+	if b.GCCVersion != "8.0.0" {
+		t.Fatalf("GCCVersion (%s) != expected (8.0.0)", b.GCCVersion)
+	}
+
+	// unknown: fallback at the heuristic
+	b = compilerTestBuild("no-data")
+	if err := b.setGCCVersion(ubuntuBuilder, kr); err != nil {
+		t.Fatal(err)
+	}
+	if b.GCCVersion != "12.0.0" {
+		t.Fatalf("GCCVersion (%s) != expected (12.0.0)", b.GCCVersion)
+	}
+
+	// no image provides the exact gcc: the script cannot be built
+	b = compilerTestBuild("CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=130200\n")
+	b.ImagePolicy = ImagePolicyExact
+	if _, err := Script(ubuntuBuilder, b.ToConfig(), kr); err == nil {
+		t.Fatal("expected an error for the exact image policy")
+	}
+	if b.GCCVersion != "" || b.gccAutoSelected {
+		t.Fatalf("GCCVersion (%s) left selected after the image policy failure", b.GCCVersion)
+	}
+}
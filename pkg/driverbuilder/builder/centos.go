@@ -17,6 +17,8 @@ package builder
 import (
 	_ "embed"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/blang/semver/v4"
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
@@ -54,6 +56,14 @@ func (c *centos) TemplateScript() string {
 }
 
 func (c *centos) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
+	return fetchRPMKernelURLs(c, kr)
+}
+
+func (c *centos) rpmPackages(_ kernelrelease.KernelRelease) []string {
+	return []string{"kernel-devel"}
+}
+
+func (c *centos) rpmRepos(kr kernelrelease.KernelRelease) []string {
 	vaultReleases := []string{
 		"6.0/os",
 		"6.0/updates",
@@ -129,55 +139,44 @@ func (c *centos) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
 		"9-stream/BaseOS",
 	}
 
-	urls := []string{}
+	// Only look into the repositories of the kernel major release, when known
+	major := ""
+	if el, ok := kr.EnterpriseLinux(); ok {
+		major = strconv.FormatUint(el.Major, 10)
+	}
+	sameMajor := func(r string) bool {
+		// eg: "7" for "7.9.2009/os", "8" for "8-stream/BaseOS"
+		releaseMajor := strings.FieldsFunc(r, func(c rune) bool { return c == '.' || c == '/' || c == '-' })[0]
+		return major == "" || releaseMajor == major
+	}
+
+	repos := []string{}
 	for _, r := range edgeReleases {
-		urls = append(urls, fmt.Sprintf(
-			"https://mirrors.edge.kernel.org/centos/%s/%s/Packages/kernel-devel-%s%s.rpm",
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
+		if sameMajor(r) {
+			repos = append(repos, fmt.Sprintf("https://mirrors.edge.kernel.org/centos/%s/%s", r, kr.Architecture.ToNonDeb()))
+		}
 	}
 	for _, r := range streamReleases {
-		urls = append(urls, fmt.Sprintf(
-			"https://mirrors.edge.kernel.org/centos/%s/%s/os/Packages/kernel-devel-%s%s.rpm",
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
+		if sameMajor(r) {
+			repos = append(repos, fmt.Sprintf("https://mirrors.edge.kernel.org/centos/%s/%s/os", r, kr.Architecture.ToNonDeb()))
+		}
 	}
 	for _, r := range vaultReleases {
-		urls = append(urls, fmt.Sprintf(
-			"http://vault.centos.org/%s/%s/Packages/kernel-devel-%s%s.rpm",
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
+		if sameMajor(r) {
+			repos = append(repos, fmt.Sprintf("http://vault.centos.org/%s/%s", r, kr.Architecture.ToNonDeb()))
+		}
 	}
 	for _, r := range centos8VaultReleases {
-		urls = append(urls, fmt.Sprintf(
-			"http://vault.centos.org/%s/%s/os/Packages/kernel-devel-%s%s.rpm",
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
+		if sameMajor(r) {
+			repos = append(repos, fmt.Sprintf("http://vault.centos.org/%s/%s/os", r, kr.Architecture.ToNonDeb()))
+		}
 	}
-
 	for _, r := range stream9Releases {
-		urls = append(urls, fmt.Sprintf(
-			"http://mirror.stream.centos.org/%s/%s/os/Packages/kernel-devel-%s%s.rpm",
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
+		if sameMajor(r) {
+			repos = append(repos, fmt.Sprintf("http://mirror.stream.centos.org/%s/%s/os", r, kr.Architecture.ToNonDeb()))
+		}
 	}
-
-	return urls, nil
+	return repos
 }
 
 func (c *centos) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -198,3 +197,13 @@ func (c *centos) GCCVersion(kr kernelrelease.KernelRelease) semver.Version {
 	}
 	return semver.Version{}
 }
+
+// Centos 6, 7 and 8 kernels (eg: "3.10.0-1160.el7.x86_64", "4.18.0-348.7.1.el8_5.x86_64")
+// and Centos Stream 9 ones, that do not carry any minor (eg: "5.14.0-362.el9.x86_64").
+func (c *centos) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	el, ok := kr.EnterpriseLinux()
+	if !ok || el.UEK {
+		return false
+	}
+	return (el.Major >= 6 && el.Major <= 8) || (el.Major == 9 && !el.HasMinor)
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"github.com/blang/semver/v4"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelconfig"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// Compiler is the compiler family used to build the kernel module.
+type Compiler string
+
+const (
+	// CompilerAuto uses the compiler the kernel was built with,
+	// detected from the kernel config data or, when missing, from the kernel headers at build time.
+	CompilerAuto Compiler = "auto"
+	// CompilerGCC builds with gcc.
+	CompilerGCC Compiler = "gcc"
+	// CompilerClang builds with clang and the LLVM toolchain (LLVM=1).
+	CompilerClang Compiler = "clang"
+)
+
+func (c Compiler) String() string {
+	return string(c)
+}
+
+// kernelGCCVersion returns the gcc version the kernel was built with, as stated by the kernel config data.
+func (b *Build) kernelGCCVersion() (semver.Version, bool) {
+	cfg, err := kernelconfig.FromBase64(b.KernelConfigData)
+	if err != nil {
+		return semver.Version{}, false
+	}
+	return cfg.GCCVersion()
+}
+
+// findClangImage returns the image providing the nearest clang version that is also <= clangVers,
+// or the lowest one if none is, along with that clang version.
+// As findImage, it prefers specific target images over "any" target ones.
+func (im ImagesMap) findClangImage(target Type, clangVers semver.Version) (Image, semver.Version, bool) {
+	for _, t := range []Type{target, "any"} {
+		var (
+			best      Image
+			bestClang semver.Version
+			found     bool
+		)
+		for _, img := range im {
+			if img.Target != t {
+				continue
+			}
+			for _, clang := range img.ClangVersions {
+				if !found || clangBetter(clang, bestClang, clangVers) ||
+					(clang.EQ(bestClang) && img.GCCVersion.GT(best.GCCVersion)) {
+					best, bestClang, found = img, clang, true
+				}
+			}
+		}
+		if found {
+			return best, bestClang, true
+		}
+	}
+	return Image{}, semver.Version{}, false
+}
+
+// clangBetter tells whether clang is a better match than current for the target version:
+// versions <= target are preferred, the nearest the better.
+func clangBetter(clang, current, target semver.Version) bool {
+	if clang.LTE(target) != current.LTE(target) {
+		return clang.LTE(target)
+	}
+	if clang.LTE(target) {
+		return clang.GT(current)
+	}
+	return clang.LT(current)
+}
+
+// Algorithm.
+// * the compiler only matters to the kernel module
+// * unless forced by user, use the compiler the kernel was built with, as stated by the kernel config data;
+// without kernel config data, leave it to the build script to detect it from the kernel headers
+// * for clang-built kernels, select the image providing the nearest clang version
+// to the kernel one, using the nearest-lower logic, the default one of setGCCVersion;
+// the image gcc version is then used, so that GetBuilderImage picks the image up.
+func (b *Build) setCompiler(kr kernelrelease.KernelRelease) {
+	var kernelClang semver.Version
+	if b.Compiler == "" || b.Compiler == CompilerAuto {
+		b.Compiler = CompilerAuto
+		if cfg, err := kernelconfig.FromBase64(b.KernelConfigData); err == nil && !cfg.Empty() {
+			b.Compiler = CompilerGCC
+			if v, ok := cfg.ClangVersion(); ok {
+				b.Compiler = CompilerClang
+				kernelClang = v
+			}
+		}
+	}
+	b.Logger.Debug("found compiler",
+		b.Logger.Args("compiler", b.Compiler.String(), "kernelClang", kernelClang.String()))
+
+	if b.Compiler != CompilerClang || len(b.ClangVersion) > 0 || b.hasCustomBuilderImage() {
+		return
+	}
+	if kernelClang.EQ(semver.Version{}) {
+		kernelClang = defaultClang(kr)
+	}
+
+	image, clang, ok := b.Images.findClangImage(b.TargetType, kernelClang)
+	if !ok {
+		b.Logger.Debug("using default clang, no image provides versioned clangs")
+		return
+	}
+	b.GCCVersion = image.GCCVersion.String()
+	b.ClangVersion = clang.String()
+	b.Logger.Debug("found clang",
+		b.Logger.Args("targetClang", kernelClang.String(), "version", b.ClangVersion, "image", image.Name))
+}
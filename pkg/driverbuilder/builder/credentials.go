@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// RegistryCredential returns the credential for registry, eg: docker.io:
+// the registry options ones for RegistryName, otherwise the docker config.json ones,
+// see RegistryConfig, including the credential helpers ones.
+func (b *Build) RegistryCredential(ctx context.Context, registry string) (auth.Credential, error) {
+	if b.RegistryName == registry && b.RegistryUser != "" {
+		return auth.Credential{
+			Username: b.RegistryUser,
+			Password: b.RegistryPassword,
+		}, nil
+	}
+	return b.credentialsStore().Get(ctx, credentials.ServerAddressFromRegistry(registry))
+}
+
+// credentialsStore lazily loads the docker config.json credentials store.
+func (b *Build) credentialsStore() credentials.Store {
+	if b.credentials != nil {
+		return b.credentials
+	}
+	var (
+		store credentials.Store
+		err   error
+	)
+	opts := credentials.StoreOptions{DetectDefaultNativeStore: true}
+	if b.RegistryConfig != "" {
+		store, err = credentials.NewStore(b.RegistryConfig, opts)
+	} else {
+		store, err = credentials.NewStoreFromDocker(opts)
+	}
+	if err != nil {
+		if b.Printer != nil {
+			b.Logger.Warn("cannot load the registries credentials",
+				b.Logger.Args("config", b.RegistryConfig, "err", err.Error()))
+		}
+		store = credentials.NewMemoryStore()
+	}
+	b.credentials = store
+	return store
+}
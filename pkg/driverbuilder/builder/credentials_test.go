@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func TestRegistryCredential(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	// "Zm9vOmJhcg==" is "foo:bar"
+	assert.NilError(t, os.WriteFile(config, []byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"Zm9vOmJhcg=="},"registry.internal":{"auth":"Zm9vOmJhcg=="}}}`), 0o600))
+
+	b := &Build{
+		RegistryName:     "ghcr.io",
+		RegistryUser:     "user",
+		RegistryPassword: "password",
+		RegistryConfig:   config,
+	}
+	tests := map[string]auth.Credential{
+		"ghcr.io":           {Username: "user", Password: "password"},
+		"docker.io":         {Username: "foo", Password: "bar"},
+		"registry.internal": {Username: "foo", Password: "bar"},
+		"quay.io":           auth.EmptyCredential,
+	}
+	for registry, expected := range tests {
+		t.Run(registry, func(t *testing.T) {
+			cred, err := b.RegistryCredential(context.Background(), registry)
+			assert.NilError(t, err)
+			assert.Equal(t, cred, expected)
+		})
+	}
+
+	// A missing config only means no credentials
+	b = &Build{RegistryConfig: filepath.Join(t.TempDir(), "missing.json")}
+	cred, err := b.RegistryCredential(context.Background(), "docker.io")
+	assert.NilError(t, err)
+	assert.Equal(t, cred, auth.EmptyCredential)
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"fmt"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// CrossBuildMode tells how drivers are built for an architecture other than the host one.
+type CrossBuildMode string
+
+const (
+	// CrossBuildAuto cross-compiles when a suitable builder image exists, else it falls back at qemu.
+	CrossBuildAuto CrossBuildMode = "auto"
+	// CrossBuildCompile cross-compiles with a toolchain running on the host architecture.
+	CrossBuildCompile CrossBuildMode = "cross-compile"
+	// CrossBuildQemu runs the target architecture toolchain under qemu emulation.
+	CrossBuildQemu CrossBuildMode = "qemu"
+)
+
+func (m CrossBuildMode) String() string {
+	return string(m)
+}
+
+// crossCompileArch holds the kbuild ARCH and CROSS_COMPILE values for an architecture.
+type crossCompileArch struct {
+	kernelArch string
+	prefix     string
+}
+
+var crossCompileArchs = map[kernelrelease.Architecture]crossCompileArch{
+	kernelrelease.ArchitectureAmd64:   {kernelArch: "x86_64", prefix: "x86_64-linux-gnu-"},
+	kernelrelease.ArchitectureArm64:   {kernelArch: "arm64", prefix: "aarch64-linux-gnu-"},
+	kernelrelease.ArchitectureS390x:   {kernelArch: "s390", prefix: "s390x-linux-gnu-"},
+	kernelrelease.ArchitecturePpc64le: {kernelArch: "powerpc", prefix: "powerpc64le-linux-gnu-"},
+}
+
+// hostArch returns the architecture driverkit runs on,
+// falling back at the build one when it is unknown or not supported.
+func (b *Build) hostArch() kernelrelease.Architecture {
+	host := kernelrelease.Architecture(b.HostArchitecture)
+	if _, ok := kernelrelease.SupportedArchs[host]; !ok {
+		return kernelrelease.Architecture(b.Architecture)
+	}
+	return host
+}
+
+// isForeignArch returns true when building for an architecture other than the host one.
+func (b *Build) isForeignArch() bool {
+	return b.hostArch().String() != b.Architecture
+}
+
+// setCrossCompile decides whether to cross-compile,
+// given whether a cross-compilation builder image is available.
+func (b *Build) setCrossCompile(available bool) {
+	b.CrossCompile = false
+	if !b.isForeignArch() {
+		return
+	}
+	switch b.CrossBuildMode {
+	case CrossBuildCompile:
+		b.CrossCompile = true
+	case CrossBuildAuto:
+		b.CrossCompile = available
+	}
+}
+
+// crossCompilePrefix returns the CROSS_COMPILE prefix of the toolchain, empty when not cross-compiling.
+func (b *Build) crossCompilePrefix() string {
+	if !b.CrossCompile {
+		return ""
+	}
+	return crossCompileArchs[kernelrelease.Architecture(b.Architecture)].prefix
+}
+
+// CrossCompileEnv returns the environment variables needed by kbuild to cross-compile, if any.
+func (b *Build) CrossCompileEnv() []string {
+	if !b.CrossCompile {
+		return nil
+	}
+	cross := crossCompileArchs[kernelrelease.Architecture(b.Architecture)]
+	return []string{
+		fmt.Sprintf("ARCH=%s", cross.kernelArch),
+		fmt.Sprintf("CROSS_COMPILE=%s", cross.prefix),
+	}
+}
+
+// BuilderImageArchitecture returns the architecture of the builder image:
+// the host one when cross-compiling, the target one otherwise.
+func (b *Build) BuilderImageArchitecture() string {
+	if b.CrossCompile {
+		return b.hostArch().String()
+	}
+	return b.Architecture
+}
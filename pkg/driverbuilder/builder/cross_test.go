@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/docker/docker/testutil/registry"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+var crossImagesYAML = `
+images:
+  - name: foo/test:any-aarch64_gcc12.0.0-latest
+    target: any
+    arch: aarch64
+    tag: latest
+    gcc_versions:
+      - 12.0.0
+  - name: foo/test:any-x86_64_gcc12.0.0_cross-arm64-latest
+    target: any
+    arch: x86_64
+    tag: latest
+    gcc_versions:
+      - 12.0.0
+    cross_archs:
+      - arm64
+  - name: foo/test:any-x86_64_gcc13.0.0-latest
+    target: any
+    arch: x86_64
+    tag: latest
+    gcc_versions:
+      - 13.0.0
+`
+
+var crossImagesJSON = `
+{
+  "name": "foo/test",
+  "tags": [
+    "any-aarch64_gcc12.0.0-latest",
+    "any-x86_64_gcc12.0.0_cross-arm64-latest",
+    "any-x86_64_gcc13.0.0-latest",
+    "any-x86_64_gcc11.0.0_cross-s390x-latest"
+  ]
+}
+`
+
+var crossImagesExpected = []Image{
+	{
+		Target:     "any",
+		GCCVersion: semver.MustParse("12.0.0"),
+		Name:       "foo/test:any-aarch64_gcc12.0.0-latest",
+		Arch:       "aarch64",
+	},
+	{
+		Target:     "any",
+		GCCVersion: semver.MustParse("12.0.0"),
+		Name:       "foo/test:any-x86_64_gcc12.0.0_cross-arm64-latest",
+		Arch:       "x86_64",
+		CrossArchs: []string{"arm64"},
+		Cross:      true,
+	},
+}
+
+func crossBuild() *Build {
+	return &Build{
+		TargetType:        Type("centos"),
+		Architecture:      "arm64",
+		HostArchitecture:  "amd64",
+		BuilderImage:      "auto:latest",
+		RegistryPlainHTTP: true,
+	}
+}
+
+func TestFileImagesListerCross(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	f, err := os.CreateTemp(t.TempDir(), "imagetest")
+	assert.NilError(t, err)
+	_, err = f.WriteString(crossImagesYAML)
+	assert.NilError(t, err)
+
+	lister, err := NewFileImagesLister(f.Name(), crossBuild())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+}
+
+func TestRepoImagesListerCross(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	mock, err := registry.NewMock(t)
+	assert.NilError(t, err)
+	defer mock.Close()
+
+	// The tags regex is lazily initialized for the first build: reset it
+	tagReg = nil
+	defer func() { tagReg = nil }()
+	lister, err := NewRepoImagesLister(mock.URL()+"/foo/test", crossBuild())
+	assert.NilError(t, err)
+
+	mock.RegisterHandler("/v2/foo/test/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(crossImagesJSON))
+	})
+	expected := make([]Image, len(crossImagesExpected))
+	for idx, img := range crossImagesExpected {
+		img.Name = mock.URL() + "/" + img.Name
+		expected[idx] = img
+	}
+	assert.DeepEqual(t, expected, lister.LoadImages(printer))
+}
+
+type staticImagesLister []Image
+
+func (s staticImagesLister) LoadImages(_ *output.Printer) []Image {
+	return s
+}
+
+func TestLoadImagesCrossBuildMode(t *testing.T) {
+	tests := map[string]struct {
+		mode             CrossBuildMode
+		hostArch         string
+		images           []Image
+		wantCrossCompile bool
+		wantImage        string
+	}{
+		"auto with cross image": {
+			mode:             CrossBuildAuto,
+			hostArch:         "amd64",
+			images:           crossImagesExpected,
+			wantCrossCompile: true,
+			wantImage:        "foo/test:any-x86_64_gcc12.0.0_cross-arm64-latest",
+		},
+		"auto without cross image": {
+			mode:      CrossBuildAuto,
+			hostArch:  "amd64",
+			images:    crossImagesExpected[:1],
+			wantImage: "foo/test:any-aarch64_gcc12.0.0-latest",
+		},
+		"qemu": {
+			mode:      CrossBuildQemu,
+			hostArch:  "amd64",
+			images:    crossImagesExpected,
+			wantImage: "foo/test:any-aarch64_gcc12.0.0-latest",
+		},
+		"cross-compile": {
+			mode:             CrossBuildCompile,
+			hostArch:         "amd64",
+			images:           crossImagesExpected,
+			wantCrossCompile: true,
+			wantImage:        "foo/test:any-x86_64_gcc12.0.0_cross-arm64-latest",
+		},
+		"cross-compile without cross image": {
+			mode:      CrossBuildCompile,
+			hostArch:  "amd64",
+			images:    crossImagesExpected[:1],
+			wantImage: "foo/test:any-aarch64_gcc12.0.0-latest",
+		},
+		"same arch": {
+			mode:      CrossBuildCompile,
+			hostArch:  "arm64",
+			images:    crossImagesExpected[:1],
+			wantImage: "foo/test:any-aarch64_gcc12.0.0-latest",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := crossBuild()
+			b.HostArchitecture = test.hostArch
+			b.CrossBuildMode = test.mode
+			b.GCCVersion = "12.0.0"
+			b.Images = make(ImagesMap)
+			b.ImagesListers = []ImagesLister{staticImagesLister(test.images)}
+			b.Printer = output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+			b.LoadImages()
+			assert.Equal(t, b.CrossCompile, test.wantCrossCompile)
+			assert.Equal(t, b.GetBuilderImage(), test.wantImage)
+		})
+	}
+}
+
+func TestCrossCompileEnv(t *testing.T) {
+	b := crossBuild()
+	assert.Assert(t, b.CrossCompileEnv() == nil)
+	assert.Equal(t, b.BuilderImageArchitecture(), "arm64")
+
+	b.CrossCompile = true
+	assert.DeepEqual(t, b.CrossCompileEnv(), []string{"ARCH=arm64", "CROSS_COMPILE=aarch64-linux-gnu-"})
+	assert.Equal(t, b.BuilderImageArchitecture(), "amd64")
+}
@@ -76,7 +76,7 @@ func (v *debian) KernelTemplateData(kr kernelrelease.KernelRelease, urls []strin
 	} else if strings.Contains(kr.FullExtraversion, "rpi") {
 		KernelHeadersPattern = "linux-headers-*-rpi-v*"
 	} else {
-		KernelHeadersPattern = "linux-headers-*" + kr.Architecture.String()
+		KernelHeadersPattern = "linux-headers-*" + debianFlavorArch(kr.Architecture)
 	}
 
 	return debianTemplateData{
@@ -124,8 +124,8 @@ func debianHeadersURLFromRelease(kr kernelrelease.KernelRelease) ([]string, erro
 }
 
 func fetchDebianHeadersURLFromRelease(baseURL string, kr kernelrelease.KernelRelease) ([]string, error) {
-	extraVersionPartial := strings.TrimSuffix(kr.FullExtraversion, "-"+kr.Architecture.String())
-	matchExtraGroup := kr.Architecture.String()
+	extraVersionPartial := strings.TrimSuffix(kr.FullExtraversion, "-"+debianFlavorArch(kr.Architecture))
+	matchExtraGroup := debianFlavorArch(kr.Architecture)
 	rmatch := `href="(linux-headers-%d\.%d\.%d%s-(%s)_.*(%s|all)\.deb)"`
 
 	// For urls like: http://security.debian.org/pool/updates/main/l/linux/linux-headers-5.10.0-12-amd64_5.10.103-1_amd64.deb
@@ -161,14 +161,20 @@ func fetchDebianHeadersURLFromRelease(baseURL string, kr kernelrelease.KernelRel
 	}
 	bodyStr := string(body)
 
+	// when the package version is known (eg: "6.1.55-1" from `uname -v`),
+	// look for the exact packages instead of the first matching ones
+	if kr.PackageVersion != "" {
+		return fetchDebianExactHeadersURLs(baseURL, bodyStr, kr, extraVersionPartial, matchExtraGroup, matchExtraGroupCommon)
+	}
+
 	// look for kernel headers
 	fullregex := fmt.Sprintf(rmatch, kr.Major, kr.Minor, kr.Patch,
-		extraVersionPartial, matchExtraGroup, kr.Architecture.String())
+		extraVersionPartial, matchExtraGroup, kr.Architecture.ToDeb())
 	pattern := regexp.MustCompile(fullregex)
 	matches := pattern.FindStringSubmatch(bodyStr)
 	if len(matches) < 1 {
 		fullregex = fmt.Sprintf(rmatchNew, matchExtraGroup, kr.Major, kr.Minor, kr.Patch,
-			extraVersionPartial, kr.Architecture.String())
+			extraVersionPartial, kr.Architecture.ToDeb())
 		pattern = regexp.MustCompile(fullregex)
 		matches = pattern.FindStringSubmatch(bodyStr)
 		if len(matches) < 1 {
@@ -178,12 +184,12 @@ func fetchDebianHeadersURLFromRelease(baseURL string, kr kernelrelease.KernelRel
 
 	// look for kernel headers common
 	fullregexCommon := fmt.Sprintf(rmatch, kr.Major, kr.Minor, kr.Patch,
-		extraVersionPartial, matchExtraGroupCommon, kr.Architecture.String())
+		extraVersionPartial, matchExtraGroupCommon, kr.Architecture.ToDeb())
 	patternCommon := regexp.MustCompile(fullregexCommon)
 	matchesCommon := patternCommon.FindStringSubmatch(bodyStr)
 	if len(matchesCommon) < 1 {
 		fullregexCommon = fmt.Sprintf(rmatchNew, matchExtraGroupCommon, kr.Major, kr.Minor, kr.Patch,
-			extraVersionPartial, kr.Architecture.String())
+			extraVersionPartial, kr.Architecture.ToDeb())
 		patternCommon = regexp.MustCompile(fullregexCommon)
 		matchesCommon = patternCommon.FindStringSubmatch(bodyStr)
 		if len(matchesCommon) < 1 {
@@ -197,10 +203,39 @@ func fetchDebianHeadersURLFromRelease(baseURL string, kr kernelrelease.KernelRel
 	return foundURLs, nil
 }
 
+func fetchDebianExactHeadersURLs(baseURL, body string, kr kernelrelease.KernelRelease, extraVersionPartial, matchExtraGroup, matchExtraGroupCommon string) ([]string, error) {
+	rmatch := `href="(linux-headers-%d\.%d\.%d%s-(%s)_%s_(%s|all)\.deb)"`
+	packageVersion := regexp.QuoteMeta(kr.PackageVersion)
+
+	pattern := regexp.MustCompile(fmt.Sprintf(rmatch, kr.Major, kr.Minor, kr.Patch,
+		regexp.QuoteMeta(extraVersionPartial), matchExtraGroup, packageVersion, kr.Architecture.ToDeb()))
+	matches := pattern.FindStringSubmatch(body)
+	if len(matches) < 1 {
+		return nil, fmt.Errorf("kernel headers %s not found", kr.PackageVersion)
+	}
+
+	patternCommon := regexp.MustCompile(fmt.Sprintf(rmatch, kr.Major, kr.Minor, kr.Patch,
+		regexp.QuoteMeta(extraVersionPartial), matchExtraGroupCommon, packageVersion, kr.Architecture.ToDeb()))
+	matchesCommon := patternCommon.FindStringSubmatch(body)
+	if len(matchesCommon) < 1 {
+		return nil, fmt.Errorf("kernel headers common %s not found", kr.PackageVersion)
+	}
+
+	return []string{
+		fmt.Sprintf("%s%s", baseURL, matches[1]),
+		fmt.Sprintf("%s%s", baseURL, matchesCommon[1]),
+	}, nil
+}
+
 func debianKbuildURLFromRelease(kr kernelrelease.KernelRelease) (string, error) {
 	rmatch := `href="(linux-kbuild-%d\.%d.*%s\.deb)"`
 
-	kbuildPattern := regexp.MustCompile(fmt.Sprintf(rmatch, kr.Major, kr.Minor, kr.Architecture.String()))
+	kbuildPattern := regexp.MustCompile(fmt.Sprintf(rmatch, kr.Major, kr.Minor, kr.Architecture.ToDeb()))
+	if kr.PackageVersion != "" {
+		// eg: linux-kbuild-6.1_6.1.55-1_amd64.deb
+		rmatch = `href="(linux-kbuild-%d\.%d_%s_%s\.deb)"`
+		kbuildPattern = regexp.MustCompile(fmt.Sprintf(rmatch, kr.Major, kr.Minor, regexp.QuoteMeta(kr.PackageVersion), kr.Architecture.ToDeb()))
+	}
 	baseURL := "http://mirrors.kernel.org/debian/pool/main/l/linux/"
 	if kr.Major == 3 {
 		baseURL = "http://mirrors.kernel.org/debian/pool/main/l/linux-tools/"
@@ -223,3 +258,24 @@ func debianKbuildURLFromRelease(kr kernelrelease.KernelRelease) (string, error)
 
 	return fmt.Sprintf("%s%s", baseURL, match[1]), nil
 }
+
+// debianFlavorArchs holds the architecture part of the Debian kernel flavors
+// that differs from the Go architecture, eg: "6.1.0-13-powerpc64le".
+var debianFlavorArchs = map[kernelrelease.Architecture]string{
+	kernelrelease.ArchitecturePpc64le: "powerpc64le",
+}
+
+func debianFlavorArch(arch kernelrelease.Architecture) string {
+	if flavorArch, ok := debianFlavorArchs[arch]; ok {
+		return flavorArch
+	}
+	return arch.String()
+}
+
+// Matches eg: "6.1.0-13-amd64", "6.1.0-13-cloud-amd64", "4.19.0-0.bpo.6-amd64", "6.1.0-13-powerpc64le",
+// Raspberry Pi ("6.1.0-rpi7-rpi-v8") and Proxmox ("6.5.11-8-pve") kernels.
+var debianReleaseRegex = regexp.MustCompile(`^-(\d+(\.bpo\.\d+)?(-(cloud|rt))?-(amd64|arm64|s390x|powerpc64le)|rpi\d+-rpi-v\d+|\d+-pve)$`)
+
+func (v *debian) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return debianReleaseRegex.MatchString(kr.FullExtraversion)
+}
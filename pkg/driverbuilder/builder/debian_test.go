@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+func TestFetchDebianHeadersURLFromRelease(t *testing.T) {
+	index := ""
+	for _, pkg := range []string{
+		"linux-headers-6.1.0-13-amd64_6.1.52-1_amd64.deb",
+		"linux-headers-6.1.0-13-common_6.1.52-1_all.deb",
+		"linux-headers-6.1.0-13-amd64_6.1.55-1_amd64.deb",
+		"linux-headers-6.1.0-13-common_6.1.55-1_all.deb",
+		"linux-headers-6.1.0-13-cloud-amd64_6.1.55-1_amd64.deb",
+	} {
+		index += fmt.Sprintf("<a href=\"%s\">%s</a>\n", pkg, pkg)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(index))
+	}))
+	defer server.Close()
+	baseURL := server.URL + "/"
+
+	kr := kernelrelease.FromString("6.1.0-13-amd64")
+	kr.Architecture = kernelrelease.ArchitectureAmd64
+
+	// Without the package version, the first matching packages are picked
+	urls, err := fetchDebianHeadersURLFromRelease(baseURL, kr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		baseURL + "linux-headers-6.1.0-13-amd64_6.1.52-1_amd64.deb",
+		baseURL + "linux-headers-6.1.0-13-common_6.1.52-1_all.deb",
+	})
+
+	// The package version from `uname -v` picks the exact packages
+	kr.PackageVersion = "6.1.55-1"
+	urls, err = fetchDebianHeadersURLFromRelease(baseURL, kr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		baseURL + "linux-headers-6.1.0-13-amd64_6.1.55-1_amd64.deb",
+		baseURL + "linux-headers-6.1.0-13-common_6.1.55-1_all.deb",
+	})
+
+	// An exact package missing from the index is not replaced by another version
+	kr.PackageVersion = "6.1.58-1"
+	_, err = fetchDebianHeadersURLFromRelease(baseURL, kr)
+	assert.Error(t, err, "kernel headers 6.1.58-1 not found")
+
+	// Extra flavors pick their own exact package, along with the common one
+	kr = kernelrelease.FromString("6.1.0-13-cloud-amd64")
+	kr.Architecture = kernelrelease.ArchitectureAmd64
+	kr.PackageVersion = "6.1.55-1"
+	urls, err = fetchDebianHeadersURLFromRelease(baseURL, kr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		baseURL + "linux-headers-6.1.0-13-cloud-amd64_6.1.55-1_amd64.deb",
+		baseURL + "linux-headers-6.1.0-13-common_6.1.55-1_all.deb",
+	})
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+//go:embed templates/builder_gcc.Dockerfile
+var builderGCCDockerfileTemplate string
+
+// builderDockerfileReg matches the builder images Dockerfiles names, see docs/builder_images.md.
+var builderDockerfileReg = regexp.MustCompile("^builder-(?P<name>(?P<target>[a-z0-9]+)-(?P<arch>" + strings.Join(nonDebArchs(), "|") + ")(?P<gccVers>(_gcc[0-9]+.[0-9]+.[0-9]+)+)(?P<clangVers>(_clang[0-9]+.[0-9]+.[0-9]+)*)(?P<crossArchs>(_cross-[a-z0-9]+)*))\\.Dockerfile$")
+
+// BuilderDockerfile is a builder image Dockerfile, whose name states the image capabilities,
+// eg: builder-any-x86_64_gcc12.0.0_gcc11.0.0.Dockerfile.
+type BuilderDockerfile struct {
+	Path string
+	// Name is the image tag, without the versioned tag suffix, eg: any-x86_64_gcc12.0.0_gcc11.0.0.
+	Name string
+	// Images are the images provided by the Dockerfile, one for each gcc version.
+	Images []Image
+}
+
+// LoadBuilderDockerfiles loads the builder images Dockerfiles under dir, skipping the unrelated files.
+func LoadBuilderDockerfiles(dir string) ([]BuilderDockerfile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []BuilderDockerfile
+	for _, entry := range entries {
+		if d, ok := parseBuilderDockerfile(filepath.Join(dir, entry.Name())); ok && !entry.IsDir() {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+// LoadBuilderDockerfile loads the builder image Dockerfile at path, whose name must follow the naming scheme.
+func LoadBuilderDockerfile(path string) (BuilderDockerfile, error) {
+	if _, err := os.Stat(path); err != nil {
+		return BuilderDockerfile{}, err
+	}
+	d, ok := parseBuilderDockerfile(path)
+	if !ok {
+		return BuilderDockerfile{}, fmt.Errorf("%s does not follow the builder Dockerfiles naming scheme", path)
+	}
+	return d, nil
+}
+
+func parseBuilderDockerfile(path string) (BuilderDockerfile, bool) {
+	match := builderDockerfileReg.FindStringSubmatch(filepath.Base(path))
+	if len(match) == 0 {
+		return BuilderDockerfile{}, false
+	}
+	name := match[builderDockerfileReg.SubexpIndex("name")]
+	caps := tagCapabilities(builderDockerfileReg, match)
+	return BuilderDockerfile{
+		Path:   path,
+		Name:   name,
+		Images: capabilitiesImages(name, []imageCapabilities{caps}, "", "", "", ""),
+	}, true
+}
+
+// Arch returns the architecture the Dockerfile image runs on.
+func (d BuilderDockerfile) Arch() kernelrelease.Architecture {
+	if len(d.Images) == 0 {
+		return ""
+	}
+	return d.Images[0].Architecture()
+}
+
+// ImageRef returns the image reference for the Dockerfile, following the tags naming scheme.
+func (d BuilderDockerfile) ImageRef(repo, tag string) string {
+	return fmt.Sprintf("%s:%s-%s", repo, d.Name, tag)
+}
+
+// GenerateGCCDockerfile writes under dir the Dockerfile of an "any" target image providing gcc,
+// based on the official gcc image for arch.
+func GenerateGCCDockerfile(dir string, gcc semver.Version, arch kernelrelease.Architecture) (BuilderDockerfile, error) {
+	// gcc images are only tagged by major for the latest release, eg: gcc:13,
+	// that is what driverkit maps to 13.0.0.
+	baseImageTag := gcc.String()
+	if gcc.Minor == 0 && gcc.Patch == 0 {
+		baseImageTag = fmt.Sprintf("%d", gcc.Major)
+	}
+
+	t, err := template.New("builder").Parse(builderGCCDockerfileTemplate)
+	if err != nil {
+		return BuilderDockerfile{}, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("builder-any-%s_gcc%s.Dockerfile", arch.ToNonDeb(), gcc.String()))
+	f, err := os.Create(path)
+	if err != nil {
+		return BuilderDockerfile{}, err
+	}
+	defer f.Close()
+	if err = t.Execute(f, map[string]string{
+		"GCCVersion":   gcc.String(),
+		"BaseImageTag": baseImageTag,
+	}); err != nil {
+		return BuilderDockerfile{}, err
+	}
+
+	d, _ := parseBuilderDockerfile(path)
+	return d, nil
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"gotest.tools/assert"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+func TestLoadBuilderDockerfiles(t *testing.T) {
+	dockerfiles, err := LoadBuilderDockerfiles("../../../docker/builders")
+	assert.NilError(t, err)
+	assert.Assert(t, len(dockerfiles) > 0)
+
+	var found bool
+	for _, d := range dockerfiles {
+		assert.Assert(t, strings.HasPrefix(filepath.Base(d.Path), "builder-"+d.Name))
+		assert.Assert(t, len(d.Images) > 0)
+		if d.Name != "any-x86_64_gcc12.0.0_gcc11.0.0_cross-arm64" {
+			continue
+		}
+		found = true
+		assert.Equal(t, d.Arch(), kernelrelease.Architecture("amd64"))
+		assert.Equal(t, d.ImageRef("docker.io/falcosecurity/driverkit-builder", "latest"),
+			"docker.io/falcosecurity/driverkit-builder:any-x86_64_gcc12.0.0_gcc11.0.0_cross-arm64-latest")
+		assert.DeepEqual(t, d.Images, []Image{
+			{
+				Target:     "any",
+				Arch:       "x86_64",
+				CrossArchs: []string{"arm64"},
+				GCCVersion: semver.MustParse("12.0.0"),
+				Name:       d.Name,
+			},
+			{
+				Target:     "any",
+				Arch:       "x86_64",
+				CrossArchs: []string{"arm64"},
+				GCCVersion: semver.MustParse("11.0.0"),
+				Name:       d.Name,
+			},
+		})
+	}
+	assert.Assert(t, found)
+
+	// Dockerfiles not following the naming scheme are refused
+	path := filepath.Join(t.TempDir(), "builder-x86_64.Dockerfile")
+	assert.NilError(t, os.WriteFile(path, []byte("FROM debian:bookworm\n"), 0644))
+	_, err = LoadBuilderDockerfile(path)
+	assert.ErrorContains(t, err, "naming scheme")
+}
+
+func TestGenerateGCCDockerfile(t *testing.T) {
+	tests := map[string]struct {
+		gcc          string
+		arch         kernelrelease.Architecture
+		expectedName string
+		expectedFrom string
+	}{
+		"major only": {
+			gcc:          "13.0.0",
+			arch:         "amd64",
+			expectedName: "any-x86_64_gcc13.0.0",
+			expectedFrom: "FROM gcc:13\n",
+		},
+		"full version": {
+			gcc:          "13.2.0",
+			arch:         "arm64",
+			expectedName: "any-aarch64_gcc13.2.0",
+			expectedFrom: "FROM gcc:13.2.0\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			d, err := GenerateGCCDockerfile(dir, semver.MustParse(test.gcc), test.arch)
+			assert.NilError(t, err)
+			assert.Equal(t, d.Name, test.expectedName)
+			assert.Equal(t, d.Arch(), test.arch)
+			assert.Equal(t, len(d.Images), 1)
+			assert.Equal(t, d.Images[0].GCCVersion.String(), test.gcc)
+
+			data, err := os.ReadFile(d.Path)
+			assert.NilError(t, err)
+			assert.Assert(t, strings.Contains(string(data), test.expectedFrom))
+			assert.Assert(t, strings.Contains(string(data), "/usr/bin/gcc-"+test.gcc))
+
+			loaded, err := LoadBuilderDockerfile(d.Path)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, loaded, d)
+		})
+	}
+}
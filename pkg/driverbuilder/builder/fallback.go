@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"slices"
+
+	"github.com/blang/semver/v4"
+)
+
+// GCCFallbacks returns the gcc versions to retry the kernel module build with,
+// in order, once the selected one failed: the ones provided by the loaded builder images
+// that are nearest lower than the selected one first, then the higher ones.
+// It returns nothing when the gcc version cannot change, that is,
+// when it was forced by user, or by the exact image policy, a custom builder image is used, or the kernel module is built with clang.
+func (b *Build) GCCFallbacks() []semver.Version {
+	if !b.gccAutoSelected || b.imagePolicy() == ImagePolicyExact || b.hasCustomBuilderImage() ||
+		b.Compiler == CompilerClang || len(b.ModuleFilePath) == 0 {
+		return nil
+	}
+
+	selected := mustParseTolerant(b.GCCVersion)
+	var lower, higher []semver.Version
+	for _, img := range b.Images {
+		switch {
+		case img.GCCVersion.EQ(selected):
+		case img.GCCVersion.LT(selected):
+			if !slices.ContainsFunc(lower, img.GCCVersion.EQ) {
+				lower = append(lower, img.GCCVersion)
+			}
+		default:
+			if !slices.ContainsFunc(higher, img.GCCVersion.EQ) {
+				higher = append(higher, img.GCCVersion)
+			}
+		}
+	}
+	semver.Sort(lower)
+	slices.Reverse(lower)
+	semver.Sort(higher)
+	return append(lower, higher...)
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"gotest.tools/assert"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+func TestGCCFallbacks(t *testing.T) {
+	kr := kernelrelease.FromString("6.1.0-generic")
+	ubuntuBuilder, err := Factory(TargetTypeUbuntu)
+	assert.NilError(t, err)
+
+	b := compilerTestBuild("CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=110400\n")
+	b.Images["ubuntu_11.0.0"] = Image{
+		Target:     TargetTypeUbuntu,
+		GCCVersion: semver.MustParse("11.0.0"),
+		Name:       "foo/test:ubuntu-x86_64_gcc11.0.0-latest",
+	}
+	b.Images["any_13.0.0"] = Image{
+		Target:     "any",
+		GCCVersion: semver.MustParse("13.0.0"),
+		Name:       "foo/test:any-x86_64_gcc13.0.0-latest",
+	}
+	assert.NilError(t, b.setGCCVersion(ubuntuBuilder, kr))
+	assert.Equal(t, b.GCCVersion, "11.0.0")
+
+	// nearest lower first, then higher ones, without duplicates
+	assert.DeepEqual(t, b.GCCFallbacks(), []semver.Version{
+		semver.MustParse("8.0.0"),
+		semver.MustParse("12.0.0"),
+		semver.MustParse("13.0.0"),
+	})
+
+	// forced by user
+	b = compilerTestBuild("no-data")
+	b.GCCVersion = "12.0.0"
+	assert.NilError(t, b.setGCCVersion(ubuntuBuilder, kr))
+	assert.Assert(t, b.GCCFallbacks() == nil)
+
+	// clang-built kernel
+	b = compilerTestBuild("CONFIG_CC_IS_CLANG=y\nCONFIG_CLANG_VERSION=150007\n")
+	assert.NilError(t, b.setGCCVersion(ubuntuBuilder, kr))
+	b.setCompiler(kr)
+	assert.Assert(t, b.GCCFallbacks() == nil)
+}
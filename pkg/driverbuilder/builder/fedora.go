@@ -17,7 +17,7 @@ package builder
 import (
 	_ "embed"
 	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 )
@@ -54,48 +54,44 @@ func (c *fedora) TemplateScript() string {
 }
 
 func (c *fedora) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
-
 	// fedora FullExtraversion looks like "-200.fc36.x86_64"
-	// need to get the "fc36" out of the middle
-	fedoraVersion := strings.Split(kr.FullExtraversion, ".")[1]
+	// need to get the "36" out of the middle
+	if _, ok := kr.Fedora(); !ok {
+		return nil, fmt.Errorf("unable to find fedora release in kernel release %s", kr.String())
+	}
+	return fetchRPMKernelURLs(c, kr)
+}
+
+func (c *fedora) rpmPackages(_ kernelrelease.KernelRelease) []string {
+	return []string{"kernel-devel"}
+}
 
-	// trim off the "fc" from fedoraVersion
-	version := strings.Trim(fedoraVersion, "fc")
+func (c *fedora) rpmRepos(kr kernelrelease.KernelRelease) []string {
+	release, _ := kr.Fedora()
+	version := strconv.FormatUint(release, 10)
 
-	// template the kernel info into all possible URL strings
-	urls := []string{
+	return []string{
 		fmt.Sprintf( // updates
-			"https://mirrors.kernel.org/fedora/updates/%s/Everything/%s/Packages/k/kernel-devel-%s%s.rpm",
+			"https://mirrors.kernel.org/fedora/updates/%s/Everything/%s",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 		fmt.Sprintf( // releases
-			"https://mirrors.kernel.org/fedora/releases/%s/Everything/%s/os/Packages/k/kernel-devel-%s%s.rpm",
+			"https://mirrors.kernel.org/fedora/releases/%s/Everything/%s/os",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 		fmt.Sprintf( // development
-			"https://mirrors.kernel.org/fedora/development/%s/Everything/%s/os/Packages/k/kernel-devel-%s%s.rpm",
+			"https://mirrors.kernel.org/fedora/development/%s/Everything/%s/os",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 		fmt.Sprintf( // updates-archive
-			"https://fedoraproject-updates-archive.fedoraproject.org/fedora/%s/%s/kernel-devel-%s%s.rpm",
+			"https://fedoraproject-updates-archive.fedoraproject.org/fedora/%s/%s",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 	}
-
-	// return out all possible urls
-	return urls, nil
 }
 
 func (c *fedora) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -103,3 +99,8 @@ func (c *fedora) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string
 		KernelDownloadURL: urls[0],
 	}
 }
+
+func (c *fedora) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	_, ok := kr.Fedora()
+	return ok
+}
@@ -63,7 +63,7 @@ func (f *flatcar) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
 	if err := f.fillFlatcarInfos(kr); err != nil {
 		return nil, err
 	}
-	return fetchFlatcarKernelURLS(f.info.KernelVersion), nil
+	return fetchFlatcarKernelURLS(f.info.KernelVersion)
 }
 
 func (f *flatcar) KernelTemplateData(kr kernelrelease.KernelRelease, urls []string) interface{} {
@@ -99,9 +99,12 @@ func (f *flatcar) fillFlatcarInfos(kr kernelrelease.KernelRelease) error {
 	return err
 }
 
-func fetchFlatcarKernelURLS(kernelVersion string) []string {
-	kv := kernelrelease.FromString(kernelVersion)
-	return []string{fetchVanillaKernelURLFromKernelVersion(kv)}
+func fetchFlatcarKernelURLS(kernelVersion string) ([]string, error) {
+	kv, err := kernelrelease.Parse(kernelVersion)
+	if err != nil {
+		return nil, err
+	}
+	return []string{fetchVanillaKernelURLFromKernelVersion(kv)}, nil
 }
 
 func fetchFlatcarMetadata(kr kernelrelease.KernelRelease) (*flatcarReleaseInfo, error) {
@@ -170,3 +173,8 @@ type flatcarReleaseInfo struct {
 	GCCVersion    semver.Version
 	KernelVersion string
 }
+
+// Flatcar kernel releases are the flatcar release versions, eg: "3510.2.0".
+func (f *flatcar) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return kr.FullExtraversion == "" && kr.Major >= 1500
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// GCCRule tells the gcc version to build the kernels of a target, in a kernel versions range, with;
+// an empty Target or Kernel matches any target or kernel.
+type GCCRule struct {
+	Target string
+	Kernel string
+	GCC    string
+
+	kernelRange semver.Range
+	gcc         semver.Version
+}
+
+// ParseGCCRule parses a gcc rule, as [<target>:]<kernel range>=<gcc>, eg: debian:>=6.1 <6.2=12;
+// the kernel range is a semver range, see semver.ParseRange, whose versions can omit the minor and patch ones.
+func ParseGCCRule(rule string) (GCCRule, error) {
+	idx := strings.LastIndex(rule, "=")
+	if idx == -1 {
+		return GCCRule{}, fmt.Errorf("gcc rule %q is not in the <target>:<kernel range>=<gcc> format", rule)
+	}
+	r := GCCRule{Kernel: rule[:idx], GCC: rule[idx+1:]}
+	if target, kernel, found := strings.Cut(r.Kernel, ":"); found {
+		r.Target, r.Kernel = target, kernel
+	}
+	return r, r.parse()
+}
+
+// parse validates the rule, parsing its kernel range and gcc version.
+func (r *GCCRule) parse() error {
+	if r.Target != "" {
+		if _, err := Factory(Type(r.Target)); err != nil {
+			return fmt.Errorf("gcc rule %q: %w", r.String(), err)
+		}
+	}
+	r.kernelRange = func(semver.Version) bool { return true }
+	if kernel := strings.TrimSpace(r.Kernel); kernel != "" {
+		kernelRange, err := semver.ParseRange(expandRangeVersions(kernel))
+		if err != nil {
+			return fmt.Errorf("gcc rule %q: %w", r.String(), err)
+		}
+		r.kernelRange = kernelRange
+	}
+	gcc, err := semver.ParseTolerant(r.GCC)
+	if err != nil {
+		return fmt.Errorf("gcc rule %q: %w", r.String(), err)
+	}
+	r.gcc = gcc
+	return nil
+}
+
+// String returns the rule as [<target>:]<kernel range>=<gcc>.
+func (r GCCRule) String() string {
+	if r.Target == "" {
+		return r.Kernel + "=" + r.GCC
+	}
+	return r.Target + ":" + r.Kernel + "=" + r.GCC
+}
+
+// matches tells whether the rule applies to the target and kernel release;
+// "ubuntu" rules apply to any ubuntu flavor too.
+func (r GCCRule) matches(target Type, kr kernelrelease.KernelRelease) bool {
+	if r.Target != "" && r.Target != target.String() &&
+		(r.Target != TargetTypeUbuntu.String() || !strings.HasPrefix(target.String(), "ubuntu")) {
+		return false
+	}
+	return r.kernelRange(semver.Version{Major: kr.Major, Minor: kr.Minor, Patch: kr.Patch})
+}
+
+// expandRangeVersions expands the versions of a semver range omitting the minor or patch ones,
+// eg: ">=6.1 <6.2" becomes ">=6.1.0 <6.2.0".
+func expandRangeVersions(r string) string {
+	fields := strings.Fields(r)
+	for i, field := range fields {
+		version := strings.TrimLeft(field, "<>=!")
+		parts := strings.Split(version, ".")
+		if version == "" || len(parts) >= 3 || strings.Trim(version, "0123456789.") != "" {
+			continue
+		}
+		fields[i] = field + strings.Repeat(".0", 3-len(parts))
+	}
+	return strings.Join(fields, " ")
+}
+
+// ruleGCCVersion returns the gcc version stated by the first GCCRules matching the build, if any.
+func (b *Build) ruleGCCVersion(kr kernelrelease.KernelRelease) (semver.Version, bool) {
+	for _, rule := range b.GCCRules {
+		if rule.kernelRange == nil {
+			// rules are parsed by ParseGCCRule, unless directly set
+			if err := rule.parse(); err != nil {
+				b.Logger.Warn("skipping gcc rule", b.Logger.Args("err", err.Error()))
+				continue
+			}
+		}
+		if rule.matches(b.TargetType, kr) {
+			b.Logger.Debug("found gcc rule",
+				b.Logger.Args("rule", rule.String(), "kernelrelease", kr.Fullversion))
+			return rule.gcc, true
+		}
+	}
+	return semver.Version{}, false
+}
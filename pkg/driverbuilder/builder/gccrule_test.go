@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+	"gotest.tools/assert"
+)
+
+func TestParseGCCRule(t *testing.T) {
+	tests := map[string]struct {
+		expected GCCRule
+		err      bool
+	}{
+		"debian:>=6.1 <6.2=12": {expected: GCCRule{Target: "debian", Kernel: ">=6.1 <6.2", GCC: "12"}},
+		"<4.0.0 || >=6.1.0=8":  {expected: GCCRule{Kernel: "<4.0.0 || >=6.1.0", GCC: "8"}},
+		"centos:=4.8":          {expected: GCCRule{Target: "centos", GCC: "4.8"}},
+		"debian:>=6.1":         {err: true},
+		"nope:>=6.1=12":        {err: true},
+		"debian:6.1-foo=12":    {err: true},
+		"debian:>=6.1=foo":     {err: true},
+	}
+	for rule, test := range tests {
+		t.Run(rule, func(t *testing.T) {
+			r, err := ParseGCCRule(rule)
+			if test.err {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, r.Target, test.expected.Target)
+			assert.Equal(t, r.Kernel, test.expected.Kernel)
+			assert.Equal(t, r.GCC, test.expected.GCC)
+			assert.Equal(t, r.String(), rule)
+		})
+	}
+}
+
+func TestSetGCCVersionFromRules(t *testing.T) {
+	ubuntuBuilder, err := Factory(TargetTypeUbuntu)
+	assert.NilError(t, err)
+
+	rules := []GCCRule{
+		{Target: "debian", Kernel: ">=6.1 <6.2", GCC: "8"},
+		{Target: "ubuntu", Kernel: ">=6.1 <6.2", GCC: "11"},
+		{Kernel: ">=6.1", GCC: "8"},
+	}
+	tests := []struct {
+		kernelRelease string
+		kernelConfig  string
+		expected      string
+	}{
+		// defaultGCC for 6.1 is 12, but the first matching rule states 11
+		{kernelRelease: "6.1.0-generic", kernelConfig: "no-data", expected: "11.0.0"},
+		// only the rules without target match
+		{kernelRelease: "6.5.0-generic", kernelConfig: "no-data", expected: "8.0.0"},
+		// no rule matches, fallback at the heuristic
+		{kernelRelease: "5.15.0-generic", kernelConfig: "no-data", expected: "12.0.0"},
+		// rules take precedence over the kernel config data too
+		{kernelRelease: "6.1.0-generic", kernelConfig: "CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=120300\n", expected: "11.0.0"},
+		// no rule matches, the kernel config data takes precedence over the heuristic (nearest-lower to 10.2)
+		{kernelRelease: "5.15.0-generic", kernelConfig: "CONFIG_CC_IS_GCC=y\nCONFIG_GCC_VERSION=100200\n", expected: "8.0.0"},
+	}
+	for _, test := range tests {
+		t.Run(test.kernelRelease, func(t *testing.T) {
+			kr := kernelrelease.FromString(test.kernelRelease)
+			b := compilerTestBuild(test.kernelConfig)
+			b.GCCRules = rules
+			td, err := b.ToConfig().toTemplateData(ubuntuBuilder, kr)
+			assert.NilError(t, err)
+			assert.Equal(t, b.GCCVersion, test.expected)
+			assert.Equal(t, td.DetectGCC, test.expected == "12.0.0" && test.kernelConfig == "no-data")
+		})
+	}
+}
@@ -15,11 +15,13 @@ limitations under the License.
 package builder
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"github.com/falcosecurity/falcoctl/pkg/output"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/blang/semver/v4"
@@ -29,11 +31,13 @@ import (
 )
 
 type YAMLImage struct {
-	Target      string   `yaml:"target"`
-	GCCVersions []string `yaml:"gcc_versions"` // we expect images to internally link eg: gcc5 to gcc5.0.0
-	Name        string   `yaml:"name"`
-	Arch        string   `yaml:"arch"`
-	Tag         string   `yaml:"tag"`
+	Target        string   `yaml:"target"`
+	GCCVersions   []string `yaml:"gcc_versions"`             // we expect images to internally link eg: gcc5 to gcc5.0.0
+	ClangVersions []string `yaml:"clang_versions,omitempty"` // we expect images to internally link eg: clang-14 and llc-14 to clang-14.0.0 and llc-14.0.0
+	Name          string   `yaml:"name"`
+	Arch          string   `yaml:"arch"`
+	Tag           string   `yaml:"tag"`
+	CrossArchs    []string `yaml:"cross_archs,omitempty"` // architectures the image can cross-compile for, eg: arm64
 }
 
 type YAMLImagesList struct {
@@ -41,24 +45,51 @@ type YAMLImagesList struct {
 }
 
 type Image struct {
-	Target     Type
-	GCCVersion semver.Version // we expect images to internally link eg: gcc5 to gcc5.0.0
-	Name       string
+	Target        Type
+	GCCVersion    semver.Version   // we expect images to internally link eg: gcc5 to gcc5.0.0
+	ClangVersions []semver.Version // empty when the image only provides the default distro clang
+	Name          string
+	Arch          string   // non-deb architecture the image runs on, eg: x86_64
+	CrossArchs    []string // architectures the image can cross-compile for, eg: arm64
+	Cross         bool     // the image runs on the host architecture and cross-compiles for the build one
+}
+
+// Architecture returns the architecture the image runs on, empty when unknown.
+func (i Image) Architecture() kernelrelease.Architecture {
+	for arch, nonDeb := range kernelrelease.SupportedArchs {
+		if i.Arch == nonDeb {
+			return arch
+		}
+	}
+	return ""
 }
 
 type ImagesLister interface {
 	LoadImages(printer *output.Printer) []Image
 }
 
+// FileImagesLister loads images from a YAMLImagesList file.
+// Empty Arch and Target load the images of any architecture and target.
 type FileImagesLister struct {
-	FilePath string
-	Arch     string
-	Tag      string
-	Target   string
+	FilePath  string
+	Arch      string
+	HostArch  string
+	CrossArch string
+	Tag       string
+	Target    string
 }
 
+// RepoImagesLister loads images from the tags of an OCI repository,
+// or from the labels of the images whose tags do not follow the naming scheme.
+// Empty Arch and Target load the images of any architecture and target.
 type RepoImagesLister struct {
 	*repository.Repository
+	Arch      string
+	HostArch  string
+	CrossArch string
+	Tag       string
+	Target    string
+	Cache     *ImagesCache // nil lists the tags on each run
 }
 
 type ImageKey string
@@ -90,29 +121,41 @@ func (im ImagesMap) findImage(target Type, gccVers semver.Version) (Image, bool)
 }
 
 func NewFileImagesLister(filePath string, build *Build) (*FileImagesLister, error) {
+	if build.AllImages {
+		return &FileImagesLister{
+			FilePath: filePath,
+			Tag:      build.BuilderImageTag(),
+		}, nil
+	}
 	return &FileImagesLister{
-		FilePath: filePath,
-		Arch:     kernelrelease.Architecture(build.Architecture).ToNonDeb(),
-		Tag:      build.builderImageTag(),
-		Target:   build.TargetType.String(),
+		FilePath:  filePath,
+		Arch:      kernelrelease.Architecture(build.Architecture).ToNonDeb(),
+		HostArch:  build.hostArch().ToNonDeb(),
+		CrossArch: build.Architecture,
+		Tag:       build.BuilderImageTag(),
+		Target:    build.TargetType.String(),
 	}, nil
 }
 
 func (f *FileImagesLister) LoadImages(printer *output.Printer) []Image {
-	var (
-		res       []Image
-		imageList YAMLImagesList
-	)
-
 	// loop over lines in file to print them
 	fileData, err := os.ReadFile(f.FilePath)
 	if err != nil {
 		printer.Logger.Warn("error opening builder repo file",
 			printer.Logger.Args("err", err.Error(), "filepath", f.FilePath))
-		return res
+		return nil
 	}
+	return f.loadYAMLImages(printer, fileData)
+}
 
-	err = yaml.Unmarshal(fileData, &imageList)
+// loadYAMLImages loads the images of a YAMLImagesList index.
+func (f *FileImagesLister) loadYAMLImages(printer *output.Printer, fileData []byte) []Image {
+	var (
+		res       []Image
+		imageList YAMLImagesList
+	)
+
+	err := yaml.Unmarshal(fileData, &imageList)
 	if err != nil {
 		printer.Logger.Warn("error unmarshalling builder repo file",
 			printer.Logger.Args("err", err.Error(), "filepath", f.FilePath))
@@ -121,7 +164,8 @@ func (f *FileImagesLister) LoadImages(printer *output.Printer) []Image {
 
 	for _, image := range imageList.Images {
 		// Values checks
-		if image.Arch != f.Arch {
+		cross := f.Arch != "" && image.Arch != f.Arch && image.Arch == f.HostArch && slices.Contains(image.CrossArchs, f.CrossArch)
+		if f.Arch != "" && image.Arch != f.Arch && !cross {
 			printer.Logger.Debug("skipping wrong-arch image",
 				printer.Logger.Args("filepath", f.FilePath, "image", image))
 			continue
@@ -131,7 +175,7 @@ func (f *FileImagesLister) LoadImages(printer *output.Printer) []Image {
 				printer.Logger.Args("filepath", f.FilePath, "image", image))
 			continue
 		}
-		if image.Target != "any" && image.Target != f.Target {
+		if f.Target != "" && image.Target != "any" && image.Target != f.Target {
 			printer.Logger.Debug("skipping wrong-target image",
 				printer.Logger.Args("filepath", f.FilePath, "image", image))
 			continue
@@ -147,11 +191,20 @@ func (f *FileImagesLister) LoadImages(printer *output.Printer) []Image {
 			continue
 		}
 
+		var clangs []semver.Version
+		for _, clang := range image.ClangVersions {
+			clangs = append(clangs, mustParseTolerant(clang))
+		}
+
 		for _, gcc := range image.GCCVersions {
 			buildImage := Image{
-				Name:       image.Name,
-				Target:     Type(image.Target),
-				GCCVersion: mustParseTolerant(gcc),
+				Name:          image.Name,
+				Target:        Type(image.Target),
+				GCCVersion:    mustParseTolerant(gcc),
+				ClangVersions: clangs,
+				Arch:          image.Arch,
+				CrossArchs:    image.CrossArchs,
+				Cross:         cross,
 			}
 			res = append(res, buildImage)
 		}
@@ -159,15 +212,30 @@ func (f *FileImagesLister) LoadImages(printer *output.Printer) []Image {
 	return res
 }
 
-func NewRepoImagesLister(repo string, build *Build) (*RepoImagesLister, error) {
-	// Lazy inizialization
-	if tagReg == nil {
-		imageTag := build.builderImageTag()
-		// Create the proper regexes to load "any" and target-specific images for requested arch
-		arch := kernelrelease.Architecture(build.Architecture).ToNonDeb()
-		targetFmt := fmt.Sprintf("^(?P<target>%s|any)-%s(?P<gccVers>(_gcc[0-9]+.[0-9]+.[0-9]+)+)-%s$", build.TargetType.String(), arch, imageTag)
-		tagReg = regexp.MustCompile(targetFmt)
+// initTagReg lazily initializes tagReg, matching the tags following the naming scheme
+// of "any" and target-specific images for the requested arch,
+// as well as of the host arch ones able to cross-compile for it.
+func initTagReg(build *Build) {
+	if tagReg != nil {
+		return
+	}
+	imageTag := build.BuilderImageTag()
+	var target, arch, hostArch string
+	if build.AllImages {
+		target = "[a-z0-9]+"
+		arch = strings.Join(nonDebArchs(), "|")
+		hostArch = arch
+	} else {
+		target = build.TargetType.String()
+		arch = kernelrelease.Architecture(build.Architecture).ToNonDeb()
+		hostArch = build.hostArch().ToNonDeb()
 	}
+	targetFmt := fmt.Sprintf("^(?P<target>%s|any)-(?P<arch>%s|%s)(?P<gccVers>(_gcc[0-9]+.[0-9]+.[0-9]+)+)(?P<clangVers>(_clang[0-9]+.[0-9]+.[0-9]+)*)(?P<crossArchs>(_cross-[a-z0-9]+)*)-%s$", target, arch, hostArch, imageTag)
+	tagReg = regexp.MustCompile(targetFmt)
+}
+
+func NewRepoImagesLister(repo string, build *Build) (*RepoImagesLister, error) {
+	initTagReg(build)
 
 	// Get the registry URL from repository.
 	registry, err := getRegistryFromRef(repo)
@@ -181,77 +249,262 @@ func NewRepoImagesLister(repo string, build *Build) (*RepoImagesLister, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &RepoImagesLister{repoOCI}, nil
+	if build.AllImages {
+		return &RepoImagesLister{
+			Repository: repoOCI,
+			Tag:        build.BuilderImageTag(),
+			Cache:      build.ImagesCache,
+		}, nil
+	}
+	return &RepoImagesLister{
+		Repository: repoOCI,
+		Arch:       kernelrelease.Architecture(build.Architecture).ToNonDeb(),
+		HostArch:   build.hostArch().ToNonDeb(),
+		CrossArch:  build.Architecture,
+		Tag:        build.BuilderImageTag(),
+		Target:     build.TargetType.String(),
+		Cache:      build.ImagesCache,
+	}, nil
 }
 
 func (repo *RepoImagesLister) LoadImages(printer *output.Printer) []Image {
-	tags, err := repo.Tags(context.Background())
+	ctx := context.Background()
+	listing, err := repo.listing(ctx, printer)
 	if err != nil {
 		printer.Logger.Warn("skipping repo",
 			printer.Logger.Args("repo", repo.Reference, "err", err.Error()))
 		return nil
 	}
 
-	var res []Image
-	for _, t := range tags {
+	var (
+		res          []Image
+		labelsLoaded bool
+	)
+	for _, t := range listing.Tags {
 		img := fmt.Sprintf("%s:%s", repo.Reference, t)
-		match := tagReg.FindStringSubmatch(t)
-		if len(match) == 0 {
-			continue
-		}
 
-		var (
-			target  string
-			gccVers []string
-		)
-		for i, name := range tagReg.SubexpNames() {
-			if i > 0 && i <= len(match) {
-				switch name {
-				case "gccVers":
-					gccVers = strings.Split(match[i], "_gcc")
-					gccVers = gccVers[1:] // remove initial whitespace
-				case "target":
-					target = match[i]
+		var caps []imageCapabilities
+		if match := tagReg.FindStringSubmatch(t); len(match) > 0 {
+			caps = append(caps, tagCapabilities(tagReg, match))
+		} else if labeledTag(t, repo.Tag) {
+			labels, ok := listing.Labels[t]
+			if !ok {
+				if repo.Cache != nil && repo.Cache.Offline {
+					continue
+				}
+				labels, err = repo.fetchLabels(ctx, t)
+				if err != nil {
+					printer.Logger.Debug("skipping image with unreadable labels",
+						printer.Logger.Args("image", img, "err", err.Error()))
+					continue
+				}
+				if listing.Labels == nil {
+					listing.Labels = make(map[string]cachedLabels)
+				}
+				listing.Labels[t] = labels
+				labelsLoaded = true
+			}
+			for _, platform := range labels.Platforms {
+				if c, ok := platform.capabilities(); ok {
+					caps = append(caps, c)
 				}
 			}
 		}
 
+		res = append(res, capabilitiesImages(img, caps, repo.Target, repo.Arch, repo.HostArch, repo.CrossArch)...)
+	}
+
+	// Store the fetched labels, to avoid fetching the manifests again
+	if labelsLoaded && repo.Cache != nil {
+		if err = repo.Cache.store(listing); err != nil {
+			printer.Logger.Warn("error caching images labels",
+				printer.Logger.Args("repo", repo.Reference, "err", err.Error()))
+		}
+	}
+	return res
+}
+
+// capabilitiesImages returns the images provided by name for the given capabilities,
+// when suitable for target and arch; empty target and arch match any of them.
+func capabilitiesImages(name string, caps []imageCapabilities, target, arch, hostArch, crossArch string) []Image {
+	var res []Image
+	for _, c := range caps {
+		if target != "" && c.target != "any" && c.target != target {
+			continue
+		}
+
+		// Host arch images are only good when able to cross-compile for the requested arch
+		cross := arch != "" && c.arch != arch
+		if cross && (c.arch != hostArch || !slices.Contains(c.crossArchs, crossArch)) {
+			continue
+		}
+
 		// Note: we store "any" target images as "any",
 		// instead of adding them to the target,
 		// because we always prefer specific target images,
 		// and we cannot guarantee here that any subsequent docker repos
 		// does not provide a target-specific image that offers same gcc version
-		for _, gccVer := range gccVers {
-			// If user set a fixed gcc version, only load images that provide it.
-			buildImage := Image{
-				GCCVersion: mustParseTolerant(gccVer),
-				Name:       img,
-				Target:     Type(target),
-			}
-			res = append(res, buildImage)
+		for _, gccVer := range c.gccVers {
+			res = append(res, Image{
+				GCCVersion:    mustParseTolerant(gccVer),
+				ClangVersions: c.clangs,
+				Name:          name,
+				Target:        Type(c.target),
+				Arch:          c.arch,
+				CrossArchs:    c.crossArchs,
+				Cross:         cross,
+			})
 		}
 	}
 	return res
 }
 
+// tagCapabilities returns the capabilities stated by a name following the naming scheme, matched by reg.
+func tagCapabilities(reg *regexp.Regexp, match []string) imageCapabilities {
+	var caps imageCapabilities
+	for i, name := range reg.SubexpNames() {
+		if i > 0 && i <= len(match) {
+			switch name {
+			case "gccVers":
+				caps.gccVers = strings.Split(match[i], "_gcc")
+				caps.gccVers = caps.gccVers[1:] // remove initial whitespace
+			case "clangVers":
+				if len(match[i]) > 0 {
+					for _, clangVer := range strings.Split(match[i], "_clang")[1:] {
+						caps.clangs = append(caps.clangs, mustParseTolerant(clangVer))
+					}
+				}
+			case "target":
+				caps.target = match[i]
+			case "arch":
+				caps.arch = match[i]
+			case "crossArchs":
+				if len(match[i]) > 0 {
+					caps.crossArchs = strings.Split(match[i], "_cross-")[1:]
+				}
+			}
+		}
+	}
+	return caps
+}
+
 func (b *Build) LoadImages() {
-	for _, imagesLister := range b.ImagesListers {
+	// listedImage is an image along with the index of the images lister that listed it.
+	type listedImage struct {
+		Image
+		lister int
+	}
+	var nativeImages, crossImages []listedImage
+	for i, imagesLister := range b.ImagesListers {
 		for _, image := range imagesLister.LoadImages(b.Printer) {
 			// User forced a gcc version? Only load images matching the requested gcc version.
 			if b.GCCVersion != "" && b.GCCVersion != image.GCCVersion.String() {
 				continue
 			}
-			// Skip if key already exists: we have a descending prio list of docker repos!
-			if _, ok := b.Images[image.toKey()]; !ok {
-				b.Images[image.toKey()] = image
+			if image.Cross {
+				crossImages = append(crossImages, listedImage{image, i})
+			} else {
+				nativeImages = append(nativeImages, listedImage{image, i})
 			}
 		}
 	}
+
+	// Only keep the images suitable for the chosen cross build mode
+	b.setCrossCompile(len(crossImages) > 0)
+	if b.CrossCompile && len(crossImages) == 0 {
+		b.Logger.Warn("no cross-compilation builder image, falling back at qemu",
+			b.Logger.Args("arch", b.Architecture, "hostArch", b.hostArch().String()))
+		b.CrossCompile = false
+	}
+	images := nativeImages
+	if b.CrossCompile {
+		images = crossImages
+	}
+
+	listers := make(map[ImageKey]int)
+	for _, image := range images {
+		// Skip if key already exists: we have a descending prio list of docker repos!
+		// When the eBPF probe is requested, still prefer images providing versioned clangs,
+		// but only among the ones listed by the same images lister.
+		key := image.toKey()
+		if img, ok := b.Images[key]; !ok ||
+			(len(b.ProbeFilePath) > 0 && listers[key] == image.lister &&
+				len(img.ClangVersions) == 0 && len(image.ClangVersions) > 0) {
+			b.Images[key] = image.Image
+			listers[key] = image.lister
+		}
+	}
 	if len(b.Images) == 0 {
 		b.Printer.Logger.Fatal("Could not load any builder image. Leaving.")
 	}
 }
 
+// ListImages returns all the images loaded by the images listers, sorted by SortImages.
+// Unlike LoadImages, it neither picks a single image per target and gcc version,
+// nor filters out images by cross build mode.
+func (b *Build) ListImages() []Image {
+	var images []Image
+	for _, imagesLister := range b.ImagesListers {
+		images = append(images, imagesLister.LoadImages(b.Printer)...)
+	}
+	SortImages(images)
+	return images
+}
+
+// SortImages sorts images by target, architecture, gcc version and name.
+func SortImages(images []Image) {
+	slices.SortStableFunc(images, func(a, b Image) int {
+		return cmp.Or(
+			cmp.Compare(a.Target, b.Target),
+			cmp.Compare(a.Arch, b.Arch),
+			a.GCCVersion.Compare(b.GCCVersion),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// ImagesIndex groups images by name into the YAMLImagesList format loaded by FileImagesLister.
+func (b *Build) ImagesIndex(images []Image) YAMLImagesList {
+	var (
+		list    YAMLImagesList
+		indexes = make(map[string]int)
+	)
+	for _, img := range images {
+		key := img.Name + "_" + img.Target.String() + "_" + img.Arch
+		idx, ok := indexes[key]
+		if !ok {
+			yamlImage := YAMLImage{
+				Target:     img.Target.String(),
+				Name:       img.Name,
+				Arch:       img.Arch,
+				Tag:        b.BuilderImageTag(),
+				CrossArchs: img.CrossArchs,
+			}
+			for _, clang := range img.ClangVersions {
+				yamlImage.ClangVersions = append(yamlImage.ClangVersions, clang.String())
+			}
+			idx = len(list.Images)
+			indexes[key] = idx
+			list.Images = append(list.Images, yamlImage)
+		}
+		gcc := img.GCCVersion.String()
+		if !slices.Contains(list.Images[idx].GCCVersions, gcc) {
+			list.Images[idx].GCCVersions = append(list.Images[idx].GCCVersions, gcc)
+		}
+	}
+	return list
+}
+
+// nonDebArchs returns the sorted non-deb names of the supported architectures.
+func nonDebArchs() []string {
+	var archs []string
+	for _, nonDeb := range kernelrelease.SupportedArchs {
+		archs = append(archs, nonDeb)
+	}
+	slices.Sort(archs)
+	return archs
+}
+
 // getRegistryFromRef extracts the registry from a ref string.
 func getRegistryFromRef(ref string) (string, error) {
 	index := strings.Index(ref, "/")
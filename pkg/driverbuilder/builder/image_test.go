@@ -24,6 +24,7 @@ import (
 
 	"github.com/blang/semver/v4"
 	"github.com/docker/docker/testutil/registry"
+	"gopkg.in/yaml.v3"
 	"gotest.tools/assert"
 )
 
@@ -58,31 +59,68 @@ images:
 		expected: []Image{
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("8.0.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0_gcc6.0.0_gcc5.0.0_gcc4.9.0_gcc4.8.0-latest",
 			},
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("6.0.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0_gcc6.0.0_gcc5.0.0_gcc4.9.0_gcc4.8.0-latest",
 			},
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("5.0.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0_gcc6.0.0_gcc5.0.0_gcc4.9.0_gcc4.8.0-latest",
 			},
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("4.9.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0_gcc6.0.0_gcc5.0.0_gcc4.9.0_gcc4.8.0-latest",
 			},
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("4.8.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0_gcc6.0.0_gcc5.0.0_gcc4.9.0_gcc4.8.0-latest",
 			},
 		},
 	},
+	// Test that clang versions are correctly attached to each image
+	{
+		yamlData: `
+images:
+  - name: foo/test:any-x86_64_gcc12.0.0_clang14.0.0_clang7.0.0-latest
+    target: any
+    arch: x86_64
+    tag: latest
+    gcc_versions:
+      - 12.0.0
+    clang_versions:
+      - 14.0.0
+      - 7.0.0
+`,
+		jsonData: `
+{
+  "name": "foo/test",
+  "tags": [
+    "any-x86_64_gcc12.0.0_clang14.0.0_clang7.0.0-latest"
+  ]
+}
+`,
+		expected: []Image{
+			{
+				Target:        "any",
+				Arch:          "x86_64",
+				GCCVersion:    semver.MustParse("12.0.0"),
+				ClangVersions: []semver.Version{semver.MustParse("14.0.0"), semver.MustParse("7.0.0")},
+				Name:          "foo/test:any-x86_64_gcc12.0.0_clang14.0.0_clang7.0.0-latest",
+			},
+		},
+	},
 	// Test that arm64 is correctly skipped on amd64 images listing
 	{
 		yamlData: `
@@ -112,6 +150,7 @@ images:
 		expected: []Image{
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("8.0.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0-latest",
 			},
@@ -144,6 +183,7 @@ images:
 		expected: []Image{
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("8.0.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0-latest",
 			},
@@ -178,6 +218,7 @@ images:
 		expected: []Image{
 			{
 				Target:     "centos",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("8.0.0"),
 				Name:       "foo/test:centos-x86_64_gcc8.0.0-latest",
 			},
@@ -203,6 +244,7 @@ images:
 		expected: []Image{
 			{
 				Target:     "any",
+				Arch:       "x86_64",
 				GCCVersion: semver.MustParse("8.0.0"),
 				Name:       "foo/test:any-x86_64_gcc8.0.0-latest",
 			},
@@ -309,3 +351,130 @@ func TestRepoImagesLister(t *testing.T) {
 		assert.DeepEqual(t, test.expected, lister.LoadImages(printer))
 	}
 }
+
+func TestListAllImages(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	f, err := os.CreateTemp(t.TempDir(), "imagetest")
+	assert.NilError(t, err)
+	_, err = f.WriteString(crossImagesYAML)
+	assert.NilError(t, err)
+
+	b := &Build{
+		TargetType:   Type("centos"),
+		Architecture: "arm64",
+		BuilderImage: "auto:latest",
+		AllImages:    true,
+		Printer:      printer,
+	}
+	lister, err := NewFileImagesLister(f.Name(), b)
+	assert.NilError(t, err)
+	b.ImagesListers = []ImagesLister{lister}
+
+	expected := []Image{
+		{
+			Target:     "any",
+			GCCVersion: semver.MustParse("12.0.0"),
+			Name:       "foo/test:any-aarch64_gcc12.0.0-latest",
+			Arch:       "aarch64",
+		},
+		{
+			Target:     "any",
+			GCCVersion: semver.MustParse("12.0.0"),
+			Name:       "foo/test:any-x86_64_gcc12.0.0_cross-arm64-latest",
+			Arch:       "x86_64",
+			CrossArchs: []string{"arm64"},
+		},
+		{
+			Target:     "any",
+			GCCVersion: semver.MustParse("13.0.0"),
+			Name:       "foo/test:any-x86_64_gcc13.0.0-latest",
+			Arch:       "x86_64",
+		},
+	}
+	images := b.ListImages()
+	assert.DeepEqual(t, expected, images)
+
+	// The exported index gets loaded back to the same images
+	data, err := yaml.Marshal(b.ImagesIndex(images))
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(f.Name(), data, 0o644))
+	assert.DeepEqual(t, expected, b.ListImages())
+}
+
+func TestRepoImagesListerAll(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	mock, err := registry.NewMock(t)
+	assert.NilError(t, err)
+	defer mock.Close()
+
+	// The tags regex is lazily initialized for the first build: reset it
+	tagReg = nil
+	defer func() { tagReg = nil }()
+	lister, err := NewRepoImagesLister(mock.URL()+"/foo/test", &Build{
+		TargetType:        Type("centos"),
+		Architecture:      "amd64",
+		BuilderImage:      "auto:latest",
+		RegistryPlainHTTP: true,
+		AllImages:         true,
+	})
+	assert.NilError(t, err)
+
+	mock.RegisterHandler("/v2/foo/test/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(crossImagesJSON))
+	})
+	var names []string
+	for _, img := range lister.LoadImages(printer) {
+		assert.Assert(t, !img.Cross)
+		names = append(names, img.Name)
+	}
+	assert.DeepEqual(t, []string{
+		mock.URL() + "/foo/test:any-aarch64_gcc12.0.0-latest",
+		mock.URL() + "/foo/test:any-x86_64_gcc12.0.0_cross-arm64-latest",
+		mock.URL() + "/foo/test:any-x86_64_gcc13.0.0-latest",
+		mock.URL() + "/foo/test:any-x86_64_gcc11.0.0_cross-s390x-latest",
+	}, names)
+}
+
+func TestSortImages(t *testing.T) {
+	images := []Image{
+		{Target: "ubuntu", Arch: "x86_64", GCCVersion: semver.MustParse("8.0.0"), Name: "b"},
+		{Target: "any", Arch: "x86_64", GCCVersion: semver.MustParse("10.0.0"), Name: "a"},
+		{Target: "any", Arch: "aarch64", GCCVersion: semver.MustParse("12.0.0"), Name: "c"},
+		{Target: "any", Arch: "x86_64", GCCVersion: semver.MustParse("9.0.0"), Name: "a"},
+	}
+	SortImages(images)
+	var names []string
+	for _, img := range images {
+		names = append(names, img.Name+"_"+img.GCCVersion.String())
+	}
+	assert.DeepEqual(t, []string{"c_12.0.0", "a_9.0.0", "a_10.0.0", "b_8.0.0"}, names)
+}
+
+func TestLoadImagesProbePriority(t *testing.T) {
+	userImage := Image{Target: "any", GCCVersion: semver.MustParse("12.0.0"), Name: "user/test:any-x86_64_gcc12.0.0-latest"}
+	defaultImage := Image{
+		Target:        "any",
+		GCCVersion:    semver.MustParse("12.0.0"),
+		ClangVersions: []semver.Version{semver.MustParse("14.0.0")},
+		Name:          "foo/test:any-x86_64_gcc12.0.0_clang14.0.0-latest",
+	}
+
+	b := &Build{
+		ProbeFilePath: "/tmp/probe.o",
+		Images:        make(ImagesMap),
+		Printer:       output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+	}
+
+	// images from a higher priority lister always win
+	b.ImagesListers = []ImagesLister{staticImagesLister{userImage}, staticImagesLister{defaultImage}}
+	b.LoadImages()
+	assert.Equal(t, b.Images["any_12.0.0"].Name, userImage.Name)
+
+	// images providing versioned clangs are only preferred among the ones of the same lister
+	b.Images = make(ImagesMap)
+	b.ImagesListers = []ImagesLister{staticImagesLister{userImage, defaultImage}}
+	b.LoadImages()
+	assert.Equal(t, b.Images["any_12.0.0"].Name, defaultImage.Name)
+}
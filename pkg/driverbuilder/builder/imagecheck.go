@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// distroImageTargets are the targets whose kernel headers get downloaded through the distro package manager,
+// hence built with user provided distro images, whose tools are not required to "any" target images.
+var distroImageTargets = []Type{TargetTypeRedhat, TargetTypeSLES}
+
+var (
+	templateActionReg = regexp.MustCompile(`(?s){{.*?}}`)
+	toolReg           = regexp.MustCompile(`^[a-z][a-z0-9._+-]*$`)
+	assignmentReg     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+)
+
+// shellBuiltins are the shell builtins and keywords, that do not need to be provided by the images.
+var shellBuiltins = []string{
+	"if", "then", "else", "elif", "fi", "for", "in", "do", "done", "while", "until", "case", "esac", "!",
+	"cd", "set", "export", "read", "echo", "printf", "exit", "return", "true", "false", "test", "local",
+	"source", "command", "shift", "break", "continue", "trap", "wait", "exec", "eval", "time",
+}
+
+// RequiredTools returns the tools run by the builder templates of target, that its builder images must provide;
+// "any" target images must provide the ones of every target, but the distroImageTargets ones.
+func RequiredTools(target Type) []string {
+	var builders []Builder
+	if target == "any" {
+		for t, b := range byTarget {
+			if !slices.Contains(distroImageTargets, t) {
+				builders = append(builders, b)
+			}
+		}
+	} else if b, err := Factory(target); err == nil {
+		builders = append(builders, b)
+	}
+
+	// The scripts are run by bash, and the drivers sources configured by cmake, see cmakeCmdFmt
+	tools := []string{"bash"}
+	for _, b := range builders {
+		for _, script := range []string{libsDownloadTemplate, b.TemplateKernelUrlsScript(), makeDriverTemplate, b.TemplateScript(), cmakeCmdFmt} {
+			tools = append(tools, scriptTools(script)...)
+		}
+	}
+	slices.Sort(tools[1:])
+	return slices.Compact(tools)
+}
+
+// scriptTools returns the tools run by a builder script template: the command of each pipeline,
+// list or command substitution, but the shell builtins, along with the decompressors needed by tar.
+// Template actions are dropped, but verifyChecksum, that runs the checksumAlgos tools.
+func scriptTools(script string) []string {
+	var tools []string
+	if strings.Contains(script, "verifyChecksum") {
+		for _, algo := range checksumAlgos {
+			tools = append(tools, algo+"sum")
+		}
+	}
+	script = templateActionReg.ReplaceAllString(script, "")
+	script = strings.ReplaceAll(script, "\\\n", " ")
+	for _, command := range shellCommands(script) {
+		words := strings.Fields(command)
+		// skip keywords and variables assignments preceding the command
+		for len(words) > 0 && (slices.Contains([]string{"if", "then", "else", "elif", "do", "while", "until", "!", "time", "exec"}, words[0]) || assignmentReg.MatchString(words[0])) {
+			words = words[1:]
+		}
+		if len(words) == 0 || slices.Contains(shellBuiltins, words[0]) || !toolReg.MatchString(words[0]) {
+			continue
+		}
+		tools = append(tools, words[0])
+		if words[0] == "tar" {
+			tools = append(tools, tarDecompressors(words[1:])...)
+		}
+	}
+	return tools
+}
+
+// shellCommands splits script into its simple commands, splitting lines on pipes, lists and subshells,
+// outside of single quotes and, but for command substitutions, double quotes; comments are dropped.
+func shellCommands(script string) []string {
+	var (
+		commands        []string
+		current         strings.Builder
+		inSingle, inDbl bool
+		comment         bool
+	)
+	split := func() {
+		commands = append(commands, current.String())
+		current.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case comment:
+			if c == '\n' {
+				comment = false
+				split()
+			}
+		case inSingle:
+			inSingle = c != '\''
+			current.WriteByte(c)
+		case inDbl && c == '$' && i+1 < len(script) && script[i+1] == '(':
+			split()
+			i++
+		case inDbl:
+			inDbl = c != '"'
+			current.WriteByte(c)
+		case c == '\'':
+			inSingle = true
+			current.WriteByte(c)
+		case c == '"':
+			inDbl = true
+			current.WriteByte(c)
+		case c == '#' && (i == 0 || script[i-1] == ' ' || script[i-1] == '\n' || script[i-1] == '\t'):
+			comment = true
+		case strings.IndexByte("\n;|&()`", c) >= 0:
+			split()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	split()
+	return commands
+}
+
+// tarDecompressors returns the decompressors tar runs to extract the archives, given its arguments.
+func tarDecompressors(args []string) []string {
+	var tools []string
+	for _, arg := range args {
+		switch {
+		case arg == "--xz" || strings.HasSuffix(arg, ".xz") ||
+			(strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "J")):
+			tools = append(tools, "xz")
+		case arg == "--gzip" || strings.HasSuffix(arg, ".gz") || strings.HasSuffix(arg, ".tgz") ||
+			(strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "z")):
+			tools = append(tools, "gzip")
+		case arg == "--zstd" || strings.HasSuffix(arg, ".zst"):
+			tools = append(tools, "zstd")
+		case strings.HasSuffix(arg, ".tar.*"):
+			// eg: deb packages data, compressed with any of them
+			tools = append(tools, "xz", "gzip", "zstd")
+		}
+	}
+	return tools
+}
+
+const (
+	ImageCheckGCC   = "gcc"
+	ImageCheckClang = "clang"
+	ImageCheckTool  = "tool"
+)
+
+// ImageCheck is a binary a builder image must provide.
+type ImageCheck struct {
+	Kind   string
+	Binary string
+}
+
+// ImageChecks returns the checks of the images sharing the same name:
+// every advertised gcc version, for the image and the cross-compilation architectures,
+// every advertised clang version, or the default distro one, and the tools required by their targets.
+func ImageChecks(images []Image) []ImageCheck {
+	var checks []ImageCheck
+	add := func(kind, binary string) {
+		check := ImageCheck{Kind: kind, Binary: binary}
+		if !slices.Contains(checks, check) {
+			checks = append(checks, check)
+		}
+	}
+
+	var clangs []string
+	for _, img := range images {
+		add(ImageCheckGCC, "/usr/bin/gcc-"+img.GCCVersion.String())
+		for _, crossArch := range img.CrossArchs {
+			if cross, ok := crossCompileArchs[kernelrelease.Architecture(crossArch)]; ok {
+				add(ImageCheckGCC, "/usr/bin/"+cross.prefix+"gcc-"+img.GCCVersion.String())
+			}
+		}
+		for _, clang := range img.ClangVersions {
+			if !slices.Contains(clangs, clang.String()) {
+				clangs = append(clangs, clang.String())
+			}
+		}
+	}
+	if len(clangs) == 0 {
+		add(ImageCheckClang, "clang")
+		add(ImageCheckClang, "llc")
+		add(ImageCheckClang, "ld.lld")
+	}
+	for _, clang := range clangs {
+		add(ImageCheckClang, "/usr/bin/clang-"+clang)
+		add(ImageCheckClang, "/usr/bin/llc-"+clang)
+		add(ImageCheckClang, "/usr/bin/ld.lld-"+clang)
+	}
+	var targets []Type
+	for _, img := range images {
+		if !slices.Contains(targets, img.Target) {
+			targets = append(targets, img.Target)
+		}
+	}
+	for _, target := range targets {
+		for _, tool := range RequiredTools(target) {
+			add(ImageCheckTool, tool)
+		}
+	}
+	return checks
+}
+
+// ImageChecksScript returns a sh script printing the binaries missing among checks, one per line.
+func ImageChecksScript(checks []ImageCheck) string {
+	var sb strings.Builder
+	for _, check := range checks {
+		fmt.Fprintf(&sb, "command -v %s >/dev/null 2>&1 || echo %s\n", check.Binary, check.Binary)
+	}
+	return sb.String()
+}
+
+// MissingImageChecks returns the checks whose binary is reported missing by the ImageChecksScript output.
+func MissingImageChecks(checks []ImageCheck, output string) []ImageCheck {
+	missing := strings.Fields(output)
+	var res []ImageCheck
+	for _, check := range checks {
+		if slices.Contains(missing, check.Binary) {
+			res = append(res, check)
+		}
+	}
+	return res
+}
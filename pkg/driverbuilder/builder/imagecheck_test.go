@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"os/exec"
+	"slices"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"gotest.tools/assert"
+)
+
+func TestImageChecks(t *testing.T) {
+	tests := map[string]struct {
+		images   []Image
+		expected []ImageCheck
+	}{
+		"gcc versions and default clang": {
+			images: []Image{
+				{Name: "foo:any-x86_64_gcc12.0.0_gcc11.0.0-latest", Target: "any", GCCVersion: semver.MustParse("12.0.0")},
+				{Name: "foo:any-x86_64_gcc12.0.0_gcc11.0.0-latest", Target: "any", GCCVersion: semver.MustParse("11.0.0")},
+			},
+			expected: []ImageCheck{
+				{Kind: ImageCheckGCC, Binary: "/usr/bin/gcc-12.0.0"},
+				{Kind: ImageCheckGCC, Binary: "/usr/bin/gcc-11.0.0"},
+				{Kind: ImageCheckClang, Binary: "clang"},
+				{Kind: ImageCheckClang, Binary: "llc"},
+				{Kind: ImageCheckClang, Binary: "ld.lld"},
+			},
+		},
+		"cross gcc and clang versions": {
+			images: []Image{
+				{
+					Name:          "foo:any-x86_64_gcc12.0.0_clang14.0.0_cross-arm64-latest",
+					Target:        "any",
+					GCCVersion:    semver.MustParse("12.0.0"),
+					ClangVersions: []semver.Version{semver.MustParse("14.0.0")},
+					CrossArchs:    []string{"arm64"},
+				},
+			},
+			expected: []ImageCheck{
+				{Kind: ImageCheckGCC, Binary: "/usr/bin/gcc-12.0.0"},
+				{Kind: ImageCheckGCC, Binary: "/usr/bin/aarch64-linux-gnu-gcc-12.0.0"},
+				{Kind: ImageCheckClang, Binary: "/usr/bin/clang-14.0.0"},
+				{Kind: ImageCheckClang, Binary: "/usr/bin/llc-14.0.0"},
+				{Kind: ImageCheckClang, Binary: "/usr/bin/ld.lld-14.0.0"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expected := test.expected
+			for _, tool := range RequiredTools("any") {
+				expected = append(expected, ImageCheck{Kind: ImageCheckTool, Binary: tool})
+			}
+			assert.DeepEqual(t, ImageChecks(test.images), expected)
+		})
+	}
+}
+
+func TestRequiredTools(t *testing.T) {
+	tests := map[Type]struct {
+		expected []string
+		missing  []string
+	}{
+		"ubuntu": {
+			expected: []string{"bash", "cmake", "curl", "ar", "tar", "xz", "gzip", "zstd", "make", "strip", "modinfo"},
+			missing:  []string{"rpm2cpio", "cpio", "sha256sum"},
+		},
+		"centos": {
+			expected: []string{"bash", "cmake", "curl", "rpm2cpio", "cpio", "sha256sum", "sha1sum", "make"},
+			missing:  []string{"ar", "zstd"},
+		},
+		"arch": {
+			expected: []string{"curl", "tar", "xz"},
+		},
+		"any": {
+			expected: []string{"ar", "rpm2cpio", "cpio", "sha256sum", "xz", "gzip", "zstd"},
+			missing:  []string{"yum", "zypper"},
+		},
+	}
+	for target, test := range tests {
+		t.Run(target.String(), func(t *testing.T) {
+			tools := RequiredTools(target)
+			for _, tool := range test.expected {
+				assert.Assert(t, slices.Contains(tools, tool), "%s not in %v", tool, tools)
+			}
+			for _, tool := range test.missing {
+				assert.Assert(t, !slices.Contains(tools, tool), "%s in %v", tool, tools)
+			}
+			// neither shell builtins, nor arguments, nor variables
+			for _, tool := range []string{"cd", "set", "echo", "modules_prepare", "KERNELDIR", "driver"} {
+				assert.Assert(t, !slices.Contains(tools, tool), "%s in %v", tool, tools)
+			}
+		})
+	}
+}
+
+func TestScriptTools(t *testing.T) {
+	script := `# download the {{ .Name }} headers
+set -xeuo pipefail
+cd {{ .DriverBuildDir }} && curl -SL {{ .URL }} | tar -Jxf - -C /tmp
+{{ verifyChecksum .URL "kernel.rpm" }}
+V=$(sed -n -E 's/^VERSION=([0-9]+)|(x)$/\1/p' ${KERNELDIR}/Makefile | head -n 1 || true)
+if [ -n "$(find /tmp -name '*.deb')" ]; then
+  ar x *.deb && tar -xf data.tar.* # a comment
+fi
+CC=gcc {{ .CrossCompilePrefix }}strip -g \
+  module.ko`
+	tools := scriptTools(script)
+	assert.DeepEqual(t, tools, []string{
+		"md5sum", "sha1sum", "sha224sum", "sha256sum", "sha384sum", "sha512sum",
+		"curl", "tar", "xz", "sed", "head", "find", "ar", "tar", "xz", "gzip", "zstd", "strip",
+	})
+}
+
+func TestImageChecksScript(t *testing.T) {
+	checks := []ImageCheck{
+		{Kind: ImageCheckTool, Binary: "sh"},
+		{Kind: ImageCheckGCC, Binary: "/usr/bin/gcc-0.0.1"},
+		{Kind: ImageCheckTool, Binary: "driverkit-missing-tool"},
+	}
+	out, err := exec.Command("/bin/sh", "-c", ImageChecksScript(checks)).Output()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, MissingImageChecks(checks, string(out)), checks[1:])
+}
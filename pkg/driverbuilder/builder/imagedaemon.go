@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// daemonImagesClient is the subset of the docker client used by DaemonImagesLister.
+type daemonImagesClient interface {
+	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
+	ImageInspect(ctx context.Context, imageID string, inspectOpts ...client.ImageInspectOption) (image.InspectResponse, error)
+}
+
+// DaemonImagesLister loads the builder images already present in the local docker daemon,
+// either following the tags naming scheme or labeled (see LabelGCCVersions).
+// Non-empty Repository only loads the images of the given repository, eg: myorg/driverkit-builder.
+// Empty Arch and Target load the images of any architecture and target.
+type DaemonImagesLister struct {
+	Repository string
+	Arch       string
+	HostArch   string
+	CrossArch  string
+	Tag        string
+	Target     string
+	client     daemonImagesClient
+}
+
+func NewDaemonImagesLister(repo string, build *Build) (*DaemonImagesLister, error) {
+	initTagReg(build)
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	if build.AllImages {
+		return &DaemonImagesLister{
+			Repository: repo,
+			Tag:        build.BuilderImageTag(),
+			client:     cli,
+		}, nil
+	}
+	return &DaemonImagesLister{
+		Repository: repo,
+		Arch:       kernelrelease.Architecture(build.Architecture).ToNonDeb(),
+		HostArch:   build.hostArch().ToNonDeb(),
+		CrossArch:  build.Architecture,
+		Tag:        build.BuilderImageTag(),
+		Target:     build.TargetType.String(),
+		client:     cli,
+	}, nil
+}
+
+func (d *DaemonImagesLister) LoadImages(printer *output.Printer) []Image {
+	ctx := context.Background()
+	summaries, err := d.client.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		printer.Logger.Warn("skipping docker daemon images",
+			printer.Logger.Args("repo", d.Repository, "err", err.Error()))
+		return nil
+	}
+
+	var res []Image
+	for _, summary := range summaries {
+		for _, repoTag := range summary.RepoTags {
+			// Split the tag from the repository, that may contain a registry port too
+			idx := strings.LastIndex(repoTag, ":")
+			if idx <= strings.LastIndex(repoTag, "/") {
+				continue
+			}
+			repo, tag := repoTag[:idx], repoTag[idx+1:]
+			if d.Repository != "" && repo != d.Repository {
+				continue
+			}
+
+			var caps []imageCapabilities
+			if match := tagReg.FindStringSubmatch(tag); len(match) > 0 {
+				caps = append(caps, tagCapabilities(tagReg, match))
+			} else if labels := driverkitLabels(summary.Labels); labels[LabelGCCVersions] != "" && labeledTag(tag, d.Tag) {
+				inspect, err := d.client.ImageInspect(ctx, summary.ID)
+				if err != nil {
+					printer.Logger.Debug("skipping uninspectable image",
+						printer.Logger.Args("image", repoTag, "err", err.Error()))
+					continue
+				}
+				if c, ok := (platformLabels{Arch: inspect.Architecture, Labels: labels}).capabilities(); ok {
+					caps = append(caps, c)
+				}
+			}
+			res = append(res, capabilitiesImages(repoTag, caps, d.Target, d.Arch, d.HostArch, d.CrossArch)...)
+		}
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+type fakeDaemonImagesClient struct {
+	summaries []image.Summary
+	archs     map[string]string
+}
+
+func (f *fakeDaemonImagesClient) ImageList(_ context.Context, _ image.ListOptions) ([]image.Summary, error) {
+	return f.summaries, nil
+}
+
+func (f *fakeDaemonImagesClient) ImageInspect(_ context.Context, imageID string, _ ...client.ImageInspectOption) (image.InspectResponse, error) {
+	return image.InspectResponse{ID: imageID, Architecture: f.archs[imageID]}, nil
+}
+
+func TestDaemonImagesLister(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	tagReg = nil
+	defer func() { tagReg = nil }()
+	initTagReg(&Build{TargetType: Type("centos"), Architecture: "amd64", BuilderImage: "auto:latest"})
+
+	fake := &fakeDaemonImagesClient{
+		summaries: []image.Summary{
+			{ID: "1", RepoTags: []string{"localhost:5000/builders:any-x86_64_gcc8.0.0-latest", "other/builders:any-x86_64_gcc9.0.0-latest"}},
+			{ID: "2", RepoTags: []string{"localhost:5000/builders:mine-latest"}, Labels: map[string]string{
+				LabelTarget:      "centos",
+				LabelGCCVersions: "12.0.0",
+			}},
+			{ID: "3", RepoTags: []string{"localhost:5000/builders:arm-latest"}, Labels: map[string]string{
+				LabelGCCVersions: "13.0.0",
+			}},
+			{ID: "4", RepoTags: []string{"localhost:5000/builders:unlabeled-latest", "localhost:5000/builders"}},
+		},
+		archs: map[string]string{"2": "amd64", "3": "arm64"},
+	}
+	lister := &DaemonImagesLister{
+		Repository: "localhost:5000/builders",
+		Arch:       "x86_64",
+		HostArch:   "x86_64",
+		CrossArch:  "amd64",
+		Tag:        "latest",
+		Target:     "centos",
+		client:     fake,
+	}
+	assert.DeepEqual(t, []Image{
+		{
+			Name:       "localhost:5000/builders:any-x86_64_gcc8.0.0-latest",
+			Target:     "any",
+			Arch:       "x86_64",
+			GCCVersion: semver.MustParse("8.0.0"),
+		},
+		{
+			Name:       "localhost:5000/builders:mine-latest",
+			Target:     "centos",
+			Arch:       "x86_64",
+			GCCVersion: semver.MustParse("12.0.0"),
+		},
+	}, lister.LoadImages(printer))
+
+	// Any repository, architecture and target
+	lister = &DaemonImagesLister{Tag: "latest", client: fake}
+	var names []string
+	for _, img := range lister.LoadImages(printer) {
+		names = append(names, img.Name)
+	}
+	assert.DeepEqual(t, []string{
+		"localhost:5000/builders:any-x86_64_gcc8.0.0-latest",
+		"other/builders:any-x86_64_gcc9.0.0-latest",
+		"localhost:5000/builders:mine-latest",
+		"localhost:5000/builders:arm-latest",
+	}, names)
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/distribution/reference"
+	"github.com/falcosecurity/falcoctl/pkg/oci/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// ImagesLock pins the builder images references to their manifest digests, for repeatable builds.
+type ImagesLock struct {
+	Images map[string]string `yaml:"images"`
+}
+
+// LoadImagesLock loads the images lock file at path, returning an empty lock when it does not exist yet.
+func LoadImagesLock(path string) (*ImagesLock, error) {
+	lock := &ImagesLock{Images: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("invalid images lock file %s: %w", path, err)
+	}
+	if lock.Images == nil {
+		lock.Images = make(map[string]string)
+	}
+	return lock, nil
+}
+
+// Save writes the images lock file at path.
+func (l *ImagesLock) Save(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// PinnedBuilderImage returns the given builder image pinned to its manifest digest, ie: <name>@sha256:<hex>,
+// so that a mutable tag like <tag>-latest cannot silently change the toolchain between builds.
+// The digest is taken from the images lock file when pinned there, otherwise it is resolved from the registry
+// and recorded in the lock file, if any. When it cannot be resolved, the image is used as is.
+func (b *Build) PinnedBuilderImage(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		b.Logger.Warn("cannot pin builder image to its digest",
+			b.Logger.Args("image", image, "err", err.Error()))
+		return image
+	}
+	if _, ok := named.(reference.Digested); ok {
+		// already pinned
+		return image
+	}
+
+	if b.imagesLock == nil {
+		b.imagesLock = &ImagesLock{Images: make(map[string]string)}
+		if b.ImagesLockFile != "" {
+			if b.imagesLock, err = LoadImagesLock(b.ImagesLockFile); err != nil {
+				b.Logger.Warn("cannot load images lock file",
+					b.Logger.Args("file", b.ImagesLockFile, "err", err.Error()))
+				b.imagesLock = &ImagesLock{Images: make(map[string]string)}
+			}
+		}
+	}
+
+	dgst, ok := b.imagesLock.Images[image]
+	if !ok {
+		if dgst, err = b.resolveImageDigest(named); err != nil {
+			b.Logger.Warn("cannot pin builder image to its digest, using its tag",
+				b.Logger.Args("image", image, "err", err.Error()))
+			return image
+		}
+		b.imagesLock.Images[image] = dgst
+		if b.ImagesLockFile != "" {
+			if err = b.imagesLock.Save(b.ImagesLockFile); err != nil {
+				b.Logger.Warn("cannot write images lock file",
+					b.Logger.Args("file", b.ImagesLockFile, "err", err.Error()))
+			}
+		}
+	}
+
+	pinned := reference.TrimNamed(named).String() + "@" + dgst
+	b.Logger.Info("using builder image",
+		b.Logger.Args("image", image, "digest", dgst, "locked", ok))
+	return pinned
+}
+
+// resolveImageDigest resolves the manifest digest of a tagged image from its registry;
+// for multi-arch images, it is the digest of the image index.
+func (b *Build) resolveImageDigest(named reference.Named) (string, error) {
+	repo, err := b.imageRepository(named)
+	if err != nil {
+		return "", err
+	}
+
+	tag := "latest"
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+	desc, err := repo.Resolve(context.Background(), tag)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest.String(), nil
+}
+
+// imageRepository returns the repository of an image, authenticated through ClientForRegistry.
+func (b *Build) imageRepository(named reference.Named) (*repository.Repository, error) {
+	return repository.NewRepository(reference.TrimNamed(named).String(),
+		repository.WithPlainHTTP(b.RegistryPlainHTTP),
+		repository.WithClient(b.ClientForRegistry(reference.Domain(named))))
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/testutil/registry"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+func TestPinnedBuilderImage(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	mock, err := registry.NewMock(t)
+	assert.NilError(t, err)
+	defer mock.Close()
+
+	var fetches int
+	var desc ocispec.Descriptor
+	assert.NilError(t, json.Unmarshal([]byte(registerImage(mock, &fetches, "amd64", "", "", "any-x86_64_gcc12.0.0-latest")), &desc))
+	mock.RegisterHandler("/v2/foo/test/manifests/missing-latest$", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	image := mock.URL() + "/foo/test:any-x86_64_gcc12.0.0-latest"
+	lockFile := filepath.Join(t.TempDir(), "images.lock")
+	newBuild := func() *Build {
+		return &Build{
+			RegistryPlainHTTP: true,
+			ImagesLockFile:    lockFile,
+			Printer:           printer,
+		}
+	}
+
+	// The digest gets resolved from the registry once, and recorded in the lock file
+	b := newBuild()
+	assert.Equal(t, b.PinnedBuilderImage(image), mock.URL()+"/foo/test@"+desc.Digest.String())
+	assert.Equal(t, b.PinnedBuilderImage(image), mock.URL()+"/foo/test@"+desc.Digest.String())
+	assert.Equal(t, fetches, 1)
+	lock, err := LoadImagesLock(lockFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, lock.Images, map[string]string{image: desc.Digest.String()})
+
+	// Digests pinned by the lock file are used as is, even if the tag moved
+	pinned := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	lock.Images[image] = pinned
+	assert.NilError(t, lock.Save(lockFile))
+	assert.Equal(t, newBuild().PinnedBuilderImage(image), mock.URL()+"/foo/test@"+pinned)
+	assert.Equal(t, fetches, 1)
+
+	// Images already pinned, or whose digest cannot be resolved, are used as is
+	b = newBuild()
+	assert.Equal(t, b.PinnedBuilderImage(mock.URL()+"/foo/test@"+pinned), mock.URL()+"/foo/test@"+pinned)
+	assert.Equal(t, b.PinnedBuilderImage(mock.URL()+"/foo/test:missing-latest"), mock.URL()+"/foo/test:missing-latest")
+}
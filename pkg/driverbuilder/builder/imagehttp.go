@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/falcosecurity/falcoctl/pkg/output"
+)
+
+// HTTPImagesLister loads a YAMLImagesList index, like FileImagesLister, from an HTTP(S) URL.
+// The index is cached by ETag, and a "#sha256=<hex>" URL fragment pins its checksum.
+type HTTPImagesLister struct {
+	*FileImagesLister // FilePath holds the URL, without fragment
+	Checksum          string
+	Cache             *ImagesCache // nil fetches the index on each run
+	Client            *http.Client
+}
+
+func NewHTTPImagesLister(rawURL string, build *Build) (*HTTPImagesLister, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var checksum string
+	if u.Fragment != "" {
+		var ok bool
+		checksum, ok = strings.CutPrefix(u.Fragment, "sha256=")
+		if _, err = hex.DecodeString(checksum); !ok || err != nil || len(checksum) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid checksum %q, expected sha256=<hex>", u.Fragment)
+		}
+		u.Fragment = ""
+	}
+
+	f, err := NewFileImagesLister(u.String(), build)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPImagesLister{
+		FileImagesLister: f,
+		Checksum:         strings.ToLower(checksum),
+		Cache:            build.ImagesCache,
+		Client:           http.DefaultClient,
+	}, nil
+}
+
+func (h *HTTPImagesLister) LoadImages(printer *output.Printer) []Image {
+	ctx := context.Background()
+	listing, err := h.Cache.get(ctx, printer, h.source(), h.fetch)
+	if err == nil && h.verify(listing.Data) != nil && h.Cache != nil && !h.Cache.Offline {
+		// The pinned checksum changed since the index was cached: download it again, bypassing the cache
+		printer.Logger.Debug("cached images index does not match the checksum, downloading it again",
+			printer.Logger.Args("repo", h.source()))
+		if listing, err = h.fetch(ctx, nil); err == nil {
+			if storeErr := h.Cache.store(listing); storeErr != nil {
+				printer.Logger.Warn("error caching images listing",
+					printer.Logger.Args("repo", h.source(), "err", storeErr.Error()))
+			}
+		}
+	}
+	if err == nil {
+		err = h.verify(listing.Data)
+	}
+	if err != nil {
+		printer.Logger.Warn("skipping repo",
+			printer.Logger.Args("repo", h.source(), "err", err.Error()))
+		return nil
+	}
+	return h.loadYAMLImages(printer, listing.Data)
+}
+
+// fetch downloads the index, unless unchanged since the cached one.
+func (h *HTTPImagesLister) fetch(ctx context.Context, cached *imagesCacheEntry) (*imagesCacheEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.source(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		listing := *cached
+		listing.FetchedAt = time.Now()
+		return &listing, nil
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		// Never cache a tampered index
+		if err = h.verify(data); err != nil {
+			return nil, err
+		}
+		return &imagesCacheEntry{
+			Reference: h.source(),
+			FetchedAt: time.Now(),
+			ETag:      resp.Header.Get("ETag"),
+			Data:      data,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// verify checks data against the pinned checksum, if any.
+func (h *HTTPImagesLister) verify(data []byte) error {
+	if h.Checksum == "" {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if checksum := hex.EncodeToString(sum[:]); checksum != h.Checksum {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", h.Checksum, checksum)
+	}
+	return nil
+}
+
+func (h *HTTPImagesLister) Refresh(ctx context.Context) error {
+	return h.Cache.refresh(ctx, h.source(), h.fetch)
+}
+
+func (h *HTTPImagesLister) source() string {
+	return h.FilePath
+}
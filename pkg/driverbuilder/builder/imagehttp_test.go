@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+func TestHTTPImagesLister(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	var (
+		downloads int
+		failing   bool
+		index     = []byte(crossImagesYAML)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sum := sha256.Sum256(index)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write(index)
+	}))
+	defer srv.Close()
+
+	cache := &ImagesCache{Dir: t.TempDir()}
+	b := crossBuild()
+	b.ImagesCache = cache
+	lister, err := NewHTTPImagesLister(srv.URL+"/index.yaml", b)
+	assert.NilError(t, err)
+	assert.Equal(t, lister.source(), srv.URL+"/index.yaml")
+
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+	assert.Equal(t, downloads, 1)
+
+	// Unchanged index is not downloaded again
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+	assert.Equal(t, downloads, 1)
+
+	// Cached index is used when the server fails
+	failing = true
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+	failing = false
+
+	// Changed index is downloaded again
+	index = []byte(crossImagesYAML + "\n")
+	assert.NilError(t, lister.Refresh(t.Context()))
+	assert.Equal(t, downloads, 2)
+
+	// Pinned checksum
+	sum := sha256.Sum256(index)
+	lister, err = NewHTTPImagesLister(srv.URL+"/index.yaml#sha256="+hex.EncodeToString(sum[:]), b)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+
+	lister, err = NewHTTPImagesLister(srv.URL+"/index.yaml#sha256="+hex.EncodeToString(make([]byte, sha256.Size)), &Build{
+		TargetType:   Type("centos"),
+		Architecture: "arm64",
+		BuilderImage: "auto:latest",
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, lister.LoadImages(printer) == nil)
+
+	// A fresh cached index not matching a new pinned checksum is downloaded again
+	b.ImagesCache = &ImagesCache{Dir: t.TempDir(), TTL: time.Hour}
+	lister, err = NewHTTPImagesLister(srv.URL+"/index.yaml", b)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+	index = []byte(crossImagesYAML + "\n\n")
+	sum = sha256.Sum256(index)
+	lister, err = NewHTTPImagesLister(srv.URL+"/index.yaml#sha256="+hex.EncodeToString(sum[:]), b)
+	assert.NilError(t, err)
+	downloads = 0
+	assert.DeepEqual(t, crossImagesExpected, lister.LoadImages(printer))
+	assert.Equal(t, downloads, 1)
+
+	_, err = NewHTTPImagesLister(srv.URL+"/index.yaml#md5=1234", b)
+	assert.ErrorContains(t, err, "invalid checksum")
+}
+
+func TestHTTPImagesListerAll(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(crossImagesYAML))
+	}))
+	defer srv.Close()
+
+	lister, err := NewHTTPImagesLister(srv.URL, &Build{BuilderImage: "auto:latest", AllImages: true})
+	assert.NilError(t, err)
+	images := lister.LoadImages(printer)
+	assert.Equal(t, len(images), 3)
+	assert.DeepEqual(t, images[2].GCCVersion, semver.MustParse("13.0.0"))
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// Builder images can state their capabilities through these manifest annotations or image config labels,
+// instead of following the tags naming scheme. Versions and architectures are comma separated lists.
+const (
+	LabelPrefix        = "org.falcosecurity.driverkit."
+	LabelTarget        = LabelPrefix + "target"
+	LabelGCCVersions   = LabelPrefix + "gcc-versions"
+	LabelClangVersions = LabelPrefix + "clang-versions"
+	LabelCrossArchs    = LabelPrefix + "cross-archs"
+)
+
+const mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+// tagNamingReg matches the tags following the naming scheme, eg: <target>-<arch>_gcc..., for any target and architecture:
+// their manifests never get fetched to look for labels.
+var tagNamingReg = regexp.MustCompile("^[a-z0-9]+-(" + strings.Join(nonDebArchs(), "|") + ")[_-]")
+
+// platformLabels holds the driverkit labels of a builder image, for one of its platforms.
+type platformLabels struct {
+	Arch   string            `json:"arch"` // eg: amd64
+	Labels map[string]string `json:"labels"`
+}
+
+// imageCapabilities describes what a builder image provides, either from its tag or from its labels.
+type imageCapabilities struct {
+	target     string
+	arch       string // non-deb, eg: x86_64
+	gccVers    []string
+	clangs     []semver.Version
+	crossArchs []string
+}
+
+// labeledTag returns true for the tags that may point to labeled builder images:
+// the ones not following the naming scheme, with the requested builder image tag as suffix.
+func labeledTag(tag, imageTag string) bool {
+	if tagNamingReg.MatchString(tag) {
+		return false
+	}
+	return tag == imageTag || strings.HasSuffix(tag, "-"+imageTag)
+}
+
+// fetchLabels fetches the driverkit labels for each platform of the image pointed by tag,
+// looking at the manifest annotations first and at the image config labels then.
+func (repo *RepoImagesLister) fetchLabels(ctx context.Context, tag string) (cachedLabels, error) {
+	desc, data, err := repo.fetchManifest(ctx, tag)
+	if err != nil {
+		return cachedLabels{}, err
+	}
+
+	res := cachedLabels{Digest: desc.Digest.String(), Platforms: []platformLabels{}}
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, mediaTypeDockerManifestList:
+		var index ocispec.Index
+		if err = json.Unmarshal(data, &index); err != nil {
+			return cachedLabels{}, err
+		}
+		for _, m := range index.Manifests {
+			// Skip non linux manifests, as well as attestations ones
+			if m.Platform == nil || m.Platform.OS != "linux" {
+				continue
+			}
+			labels := driverkitLabels(index.Annotations, m.Annotations)
+			if labels[LabelGCCVersions] == "" {
+				_, data, err = repo.fetchManifest(ctx, m.Digest.String())
+				if err != nil {
+					return cachedLabels{}, err
+				}
+				platform, err := repo.manifestLabels(ctx, data)
+				if err != nil {
+					return cachedLabels{}, err
+				}
+				// Index annotations take precedence
+				maps.Copy(platform.Labels, labels)
+				labels = platform.Labels
+			}
+			if labels[LabelGCCVersions] != "" {
+				res.Platforms = append(res.Platforms, platformLabels{Arch: m.Platform.Architecture, Labels: labels})
+			}
+		}
+	default:
+		platform, err := repo.manifestLabels(ctx, data)
+		if err != nil {
+			return cachedLabels{}, err
+		}
+		if platform.Labels[LabelGCCVersions] != "" {
+			res.Platforms = append(res.Platforms, platform)
+		}
+	}
+	return res, nil
+}
+
+func (repo *RepoImagesLister) fetchManifest(ctx context.Context, reference string) (ocispec.Descriptor, []byte, error) {
+	desc, rc, err := repo.FetchReference(ctx, reference)
+	if err != nil {
+		return desc, nil, err
+	}
+	defer rc.Close()
+	data, err := content.ReadAll(rc, desc)
+	return desc, data, err
+}
+
+// manifestLabels returns the driverkit labels of a single platform image manifest,
+// merging its annotations with the image config labels.
+func (repo *RepoImagesLister) manifestLabels(ctx context.Context, data []byte) (platformLabels, error) {
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return platformLabels{}, err
+	}
+
+	// The image config is needed anyway for the architecture
+	rc, err := repo.Fetch(ctx, manifest.Config)
+	if err != nil {
+		return platformLabels{}, err
+	}
+	defer rc.Close()
+	configData, err := content.ReadAll(rc, manifest.Config)
+	if err != nil {
+		return platformLabels{}, err
+	}
+	var config ocispec.Image
+	if err = json.Unmarshal(configData, &config); err != nil {
+		return platformLabels{}, err
+	}
+
+	// Manifest annotations take precedence
+	return platformLabels{
+		Arch:   config.Architecture,
+		Labels: driverkitLabels(config.Config.Labels, manifest.Annotations),
+	}, nil
+}
+
+// driverkitLabels merges the driverkit labels from sources, with the latter ones taking precedence.
+func driverkitLabels(sources ...map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for _, source := range sources {
+		for k, v := range source {
+			if strings.HasPrefix(k, LabelPrefix) {
+				labels[k] = v
+			}
+		}
+	}
+	return labels
+}
+
+// capabilities returns the capabilities stated by the labels,
+// and false when they are not enough to use the image.
+func (p platformLabels) capabilities() (imageCapabilities, bool) {
+	arch, ok := kernelrelease.SupportedArchs[kernelrelease.Architecture(p.Arch)]
+	if !ok {
+		return imageCapabilities{}, false
+	}
+	caps := imageCapabilities{
+		target:     p.Labels[LabelTarget],
+		arch:       arch,
+		gccVers:    splitLabel(p.Labels[LabelGCCVersions]),
+		crossArchs: splitLabel(p.Labels[LabelCrossArchs]),
+	}
+	if caps.target == "" {
+		caps.target = "any"
+	}
+	for _, gccVer := range caps.gccVers {
+		if _, err := semver.ParseTolerant(gccVer); err != nil {
+			return imageCapabilities{}, false
+		}
+	}
+	for _, clangVer := range splitLabel(p.Labels[LabelClangVersions]) {
+		clang, err := semver.ParseTolerant(clangVer)
+		if err != nil {
+			return imageCapabilities{}, false
+		}
+		caps.clangs = append(caps.clangs, clang)
+	}
+	return caps, len(caps.gccVers) > 0
+}
+
+func splitLabel(value string) []string {
+	var res []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
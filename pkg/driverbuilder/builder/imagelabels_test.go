@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/docker/docker/testutil/registry"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+// registerContent serves data as a manifest or blob of the foo/test mock repository,
+// by digest and by the given tags, returning its descriptor.
+func registerContent(mock *registry.Mock, counter *int, mediaType, data string, tags ...string) string {
+	sum := sha256.Sum256([]byte(data))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		*counter++
+		w.Header().Set("Content-Type", mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Write([]byte(data))
+	}
+	kind := "manifests"
+	if mediaType == ocispec.MediaTypeImageConfig {
+		kind = "blobs"
+	}
+	mock.RegisterHandler("/v2/foo/test/"+kind+"/"+digest+"$", handler)
+	for _, tag := range tags {
+		mock.RegisterHandler("/v2/foo/test/manifests/"+tag+"$", handler)
+	}
+	return fmt.Sprintf(`{"mediaType": %q, "digest": %q, "size": %d}`, mediaType, digest, len(data))
+}
+
+func registerImage(mock *registry.Mock, counter *int, arch, labels, annotations string, tags ...string) string {
+	config := registerContent(mock, counter, ocispec.MediaTypeImageConfig,
+		fmt.Sprintf(`{"architecture": %q, "os": "linux", "config": {"Labels": {%s}}}`, arch, labels))
+	return registerContent(mock, counter, ocispec.MediaTypeImageManifest,
+		fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "config": %s, "layers": [], "annotations": {%s}}`, ocispec.MediaTypeImageManifest, config, annotations),
+		tags...)
+}
+
+func TestRepoImagesListerLabels(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	mock, err := registry.NewMock(t)
+	assert.NilError(t, err)
+	defer mock.Close()
+
+	var fetches int
+	mock.RegisterHandler("/v2/foo/test/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		// Tags not following the naming scheme, nor ending with the requested tag, never get fetched
+		w.Write([]byte(`{"name": "foo/test", "tags": [
+			"any-x86_64_gcc8.0.0-latest",
+			"any-x86_64-latest",
+			"labeled-latest",
+			"multi-latest",
+			"unlabeled-latest",
+			"labeled-master"
+		]}`))
+	})
+
+	// Single platform image, with target and gcc versions as annotations and clang versions as config labels
+	registerImage(mock, &fetches, "amd64",
+		`"org.falcosecurity.driverkit.clang-versions": "14.0.0"`,
+		`"org.falcosecurity.driverkit.target": "centos", "org.falcosecurity.driverkit.gcc-versions": "11.0.0, 10.0.0"`,
+		"labeled-latest")
+	// Image without any driverkit label
+	registerImage(mock, &fetches, "amd64", `"foo": "bar"`, "", "unlabeled-latest")
+	// Multi-arch image index: annotations on the index manifests, or config labels of the platform images
+	amd64 := registerImage(mock, &fetches, "amd64", "", "")
+	arm64 := registerImage(mock, &fetches, "arm64",
+		`"org.falcosecurity.driverkit.gcc-versions": "13.0.0"`, "")
+	amd64 = amd64[:len(amd64)-1] + `, "platform": {"architecture": "amd64", "os": "linux"}, "annotations": {"org.falcosecurity.driverkit.gcc-versions": "12.0.0"}}`
+	arm64 = arm64[:len(arm64)-1] + `, "platform": {"architecture": "arm64", "os": "linux"}}`
+	attestation := `{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:0000000000000000000000000000000000000000000000000000000000000000", "size": 1, "platform": {"architecture": "unknown", "os": "unknown"}}`
+	registerContent(mock, &fetches, ocispec.MediaTypeImageIndex,
+		fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "manifests": [%s, %s, %s]}`, ocispec.MediaTypeImageIndex, amd64, arm64, attestation),
+		"multi-latest")
+
+	newLister := func(all bool) *RepoImagesLister {
+		tagReg = nil
+		lister, err := NewRepoImagesLister(mock.URL()+"/foo/test", &Build{
+			TargetType:        Type("centos"),
+			Architecture:      "amd64",
+			BuilderImage:      "auto:latest",
+			RegistryPlainHTTP: true,
+			AllImages:         all,
+			ImagesCache:       &ImagesCache{Dir: t.TempDir(), TTL: time.Hour},
+		})
+		assert.NilError(t, err)
+		return lister
+	}
+	defer func() { tagReg = nil }()
+
+	img := func(tag, target, arch, gcc string, clangs ...semver.Version) Image {
+		return Image{
+			Name:          mock.URL() + "/foo/test:" + tag,
+			Target:        Type(target),
+			Arch:          arch,
+			GCCVersion:    semver.MustParse(gcc),
+			ClangVersions: clangs,
+		}
+	}
+	expected := []Image{
+		img("any-x86_64_gcc8.0.0-latest", "any", "x86_64", "8.0.0"),
+		img("labeled-latest", "centos", "x86_64", "11.0.0", semver.MustParse("14.0.0")),
+		img("labeled-latest", "centos", "x86_64", "10.0.0", semver.MustParse("14.0.0")),
+		img("multi-latest", "any", "x86_64", "12.0.0"),
+	}
+
+	lister := newLister(false)
+	assert.DeepEqual(t, expected, lister.LoadImages(printer))
+	assert.Assert(t, fetches > 0)
+
+	// Labels are cached too
+	fetches = 0
+	assert.DeepEqual(t, expected, lister.LoadImages(printer))
+	assert.Equal(t, fetches, 0)
+
+	// All the platforms are listed for any target and architecture
+	expected = append(expected, img("multi-latest", "any", "aarch64", "13.0.0"))
+	assert.DeepEqual(t, expected, newLister(true).LoadImages(printer))
+}
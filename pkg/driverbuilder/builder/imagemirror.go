@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// ImageMirror rewrites the images references starting with From, replacing it with To,
+// eg: docker.io/ with registry.internal/dockerhub/, to pull them from a registry mirror.
+type ImageMirror struct {
+	From string
+	To   string
+}
+
+// ParseImageMirror parses a <from>=<to> image mirror rule.
+func ParseImageMirror(rule string) (ImageMirror, error) {
+	from, to, ok := strings.Cut(rule, "=")
+	if !ok || from == "" || to == "" {
+		return ImageMirror{}, fmt.Errorf("invalid image mirror %q, expected <from>=<to>", rule)
+	}
+	return ImageMirror{From: from, To: to}, nil
+}
+
+// MirroredImage returns the image, or repository, reference rewritten by the first matching ImageMirrors rule.
+// References are normalized when needed, so that eg: falcosecurity/driverkit-builder matches a docker.io/ rule;
+// references already rewritten by a rule are left untouched.
+func (b *Build) MirroredImage(ref string) string {
+	normalized := ref
+	if named, err := reference.ParseNormalizedNamed(ref); err == nil {
+		normalized = named.String()
+	}
+	for _, mirror := range b.ImageMirrors {
+		if strings.HasPrefix(ref, mirror.To) || strings.HasPrefix(normalized, mirror.To) {
+			return ref
+		}
+		for _, r := range []string{ref, normalized} {
+			if strings.HasPrefix(r, mirror.From) {
+				return mirror.To + strings.TrimPrefix(r, mirror.From)
+			}
+		}
+	}
+	return ref
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestParseImageMirror(t *testing.T) {
+	mirror, err := ParseImageMirror("docker.io/=registry.internal/dockerhub/")
+	assert.NilError(t, err)
+	assert.Equal(t, mirror, ImageMirror{From: "docker.io/", To: "registry.internal/dockerhub/"})
+
+	for _, rule := range []string{"docker.io/", "=registry.internal/", "docker.io/="} {
+		_, err = ParseImageMirror(rule)
+		assert.ErrorContains(t, err, "invalid image mirror")
+	}
+}
+
+func TestMirroredImage(t *testing.T) {
+	b := &Build{ImageMirrors: []ImageMirror{
+		{From: "docker.io/falcosecurity/", To: "registry.internal/falco/"},
+		{From: "docker.io/", To: "registry.internal/dockerhub/"},
+		{From: "quay.io/", To: "quay.io/mirror/"},
+	}}
+
+	tests := map[string]string{
+		// builder repos and images, the first matching rule wins
+		"docker.io/falcosecurity/driverkit-builder":                             "registry.internal/falco/driverkit-builder",
+		"docker.io/falcosecurity/driverkit-builder:any-x86_64_gcc12.0.0-latest": "registry.internal/falco/driverkit-builder:any-x86_64_gcc12.0.0-latest",
+		"docker.io/myorg/driverkit-builder:any-x86_64_gcc12.0.0-latest":         "registry.internal/dockerhub/myorg/driverkit-builder:any-x86_64_gcc12.0.0-latest",
+		// docker hub references get normalized
+		"tonistiigi/binfmt": "registry.internal/dockerhub/tonistiigi/binfmt",
+		"ubuntu:22.04":      "registry.internal/dockerhub/library/ubuntu:22.04",
+		// already rewritten references are left untouched
+		"quay.io/mirror/foo/bar:latest": "quay.io/mirror/foo/bar:latest",
+		"quay.io/foo/bar:latest":        "quay.io/mirror/foo/bar:latest",
+		// references not matching any rule are left untouched
+		"ghcr.io/foo/bar:latest": "ghcr.io/foo/bar:latest",
+	}
+	for ref, expected := range tests {
+		assert.Equal(t, b.MirroredImage(ref), expected, ref)
+	}
+
+	// Without rules, references are never normalized
+	assert.Equal(t, (&Build{}).MirroredImage("tonistiigi/binfmt"), "tonistiigi/binfmt")
+
+	b.BuilderImage = "falcosecurity/driverkit-builder:centos-x86_64_gcc4.8.5-latest"
+	assert.Equal(t, b.GetBuilderImage(), "registry.internal/falco/driverkit-builder:centos-x86_64_gcc4.8.5-latest")
+}
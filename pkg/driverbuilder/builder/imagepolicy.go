@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"slices"
+
+	"github.com/blang/semver/v4"
+)
+
+// ImagePolicy tells how the builder image gcc version is selected for the target one, see setGCCVersion.
+type ImagePolicy string
+
+const (
+	// ImagePolicyNearestLower selects the nearest gcc lower than or equal to the target one,
+	// otherwise the lowest one, when every gcc is higher.
+	ImagePolicyNearestLower ImagePolicy = "nearest-lower"
+	// ImagePolicyNearest selects the nearest gcc, either lower or higher than the target one.
+	ImagePolicyNearest ImagePolicy = "nearest"
+	// ImagePolicyExact only selects the target gcc.
+	ImagePolicyExact ImagePolicy = "exact"
+	// ImagePolicyNewest selects the newest gcc, regardless of the target one.
+	ImagePolicyNewest ImagePolicy = "newest"
+)
+
+func (p ImagePolicy) String() string {
+	return string(p)
+}
+
+// ImageTieBreak is a rule breaking the ties between the gcc versions equally suiting the ImagePolicy, see selectGCCVersion.
+type ImageTieBreak string
+
+const (
+	// ImageTieBreakHigher prefers the higher gcc when a lower and a higher one are equally near to the target,
+	// instead of the lower one.
+	ImageTieBreakHigher ImageTieBreak = "higher"
+)
+
+func (t ImageTieBreak) String() string {
+	return string(t)
+}
+
+// imagePolicy returns the ImagePolicy, nearest-lower when unset.
+func (b *Build) imagePolicy() ImagePolicy {
+	if b.ImagePolicy == "" {
+		return ImagePolicyNearestLower
+	}
+	return b.ImagePolicy
+}
+
+// selectGCCVersion returns the gcc version of the loaded images selected by the ImagePolicy for targetGCC,
+// breaking the ties with the ImageTieBreaks; it returns false when none of them is suitable.
+// With ImageTargetOnly, only the target-specific images are candidates, when there are any.
+func (b *Build) selectGCCVersion(targetGCC semver.Version) (semver.Version, bool) {
+	images := b.Images
+	if b.ImageTargetOnly {
+		targetImages := make(ImagesMap)
+		for key, img := range b.Images {
+			if img.Target == b.TargetType {
+				targetImages[key] = img
+			}
+		}
+		if len(targetImages) > 0 {
+			images = targetImages
+		}
+	}
+
+	proposedGCCs := make([]semver.Version, 0, len(images))
+	for _, img := range images {
+		proposedGCCs = append(proposedGCCs, img.GCCVersion)
+		b.Logger.Debug("proposed GCC",
+			b.Logger.Args("image", img.Name,
+				"targetGCC", targetGCC.String(),
+				"proposedGCC", img.GCCVersion.String()))
+	}
+	if len(proposedGCCs) == 0 {
+		return semver.Version{}, false
+	}
+	semver.Sort(proposedGCCs)
+
+	switch b.imagePolicy() {
+	case ImagePolicyExact:
+		if slices.ContainsFunc(proposedGCCs, targetGCC.EQ) {
+			return targetGCC, true
+		}
+		return semver.Version{}, false
+	case ImagePolicyNewest:
+		return proposedGCCs[len(proposedGCCs)-1], true
+	case ImagePolicyNearest:
+		higher := slices.Contains(b.ImageTieBreaks, ImageTieBreakHigher)
+		nearest := proposedGCCs[0]
+		for _, gcc := range proposedGCCs[1:] {
+			d, nearestD := gccDistance(gcc, targetGCC), gccDistance(nearest, targetGCC)
+			// versions are sorted: on ties, gcc is the higher one
+			if d < nearestD || (d == nearestD && higher) {
+				nearest = gcc
+			}
+		}
+		return nearest, true
+	default:
+		// ImagePolicyNearestLower: the nearest gcc that is also <= targetGCC, otherwise the lowest one
+		lastGCC := proposedGCCs[0]
+		for _, gcc := range proposedGCCs {
+			if gcc.GT(targetGCC) {
+				break
+			}
+			lastGCC = gcc
+		}
+		return lastGCC, true
+	}
+}
+
+// gccDistance returns how far apart two gcc versions are, weighting the major version the most.
+func gccDistance(a, b semver.Version) uint64 {
+	toUint := func(v semver.Version) uint64 {
+		return v.Major*1000000 + v.Minor*1000 + v.Patch
+	}
+	if toUint(a) > toUint(b) {
+		return toUint(a) - toUint(b)
+	}
+	return toUint(b) - toUint(a)
+}
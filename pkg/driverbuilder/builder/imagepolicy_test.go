@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"os"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+func TestSelectGCCVersion(t *testing.T) {
+	images := ImagesMap{}
+	for _, img := range []Image{
+		{Target: TargetTypeUbuntu, GCCVersion: semver.MustParse("7.0.0")},
+		{Target: "any", GCCVersion: semver.MustParse("9.0.0")},
+		{Target: "any", GCCVersion: semver.MustParse("11.0.0")},
+		{Target: "any", GCCVersion: semver.MustParse("13.0.0")},
+	} {
+		images[img.toKey()] = img
+	}
+
+	tests := []struct {
+		name       string
+		policy     ImagePolicy
+		tieBreaks  []ImageTieBreak
+		targetOnly bool
+		targetGCC  string
+		expected   string
+	}{
+		{name: "default", targetGCC: "10.0.0", expected: "9.0.0"},
+		{name: "nearest-lower", policy: ImagePolicyNearestLower, targetGCC: "10.0.0", expected: "9.0.0"},
+		{name: "nearest-lower exact", policy: ImagePolicyNearestLower, targetGCC: "11.0.0", expected: "11.0.0"},
+		{name: "nearest-lower all higher", policy: ImagePolicyNearestLower, targetGCC: "5.0.0", expected: "7.0.0"},
+		{name: "nearest", policy: ImagePolicyNearest, targetGCC: "12.5.0", expected: "13.0.0"},
+		{name: "nearest tie", policy: ImagePolicyNearest, targetGCC: "12.0.0", expected: "11.0.0"},
+		{name: "nearest tie higher", policy: ImagePolicyNearest, tieBreaks: []ImageTieBreak{ImageTieBreakHigher}, targetGCC: "12.0.0", expected: "13.0.0"},
+		{name: "exact", policy: ImagePolicyExact, targetGCC: "11.0.0", expected: "11.0.0"},
+		{name: "exact missing", policy: ImagePolicyExact, targetGCC: "12.0.0"},
+		{name: "newest", policy: ImagePolicyNewest, targetGCC: "8.0.0", expected: "13.0.0"},
+		{name: "target only", targetOnly: true, targetGCC: "11.0.0", expected: "7.0.0"},
+		{name: "target only nearest", policy: ImagePolicyNearest, targetOnly: true, targetGCC: "13.0.0", expected: "7.0.0"},
+		{name: "target only exact missing", policy: ImagePolicyExact, targetOnly: true, targetGCC: "11.0.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &Build{
+				TargetType:      TargetTypeUbuntu,
+				Images:          images,
+				ImagePolicy:     test.policy,
+				ImageTieBreaks:  test.tieBreaks,
+				ImageTargetOnly: test.targetOnly,
+				Printer:         output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+			}
+			gcc, ok := b.selectGCCVersion(semver.MustParse(test.targetGCC))
+			assert.Equal(t, ok, test.expected != "")
+			if ok {
+				assert.Equal(t, gcc.String(), test.expected)
+			}
+		})
+	}
+
+	// Only targets without specific images fall back at the "any" ones
+	b := &Build{
+		TargetType:      TargetTypeCentos,
+		Images:          images,
+		ImageTargetOnly: true,
+		Printer:         output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+	}
+	gcc, ok := b.selectGCCVersion(semver.MustParse("11.0.0"))
+	assert.Assert(t, ok)
+	assert.Equal(t, gcc.String(), "11.0.0")
+}
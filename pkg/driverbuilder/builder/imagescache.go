@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/falcosecurity/falcoctl/pkg/output"
+)
+
+// ImagesCache stores on disk the tags listed from builder images repositories,
+// to avoid listing them again on each run.
+// Once the TTL elapses, repositories are listed again, but the labels fetched for their tags
+// are only fetched again when the tags point to new manifests, see cachedLabels.
+type ImagesCache struct {
+	Dir string
+	// TTL is the time after which a cached listing gets refreshed; 0 always refreshes it.
+	TTL time.Duration
+	// Offline never contacts the registries, using the cached listings regardless of their age.
+	Offline bool
+}
+
+type imagesCacheEntry struct {
+	Reference string    `json:"reference"`
+	Tags      []string  `json:"tags"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Labels holds the labels fetched for the tags not following the naming scheme, see fetchLabels.
+	Labels map[string]cachedLabels `json:"labels,omitempty"`
+	// ETag and Data hold the images index fetched by HTTPImagesLister.
+	ETag string `json:"etag,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// cachedLabels holds the labels of a tag, along with the digest of the manifest they were read from:
+// mutable tags may be pushed again with different labels.
+type cachedLabels struct {
+	Digest    string           `json:"digest"`
+	Platforms []platformLabels `json:"platforms"`
+}
+
+// DefaultImagesCacheDir returns the user cache directory for the builder images listings,
+// or an empty string when there is none.
+func DefaultImagesCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "driverkit", "images")
+}
+
+func (c *ImagesCache) path(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *ImagesCache) load(ref string) (*imagesCacheEntry, error) {
+	data, err := os.ReadFile(c.path(ref))
+	if err != nil {
+		return nil, err
+	}
+	var entry imagesCacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Reference != ref {
+		return nil, fmt.Errorf("cached listing is for %q", entry.Reference)
+	}
+	return &entry, nil
+}
+
+func (c *ImagesCache) store(entry *imagesCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that concurrent runs never read a partial listing
+	tmp, err := os.CreateTemp(c.Dir, ".tags-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(entry.Reference))
+}
+
+// fetchListingFunc fetches a listing again, given the cached one if any.
+type fetchListingFunc func(ctx context.Context, cached *imagesCacheEntry) (*imagesCacheEntry, error)
+
+// get returns the listing of ref, from the cache when still fresh, otherwise fetching it again.
+// When ref cannot be reached, the stale cached listing is used instead.
+func (c *ImagesCache) get(ctx context.Context, printer *output.Printer, ref string, fetch fetchListingFunc) (*imagesCacheEntry, error) {
+	if c == nil {
+		return fetch(ctx, nil)
+	}
+
+	entry, cacheErr := c.load(ref)
+	if cacheErr != nil && !errors.Is(cacheErr, os.ErrNotExist) {
+		printer.Logger.Debug("ignoring invalid cached images listing",
+			printer.Logger.Args("repo", ref, "err", cacheErr.Error()))
+	}
+	switch {
+	case c.Offline && entry == nil:
+		return nil, errors.New("no cached images listing available in offline mode")
+	case c.Offline:
+		printer.Logger.Debug("using cached images listing in offline mode",
+			printer.Logger.Args("repo", ref, "fetched", entry.FetchedAt.Format(time.RFC3339)))
+		return entry, nil
+	case entry != nil && time.Since(entry.FetchedAt) < c.TTL:
+		printer.Logger.Debug("using cached images listing",
+			printer.Logger.Args("repo", ref, "fetched", entry.FetchedAt.Format(time.RFC3339)))
+		return entry, nil
+	}
+
+	listing, err := fetch(ctx, entry)
+	if err != nil {
+		if entry == nil {
+			return nil, err
+		}
+		printer.Logger.Warn("using stale cached images listing",
+			printer.Logger.Args("repo", ref, "fetched", entry.FetchedAt.Format(time.RFC3339), "err", err.Error()))
+		return entry, nil
+	}
+	if err = c.store(listing); err != nil {
+		printer.Logger.Warn("error caching images listing",
+			printer.Logger.Args("repo", ref, "err", err.Error()))
+	}
+	return listing, nil
+}
+
+// refresh fetches the listing of ref again, regardless of the cached listing age.
+func (c *ImagesCache) refresh(ctx context.Context, ref string, fetch fetchListingFunc) error {
+	if c == nil {
+		return errors.New("images cache disabled")
+	}
+	if c.Offline {
+		return errors.New("cannot refresh the images cache in offline mode")
+	}
+	cached, _ := c.load(ref)
+	listing, err := fetch(ctx, cached)
+	if err != nil {
+		return err
+	}
+	return c.store(listing)
+}
+
+// refreshableImagesLister is implemented by the images listers caching their listings.
+type refreshableImagesLister interface {
+	ImagesLister
+	// Refresh fetches the listing again, regardless of the cached listing age.
+	Refresh(ctx context.Context) error
+	source() string
+}
+
+// listing returns the repository tags listing, see ImagesCache.get.
+func (repo *RepoImagesLister) listing(ctx context.Context, printer *output.Printer) (*imagesCacheEntry, error) {
+	return repo.Cache.get(ctx, printer, repo.source(), repo.list)
+}
+
+func (repo *RepoImagesLister) list(ctx context.Context, cached *imagesCacheEntry) (*imagesCacheEntry, error) {
+	tags, err := repo.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := &imagesCacheEntry{
+		Reference: repo.source(),
+		Tags:      tags,
+		FetchedAt: time.Now(),
+	}
+	if cached == nil {
+		return entry, nil
+	}
+	// Keep the labels of the tags still pointing to the same manifest, resolving them is cheaper than fetching them
+	for tag, labels := range cached.Labels {
+		if !slices.Contains(tags, tag) {
+			continue
+		}
+		desc, err := repo.Resolve(ctx, tag)
+		if err != nil || desc.Digest.String() != labels.Digest {
+			continue
+		}
+		if entry.Labels == nil {
+			entry.Labels = make(map[string]cachedLabels)
+		}
+		entry.Labels[tag] = labels
+	}
+	return entry, nil
+}
+
+func (repo *RepoImagesLister) Refresh(ctx context.Context) error {
+	return repo.Cache.refresh(ctx, repo.source(), repo.list)
+}
+
+func (repo *RepoImagesLister) source() string {
+	return repo.Reference.String()
+}
+
+// RefreshImagesCache refreshes the cached listings of all the builder repos.
+func (b *Build) RefreshImagesCache() error {
+	var errs []error
+	for _, imagesLister := range b.ImagesListers {
+		lister, ok := imagesLister.(refreshableImagesLister)
+		if !ok {
+			continue
+		}
+		if err := lister.Refresh(context.Background()); err != nil {
+			errs = append(errs, fmt.Errorf("repo %s: %w", lister.source(), err))
+			continue
+		}
+		b.Printer.Logger.Info("refreshed images listing",
+			b.Printer.Logger.Args("repo", lister.source()))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/testutil/registry"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+func TestImagesCache(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	mock, err := registry.NewMock(t)
+	assert.NilError(t, err)
+	defer mock.Close()
+
+	var (
+		calls   int
+		tagsErr bool
+		tags    = `{"name": "foo/test", "tags": ["any-x86_64_gcc12.0.0-latest"]}`
+	)
+	mock.RegisterHandler("/v2/foo/test/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if tagsErr {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(tags))
+	})
+
+	cache := &ImagesCache{Dir: t.TempDir(), TTL: time.Hour}
+	tagReg = nil
+	defer func() { tagReg = nil }()
+	lister, err := NewRepoImagesLister(mock.URL()+"/foo/test", &Build{
+		TargetType:        Type("centos"),
+		Architecture:      "amd64",
+		BuilderImage:      "auto:latest",
+		RegistryPlainHTTP: true,
+		ImagesCache:       cache,
+	})
+	assert.NilError(t, err)
+	ctx := context.Background()
+
+	// Offline mode cannot work without a cached listing
+	cache.Offline = true
+	_, err = lister.listing(ctx, printer)
+	assert.ErrorContains(t, err, "offline")
+	assert.Equal(t, calls, 0)
+	cache.Offline = false
+
+	res, err := lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res.Tags, []string{"any-x86_64_gcc12.0.0-latest"})
+	assert.Equal(t, calls, 1)
+
+	// Fresh cached listing is used
+	tags = `{"name": "foo/test", "tags": ["any-x86_64_gcc13.0.0-latest"]}`
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res.Tags, []string{"any-x86_64_gcc12.0.0-latest"})
+	assert.Equal(t, calls, 1)
+
+	// Expired cached listing is refreshed
+	cache.TTL = 0
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res.Tags, []string{"any-x86_64_gcc13.0.0-latest"})
+	assert.Equal(t, calls, 2)
+
+	// Stale cached listing is used when the registry fails
+	tagsErr = true
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res.Tags, []string{"any-x86_64_gcc13.0.0-latest"})
+	assert.Assert(t, lister.Refresh(ctx) != nil)
+
+	// Offline mode uses the cached listing regardless of its age, without contacting the registry
+	tagsErr = false
+	cache.Offline = true
+	calls = 0
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res.Tags, []string{"any-x86_64_gcc13.0.0-latest"})
+	assert.Equal(t, calls, 0)
+	cache.Offline = false
+
+	// Refresh ignores the TTL
+	cache.TTL = time.Hour
+	tags = `{"name": "foo/test", "tags": ["any-x86_64_gcc14.0.0-latest"]}`
+	assert.NilError(t, lister.Refresh(ctx))
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, res.Tags, []string{"any-x86_64_gcc14.0.0-latest"})
+	assert.Equal(t, calls, 1)
+
+	// Labels are kept while their tag points to the same manifest, and dropped as soon as it is pushed again
+	manifestDigest := "sha256:" + strings.Repeat("1", 64)
+	mock.RegisterHandler("/v2/foo/test/manifests/latest$", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", manifestDigest)
+		w.Write([]byte("{}"))
+	})
+	tags = `{"name": "foo/test", "tags": ["any-x86_64_gcc14.0.0-latest", "latest"]}`
+	res.Tags = []string{"any-x86_64_gcc14.0.0-latest", "latest"}
+	res.Labels = map[string]cachedLabels{"latest": {Digest: manifestDigest, Platforms: []platformLabels{{Arch: "amd64"}}}}
+	assert.NilError(t, cache.store(res))
+	assert.NilError(t, lister.Refresh(ctx))
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.Labels), 1)
+	manifestDigest = "sha256:" + strings.Repeat("2", 64)
+	assert.NilError(t, lister.Refresh(ctx))
+	res, err = lister.listing(ctx, printer)
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.Labels), 0)
+}
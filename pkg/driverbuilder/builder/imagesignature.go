@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// SignaturePolicy tells what to do when a builder image signature cannot be verified.
+type SignaturePolicy string
+
+const (
+	// SignaturePolicyWarn logs a warning and uses the image anyway.
+	SignaturePolicyWarn SignaturePolicy = "warn"
+	// SignaturePolicyEnforce refuses to use the image.
+	SignaturePolicyEnforce SignaturePolicy = "enforce"
+)
+
+func (p SignaturePolicy) String() string {
+	return string(p)
+}
+
+const (
+	// cosignSignatureAnnotation holds the base64 signature of a cosign signature layer.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// cosignSimpleSigningMediaType is the media type of the cosign signature layers, holding the signed payload.
+	cosignSimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+)
+
+// cosignPayload is the payload signed by cosign, stating the signed image digest.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// VerifyBuilderImage verifies the cosign-compatible signature of a builder image pinned to its digest,
+// as returned by PinnedBuilderImage, against the ImageSignatureKey public key.
+// Signatures are stored alongside the image in its repository, under the sha256-<hex>.sig tag.
+// When the signature cannot be verified, an error is only returned with the enforce policy.
+func (b *Build) VerifyBuilderImage(image string) error {
+	if b.ImageSignatureKey == "" {
+		return nil
+	}
+	err := b.verifyImageSignature(context.Background(), image)
+	if err == nil {
+		b.Logger.Info("verified builder image signature",
+			b.Logger.Args("image", image))
+		return nil
+	}
+	if b.ImageSignaturePolicy == SignaturePolicyWarn {
+		b.Logger.Warn("cannot verify builder image signature, using it anyway",
+			b.Logger.Args("image", image, "err", err.Error()))
+		return nil
+	}
+	return fmt.Errorf("cannot verify builder image %s signature: %w", image, err)
+}
+
+func (b *Build) verifyImageSignature(ctx context.Context, image string) error {
+	pub, err := loadPublicKey(b.ImageSignatureKey)
+	if err != nil {
+		return err
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+	digested, ok := named.(reference.Digested)
+	if !ok {
+		return errors.New("image not pinned to a digest")
+	}
+	dgst := digested.Digest()
+
+	repo, err := b.imageRepository(named)
+	if err != nil {
+		return err
+	}
+	sigTag := strings.Replace(dgst.String(), ":", "-", 1) + ".sig"
+	desc, rc, err := repo.FetchReference(ctx, sigTag)
+	if err != nil {
+		return fmt.Errorf("cannot fetch signature %s: %w", sigTag, err)
+	}
+	defer rc.Close()
+	data, err := content.ReadAll(rc, desc)
+	if err != nil {
+		return err
+	}
+	var manifest ocispec.Manifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+
+	// Any of the signatures, eg: one for each signing key, is enough
+	for _, layer := range manifest.Layers {
+		sig, ok := layer.Annotations[cosignSignatureAnnotation]
+		if layer.MediaType != cosignSimpleSigningMediaType || !ok {
+			continue
+		}
+		payload, err := fetchBlob(ctx, repo, layer)
+		if err != nil {
+			return err
+		}
+		if err = verifyCosignPayload(pub, payload, sig, dgst.String()); err == nil {
+			return nil
+		}
+		b.Logger.Debug("signature not matching",
+			b.Logger.Args("image", image, "err", err.Error()))
+	}
+	return errors.New("no signature matching the public key")
+}
+
+func fetchBlob(ctx context.Context, repo content.Fetcher, desc ocispec.Descriptor) ([]byte, error) {
+	rc, err := repo.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return content.ReadAll(rc, desc)
+}
+
+// verifyCosignPayload verifies the base64 signature of a cosign payload, that must state the image digest.
+func verifyCosignPayload(pub crypto.PublicKey, payload []byte, signature, digest string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, hash[:], sig) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig); err != nil {
+			return err
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, sig) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	// The payload is only trusted once its signature is verified
+	var p cosignPayload
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for digest %s", p.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+// loadPublicKey loads a PEM encoded public key, eg: a cosign.pub one.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded public key in %s", path)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
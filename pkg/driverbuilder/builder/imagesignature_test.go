@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/testutil/registry"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+)
+
+// writePublicKey writes the PEM encoded public key of a new ECDSA key, like cosign.pub, returning the private key.
+func writePublicKey(t *testing.T, path string) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644))
+	return key
+}
+
+// registerSignature serves a cosign signature of the given digest, as signed by key, under the image signature tag.
+func registerSignature(t *testing.T, mock *registry.Mock, counter *int, key *ecdsa.PrivateKey, imageDigest, signedDigest string) {
+	payload := fmt.Sprintf(`{"critical": {"identity": {"docker-reference": "foo/test"}, "image": {"docker-manifest-digest": %q}, "type": "cosign container image signature"}, "optional": null}`, signedDigest)
+	hash := sha256.Sum256([]byte(payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	assert.NilError(t, err)
+
+	var layer ocispec.Descriptor
+	assert.NilError(t, json.Unmarshal([]byte(registerContent(mock, counter, ocispec.MediaTypeImageConfig, payload)), &layer))
+	layer.MediaType = cosignSimpleSigningMediaType
+	layer.Annotations = map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	layerData, err := json.Marshal(layer)
+	assert.NilError(t, err)
+	config := registerContent(mock, counter, ocispec.MediaTypeImageConfig, `{}`)
+	registerContent(mock, counter, ocispec.MediaTypeImageManifest,
+		fmt.Sprintf(`{"schemaVersion": 2, "mediaType": %q, "config": %s, "layers": [%s]}`, ocispec.MediaTypeImageManifest, config, layerData),
+		strings.Replace(imageDigest, ":", "-", 1)+".sig")
+}
+
+func TestVerifyBuilderImage(t *testing.T) {
+	printer := output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+
+	mock, err := registry.NewMock(t)
+	assert.NilError(t, err)
+	defer mock.Close()
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "cosign.pub")
+	key := writePublicKey(t, keyFile)
+	otherKeyFile := filepath.Join(dir, "other.pub")
+	writePublicKey(t, otherKeyFile)
+
+	digest := func(i int) string {
+		return fmt.Sprintf("sha256:%064d", i)
+	}
+	var fetches int
+	// Image 1 is signed, image 2 carries the signature of image 1, image 3 is not signed
+	registerSignature(t, mock, &fetches, key, digest(1), digest(1))
+	registerSignature(t, mock, &fetches, key, digest(2), digest(1))
+	mock.RegisterHandler("/v2/foo/test/manifests/"+strings.Replace(digest(3), ":", "-", 1)+".sig$", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	tests := map[string]struct {
+		image       string
+		key         string
+		expectedErr string
+	}{
+		"signed": {
+			image: mock.URL() + "/foo/test@" + digest(1),
+			key:   keyFile,
+		},
+		"signed with another key": {
+			image:       mock.URL() + "/foo/test@" + digest(1),
+			key:         otherKeyFile,
+			expectedErr: "no signature matching the public key",
+		},
+		"signature of another digest": {
+			image:       mock.URL() + "/foo/test@" + digest(2),
+			key:         keyFile,
+			expectedErr: "no signature matching the public key",
+		},
+		"not signed": {
+			image:       mock.URL() + "/foo/test@" + digest(3),
+			key:         keyFile,
+			expectedErr: "cannot fetch signature",
+		},
+		"not pinned": {
+			image:       mock.URL() + "/foo/test:any-x86_64_gcc12.0.0-latest",
+			key:         keyFile,
+			expectedErr: "image not pinned to a digest",
+		},
+		"no key": {
+			image: mock.URL() + "/foo/test@" + digest(3),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &Build{
+				RegistryPlainHTTP:    true,
+				ImageSignatureKey:    test.key,
+				ImageSignaturePolicy: SignaturePolicyEnforce,
+				Printer:              printer,
+			}
+			err := b.VerifyBuilderImage(test.image)
+			if test.expectedErr == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, test.expectedErr)
+
+			// The warn policy uses the image anyway
+			b.ImageSignaturePolicy = SignaturePolicyWarn
+			assert.NilError(t, b.VerifyBuilderImage(test.image))
+		})
+	}
+}
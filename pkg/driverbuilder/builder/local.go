@@ -61,8 +61,10 @@ func (l *LocalBuilder) TemplateData(c Config, kr kernelrelease.KernelRelease) in
 			ModuleDriverName: c.DriverName,
 			ModuleFullPath:   l.GetModuleFullPath(c, kr),
 			BuildModule:      len(c.ModuleFilePath) > 0,
+			BuildProbe:       len(c.ProbeFilePath) > 0,
 			GCCVersion:       l.GccPath,
 			CmakeCmd: fmt.Sprintf(cmakeCmdFmt,
+				c.cmakeBuildBPF(),
 				c.DriverName,
 				c.DriverName,
 				c.DriverVersion,
@@ -90,6 +92,13 @@ func (l *LocalBuilder) GetModuleFullPath(c Config, kr kernelrelease.KernelReleas
 	return c.ToDriverFullPath()
 }
 
+func (l *LocalBuilder) GetProbeFullPath(c Config) string {
+	if l.SrcDir != "" {
+		return filepath.Join(l.SrcDir, "bpf", "probe.o")
+	}
+	return c.ToProbeFullPath()
+}
+
 func (l *LocalBuilder) GetDriverBuildDir() string {
 	driverBuildDir := DriverDirectory
 	if l.SrcDir != "" {
@@ -54,3 +54,8 @@ func (m *minikube) GCCVersion(kr kernelrelease.KernelRelease) semver.Version {
 		return semver.Version{Major: 12}
 	}
 }
+
+// Minikube kernels cannot be told apart from vanilla ones.
+func (m *minikube) RecognizeKernelRelease(_ kernelrelease.KernelRelease) bool {
+	return false
+}
@@ -46,6 +46,12 @@ var baseURLs []string = []string{
 	"http://download.opensuse.org",
 }
 
+// ports base URLs, for architectures whose tumbleweed packages are not part of the distribution
+var portsBaseURLs = map[kernelrelease.Architecture]string{
+	kernelrelease.ArchitecturePpc64le: "http://download.opensuse.org/ports/ppc",
+	kernelrelease.ArchitectureS390x:   "http://download.opensuse.org/ports/zsystems",
+}
+
 // all known releases - will need to expand as more are added
 var releases = []string{
 	// openSUSE leap
@@ -92,177 +98,48 @@ func (o *opensuse) TemplateScript() string {
 }
 
 func (o *opensuse) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
+	return fetchRPMKernelURLs(o, kr)
+}
 
-	// SUSE requires 2 urls: a kernel-default-devel*{arch}.rpm and a kernel-devel*noarch.rpm
-	kernelDefaultDevelPattern := fmt.Sprintf("kernel-default-devel-%s%s.rpm", kr.Fullversion, kr.FullExtraversion)
-	kernelDevelNoArchPattern := strings.ReplaceAll( // need to replace architecture string with "noarch"
-		fmt.Sprintf("kernel-devel-%s%s.rpm", kr.Fullversion, kr.FullExtraversion),
-		kr.Architecture.ToNonDeb(),
-		"noarch",
-	)
-
-	// get all possible URLs
-	possibleURLs := buildURLs(kr, kernelDefaultDevelPattern, kernelDevelNoArchPattern)
-
-	// trim the list to only resolving URLs
-	urls, err := GetResolvingURLs(possibleURLs)
-	if err != nil {
-		return nil, err
-	}
-
-	// ensure there is at least one URL of each required package type
-	if validateURLs(urls, kernelDefaultDevelPattern, kernelDevelNoArchPattern) {
-		return urls, nil
-	} else {
-		return nil, fmt.Errorf(
-			"missing one of the required package types: [ kernel-default-devel, kernel-devel*noarch ]: %v",
-			urls,
-		)
+// SUSE requires 2 packages: a kernel-default-devel {arch} one and a kernel-devel noarch one
+func (o *opensuse) rpmPackages(kr kernelrelease.KernelRelease) []string {
+	// the kernel flavor, eg: "default", follows the RPM release;
+	// without it the headers packages cannot be named
+	_, flavor, ok := strings.Cut(strings.TrimPrefix(kr.FullExtraversion, "-"), "-")
+	if !ok || flavor == "" {
+		return nil
 	}
+	return []string{fmt.Sprintf("kernel-%s-devel", flavor), "kernel-devel"}
 }
 
-// build all possible url combinations from base URLs and releases
-func buildURLs(kr kernelrelease.KernelRelease, kernelDefaultDevelPattern string, kernelDevelNoArchPattern string) []string {
+// build all possible repositories combinations from base URLs and releases
+func (o *opensuse) rpmRepos(kr kernelrelease.KernelRelease) []string {
+	archBaseURLs := baseURLs
+	if portsBaseURL, ok := portsBaseURLs[kr.Architecture]; ok {
+		archBaseURLs = append([]string{portsBaseURL}, baseURLs...)
+	}
 
-	possibleURLs := []string{}
+	repos := []string{}
 	for _, release := range releases {
-		for _, baseURL := range baseURLs {
-
-			possibleURLs = append(
-				possibleURLs,
-				// leap urls
-				fmt.Sprintf(
-					"%s/leap/%s/repo/oss/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf( // noarch
-					"%s/leap/%s/repo/oss/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				// other urls
-				fmt.Sprintf(
-					"%s/%s/repo/oss/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf( // noarch
-					"%s/%s/repo/oss/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				// weird opensuse site urls
-				fmt.Sprintf(
-					"%s/openSUSE-%s/Submit/standard/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf(
-					"%s/openSUSE-%s/standard/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf(
-					"%s/openSUSE-%s:/Submit/standard/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf(
-					"%s/openSUSE-%s:/standard/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf(
-					"%s/%s/Submit/standard/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				fmt.Sprintf(
-					"%s/%s/standard/%s/%s",
-					baseURL,
-					release,
-					kr.Architecture.ToNonDeb(),
-					kernelDefaultDevelPattern,
-				),
-				// weird opensuse site urls - kernel-devel*noarch edition
-				fmt.Sprintf(
-					"%s/openSUSE-%s/Submit/standard/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				fmt.Sprintf(
-					"%s/openSUSE-%s/standard/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				fmt.Sprintf(
-					"%s/openSUSE-%s:/Submit/standard/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				fmt.Sprintf(
-					"%s/openSUSE-%s:/standard/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				fmt.Sprintf(
-					"%s/%s/Submit/standard/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
-				fmt.Sprintf(
-					"%s/%s/standard/noarch/%s",
-					baseURL,
-					release,
-					kernelDevelNoArchPattern,
-				),
+		for _, baseURL := range archBaseURLs {
+			repos = append(
+				repos,
+				// leap repositories
+				fmt.Sprintf("%s/leap/%s/repo/oss", baseURL, release),
+				// other repositories
+				fmt.Sprintf("%s/%s/repo/oss", baseURL, release),
+				// weird opensuse site repositories
+				fmt.Sprintf("%s/openSUSE-%s/Submit/standard", baseURL, release),
+				fmt.Sprintf("%s/openSUSE-%s/standard", baseURL, release),
+				fmt.Sprintf("%s/openSUSE-%s:/Submit/standard", baseURL, release),
+				fmt.Sprintf("%s/openSUSE-%s:/standard", baseURL, release),
+				fmt.Sprintf("%s/%s/Submit/standard", baseURL, release),
+				fmt.Sprintf("%s/%s/standard", baseURL, release),
 			)
 		}
 	}
 
-	return possibleURLs
-}
-
-// check to ensure there is at least one URL of each package type
-func validateURLs(urls []string, kernelDefaultDevelPattern string, kernelDevelNoArchPattern string) bool {
-
-	// setup some flags
-	kernelDefaultDevelFlag := false
-	kernelDevelNoArchFlag := false
-
-	for _, url := range urls {
-		if strings.Contains(url, kernelDefaultDevelPattern) {
-			kernelDefaultDevelFlag = true
-		}
-		if strings.Contains(url, kernelDevelNoArchPattern) {
-			kernelDevelNoArchFlag = true
-		}
-	}
-
-	return kernelDefaultDevelFlag && kernelDevelNoArchFlag
-
+	return repos
 }
 
 func (o *opensuse) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -270,3 +147,7 @@ func (o *opensuse) KernelTemplateData(_ kernelrelease.KernelRelease, urls []stri
 		KernelDownloadURLs: urls,
 	}
 }
+
+func (o *opensuse) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return strings.HasSuffix(kr.FullExtraversion, "-default")
+}
@@ -17,7 +17,7 @@ package builder
 import (
 	_ "embed"
 	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 )
@@ -56,73 +56,64 @@ func (c *oracle) TemplateScript() string {
 }
 
 func (c *oracle) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
-
 	// oracle FullExtraversion looks like "-2047.510.5.5.el7uek.x86_64"
-	// need to get the "el7uek" out of the middle
-	splitVersion := strings.Split(kr.FullExtraversion, ".")
-	oracleVersion := splitVersion[len(splitVersion)-2] // [ "-2047", "510", "5", "5", "el7uek","x86_64" ] want -2
-
-	// trim off the "el" and "uek" from oracleVersion
-	version := strings.Trim(strings.Trim(oracleVersion, "el"), "uek")
+	// only the "7" major is needed, as Oracle 8 may also carry a minor ("el8_x")
+	if _, ok := kr.EnterpriseLinux(); !ok {
+		return nil, fmt.Errorf("unable to find oracle release in kernel release %s", kr.String())
+	}
+	return fetchRPMKernelURLs(c, kr)
+}
 
-	// sometimes Oracle 8 does "8_x" for version, only want the "8"
-	if strings.Contains(version, "_") {
-		version = strings.Split(version, "_")[0]
+func (c *oracle) rpmPackages(kr kernelrelease.KernelRelease) []string {
+	if el, _ := kr.EnterpriseLinux(); el.UEK {
+		return []string{"kernel-uek-devel"}
 	}
+	return []string{"kernel-devel"}
+}
 
-	// list of possible UEK versions, which are used in the URL - ex: "UEKR3"
-	// may need to evolve over time if Oracle adds more
-	ueks := []string{"R3", "R4", "R5", "R6", "R7"}
+func (c *oracle) rpmRepos(kr kernelrelease.KernelRelease) []string {
+	el, _ := kr.EnterpriseLinux()
+	version := strconv.FormatUint(el.Major, 10)
 
-	// template the kernel info into all possible URL strings
-	urls := []string{
+	if el.UEK {
+		// list of possible UEK versions, which are used in the URL - ex: "UEKR3"
+		// may need to evolve over time if Oracle adds more
+		ueks := []string{"R3", "R4", "R5", "R6", "R7"}
+
+		repos := []string{}
+		for _, uekVers := range ueks {
+			repos = append(repos, fmt.Sprintf( // UEK versions URL
+				"http://yum.oracle.com/repo/OracleLinux/OL%s/UEK%s/%s",
+				version,
+				uekVers,
+				kr.Architecture.ToNonDeb(),
+			))
+		}
+		return repos
+	}
+
+	return []string{
 		fmt.Sprintf( // latest (Oracle 7)
-			"http://yum.oracle.com/repo/OracleLinux/OL%s/latest/%s/getPackage/kernel-devel-%s%s.rpm",
+			"http://yum.oracle.com/repo/OracleLinux/OL%s/latest/%s",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 		fmt.Sprintf( // latest + baseos (Oracle 8 + 9)
-			"http://yum.oracle.com/repo/OracleLinux/OL%s/baseos/latest/%s/getPackage/kernel-devel-%s%s.rpm",
+			"http://yum.oracle.com/repo/OracleLinux/OL%s/baseos/latest/%s",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 		fmt.Sprintf( // appstream (Oracle 8 + 9)
-			"http://yum.oracle.com/repo/OracleLinux/OL%s/appstream/%s/getPackage/kernel-devel-%s%s.rpm",
+			"http://yum.oracle.com/repo/OracleLinux/OL%s/appstream/%s",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 		fmt.Sprintf( // MODRHCK (Oracle 7)
-			"http://yum.oracle.com/repo/OracleLinux/OL%s/MODRHCK/%s/getPackage/kernel-devel-%s%s.rpm",
+			"http://yum.oracle.com/repo/OracleLinux/OL%s/MODRHCK/%s",
 			version,
 			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
 		),
 	}
-
-	// add in all the UEK versions
-	for _, uekVers := range ueks {
-		urls = append(
-			urls,
-			fmt.Sprintf( // UEK versions URL
-				"http://yum.oracle.com/repo/OracleLinux/OL%s/UEK%s/%s/getPackage/kernel-uek-devel-%s%s.rpm",
-				version,
-				uekVers,
-				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
-			),
-		)
-	}
-
-	// return out all possible urls
-	return urls, nil
 }
 
 func (c *oracle) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -130,3 +121,9 @@ func (c *oracle) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string
 		KernelDownloadURL: urls[0],
 	}
 }
+
+// Only UEK kernels can be told apart from the other RHEL-like ones.
+func (c *oracle) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	el, ok := kr.EnterpriseLinux()
+	return ok && el.UEK
+}
@@ -17,6 +17,8 @@ package builder
 import (
 	_ "embed"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 )
@@ -55,7 +57,17 @@ func (p *photon) TemplateScript() string {
 }
 
 func (p *photon) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
-	return fetchPhotonKernelURLS(kr), nil
+	return fetchRPMKernelURLs(p, kr)
+}
+
+// rpmPackages returns the flavor devel package, if any, eg: "linux-esx-devel" for "4.19.225-3.ph3-esx".
+func (p *photon) rpmPackages(kr kernelrelease.KernelRelease) []string {
+	if _, flavor, ok := strings.Cut(kr.FullExtraversion, ".ph"); ok {
+		if _, flavor, ok = strings.Cut(flavor, "-"); ok {
+			return []string{"linux-" + flavor + "-devel"}
+		}
+	}
+	return []string{"linux-devel"}
 }
 
 func (p *photon) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -64,42 +76,26 @@ func (p *photon) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string
 	}
 }
 
-func fetchPhotonKernelURLS(kr kernelrelease.KernelRelease) []string {
+func (p *photon) rpmRepos(kr kernelrelease.KernelRelease) []string {
 	photonReleases := []string{
 		"3.0",
 		"4.0",
 		"5.0",
 	}
 
-	var urls []string
+	var repos []string
 	for _, r := range photonReleases {
-		urls = append(urls, fmt.Sprintf(
-			"https://packages.vmware.com/photon/%s/photon_%s_%s/%s/linux-devel-%s%s.x86_64.rpm",
-			r,
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
-		urls = append(urls, fmt.Sprintf(
-			"https://packages.vmware.com/photon/%s/photon_release_%s_%s/%s/linux-devel-%s%s.x86_64.rpm",
-			r,
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
-		urls = append(urls, fmt.Sprintf(
-			"https://packages.vmware.com/photon/%s/photon_updates_%s_%s/%s/linux-devel-%s%s.x86_64.rpm",
-			r,
-			r,
-			kr.Architecture.ToNonDeb(),
-			kr.Architecture.ToNonDeb(),
-			kr.Fullversion,
-			kr.FullExtraversion,
-		))
+		repos = append(repos,
+			fmt.Sprintf("https://packages.vmware.com/photon/%s/photon_%s_%s", r, r, kr.Architecture.ToNonDeb()),
+			fmt.Sprintf("https://packages.vmware.com/photon/%s/photon_release_%s_%s", r, r, kr.Architecture.ToNonDeb()),
+			fmt.Sprintf("https://packages.vmware.com/photon/%s/photon_updates_%s_%s", r, r, kr.Architecture.ToNonDeb()),
+		)
 	}
-	return urls
+	return repos
+}
+
+var photonReleaseRegex = regexp.MustCompile(`\.ph\d+(-[a-z]+)?$`)
+
+func (p *photon) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return photonReleaseRegex.MatchString(kr.FullExtraversion)
 }
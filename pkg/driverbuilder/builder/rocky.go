@@ -55,7 +55,11 @@ func (c *rocky) TemplateScript() string {
 }
 
 func (c *rocky) URLs(kr kernelrelease.KernelRelease) ([]string, error) {
-	return fetchRockyKernelURLS(kr), nil
+	return fetchRPMKernelURLs(c, kr)
+}
+
+func (c *rocky) rpmPackages(_ kernelrelease.KernelRelease) []string {
+	return []string{"kernel-devel"}
 }
 
 func (c *rocky) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string) interface{} {
@@ -64,7 +68,7 @@ func (c *rocky) KernelTemplateData(_ kernelrelease.KernelRelease, urls []string)
 	}
 }
 
-func fetchRockyKernelURLS(kr kernelrelease.KernelRelease) []string {
+func (c *rocky) rpmRepos(kr kernelrelease.KernelRelease) []string {
 	rockyReleases := []string{
 		"8",
 		"8.7",
@@ -80,44 +84,41 @@ func fetchRockyKernelURLS(kr kernelrelease.KernelRelease) []string {
 		"9.1",
 	}
 
-	urls := []string{}
+	repos := []string{}
 	for _, r := range rockyReleases {
 		if r >= "9" {
-			urls = append(urls, fmt.Sprintf(
-				"https://download.rockylinux.org/pub/rocky/%s/AppStream/%s/os/Packages/k/kernel-devel-%s%s.rpm",
+			repos = append(repos, fmt.Sprintf(
+				"https://download.rockylinux.org/pub/rocky/%s/AppStream/%s/os",
 				r,
 				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
 			))
 		} else {
-			urls = append(urls, fmt.Sprintf(
-				"https://download.rockylinux.org/pub/rocky/%s/BaseOS/%s/os/Packages/k/kernel-devel-%s%s.rpm",
+			repos = append(repos, fmt.Sprintf(
+				"https://download.rockylinux.org/pub/rocky/%s/BaseOS/%s/os",
 				r,
 				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
 			))
 		}
 	}
 	for _, r := range rockyVaultReleases {
 		if r >= "9" {
-			urls = append(urls, fmt.Sprintf(
-				"https://download.rockylinux.org/vault/rocky/%s/AppStream/%s/os/Packages/k/kernel-devel-%s%s.rpm",
+			repos = append(repos, fmt.Sprintf(
+				"https://download.rockylinux.org/vault/rocky/%s/AppStream/%s/os",
 				r,
 				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
 			))
 		} else {
-			urls = append(urls, fmt.Sprintf(
-				"https://download.rockylinux.org/vault/rocky/%s/BaseOS/%s/os/Packages/k/kernel-devel-%s%s.rpm",
+			repos = append(repos, fmt.Sprintf(
+				"https://download.rockylinux.org/vault/rocky/%s/BaseOS/%s/os",
 				r,
 				kr.Architecture.ToNonDeb(),
-				kr.Fullversion,
-				kr.FullExtraversion,
 			))
 		}
 	}
-	return urls
+	return repos
+}
+
+func (c *rocky) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	el, ok := kr.EnterpriseLinux()
+	return ok && !el.UEK && el.HasMinor && el.Major >= 8
 }
@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	_ "modernc.org/sqlite"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// rpmBuilder is implemented by the builders of RPM based targets, whose kernel headers packages
+// are found in their repositories metadata, see fetchRPMKernelURLs.
+type rpmBuilder interface {
+	Builder
+	// rpmRepos returns the base URLs of the repositories to look for the kernel headers packages into, in order,
+	// ie: the ones holding "repodata/repomd.xml".
+	rpmRepos(kr kernelrelease.KernelRelease) []string
+	// rpmPackages returns the names of the kernel headers packages, all of them are needed;
+	// none when they cannot be named for the kernel release.
+	rpmPackages(kr kernelrelease.KernelRelease) []string
+}
+
+// rpmPackage is a package listed by a RPM repository primary metadata.
+type rpmPackage struct {
+	Name         string
+	Arch         string
+	Version      string
+	Release      string
+	Location     string
+	ChecksumType string
+	Checksum     string
+}
+
+// url returns the package URL in repo, carrying its checksum as fragment, eg: "#sha256=<hex>", see verifyChecksum.
+func (p rpmPackage) url(repo string) string {
+	u := repo + "/" + p.Location
+	if p.ChecksumType != "" && p.Checksum != "" {
+		u += "#" + p.ChecksumType + "=" + p.Checksum
+	}
+	return u
+}
+
+// rpmRepoMD is the repodata/repomd.xml index of a RPM repository metadata.
+type rpmRepoMD struct {
+	Data []struct {
+		Type     string `xml:"type,attr"`
+		Location struct {
+			Href string `xml:"href,attr"`
+		} `xml:"location"`
+	} `xml:"data"`
+}
+
+// rpmPrimaryPackage is a package of the primary.xml metadata.
+type rpmPrimaryPackage struct {
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Ver string `xml:"ver,attr"`
+		Rel string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"checksum"`
+	Location struct {
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+}
+
+// fetchRPMKernelURLs returns the URLs of the kernel headers packages of the kernel release,
+// as found in the metadata of the builder repositories.
+func fetchRPMKernelURLs(b rpmBuilder, kr kernelrelease.KernelRelease) ([]string, error) {
+	return resolveRPMKernelURLs(b.rpmRepos(kr), b.rpmPackages(kr), kr)
+}
+
+// resolveRPMKernelURLs returns the URLs of the named kernel headers packages of the kernel release,
+// as found in the metadata of the repositories: the first repository providing a package wins.
+func resolveRPMKernelURLs(repos, names []string, kr kernelrelease.KernelRelease) ([]string, error) {
+	if len(names) == 0 {
+		return nil, HeadersNotFoundErr
+	}
+	arch := kr.Architecture.ToNonDeb()
+	release := rpmRelease(kr)
+	match := func(p rpmPackage) bool {
+		return slices.Contains(names, p.Name) &&
+			(p.Arch == arch || p.Arch == "noarch") &&
+			p.Version == kr.Fullversion &&
+			// some distros, eg: opensuse, append a rebuild counter to the kernel release
+			(p.Release == release || strings.HasPrefix(p.Release, release+"."))
+	}
+
+	found := make(map[string]string)
+	visited := make(map[string]struct{})
+	var errs []error
+	for _, repo := range repos {
+		repo = strings.TrimSuffix(repo, "/")
+		if _, ok := visited[repo]; ok {
+			continue
+		}
+		visited[repo] = struct{}{}
+
+		// Most of the repositories do not provide the requested kernel, or do not even exist anymore:
+		// their errors are only reported when the packages are not found at all
+		ctx, cancel := context.WithTimeout(context.Background(), rpmRepoTimeout)
+		packages, err := fetchRPMPackages(ctx, repo, match)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("repository %s: %w", repo, err))
+			continue
+		}
+		for _, p := range packages {
+			if _, ok := found[p.Name]; !ok {
+				found[p.Name] = p.url(repo)
+			}
+		}
+		// Found, do not continue
+		if len(found) == len(names) {
+			break
+		}
+	}
+
+	if len(found) < len(names) {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%w: %w", HeadersNotFoundErr, errors.Join(errs...))
+		}
+		return nil, HeadersNotFoundErr
+	}
+	urls := make([]string, 0, len(names))
+	for _, name := range names {
+		urls = append(urls, found[name])
+	}
+	return urls, nil
+}
+
+// rpmRelease returns the RPM release of the kernel, eg: "1160.el7" for "3.10.0-1160.el7.x86_64",
+// or "150400.24.46" for "5.14.21-150400.24.46-default".
+func rpmRelease(kr kernelrelease.KernelRelease) string {
+	release := strings.TrimPrefix(kr.FullExtraversion, "-")
+	release = strings.TrimSuffix(release, "."+kr.Architecture.ToNonDeb())
+	// RPM releases cannot hold dashes, the kernel flavor follows them
+	release, _, _ = strings.Cut(release, "-")
+	return release
+}
+
+// rpmRepoTimeout bounds the time spent reading the metadata of each repository,
+// so that a dead mirror cannot hang the kernel headers resolution.
+var rpmRepoTimeout = 2 * time.Minute
+
+// rpmGet fetches url, until ctx is done.
+func rpmGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// fetchRPMPackages returns the packages of the repository matching match,
+// reading its primary metadata, either the xml or the sqlite one.
+func fetchRPMPackages(ctx context.Context, repo string, match func(rpmPackage) bool) ([]rpmPackage, error) {
+	res, err := rpmGet(ctx, repo+"/repodata/repomd.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s repomd.xml: %s", repo, res.Status)
+	}
+	var repomd rpmRepoMD
+	if err := xml.NewDecoder(res.Body).Decode(&repomd); err != nil {
+		return nil, err
+	}
+
+	// The primary xml metadata is mandatory, while the sqlite one is optional
+	var primary, primaryDB string
+	for _, data := range repomd.Data {
+		switch data.Type {
+		case "primary":
+			primary = data.Location.Href
+		case "primary_db":
+			primaryDB = data.Location.Href
+		}
+	}
+	href := primary
+	if href == "" {
+		href = primaryDB
+	}
+	if href == "" {
+		return nil, fmt.Errorf("no primary metadata found in %s repomd.xml", repo)
+	}
+
+	res, err = rpmGet(ctx, repo+"/"+href)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s %s: %s", repo, href, res.Status)
+	}
+	r, err := rpmDecompress(href, res.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if href == primary {
+		return readRPMPrimaryXML(r, match)
+	}
+	return readRPMPrimaryDB(r, match)
+}
+
+// rpmDecompress decompresses the metadata file, according to its extension.
+func rpmDecompress(href string, r io.Reader) (io.ReadCloser, error) {
+	switch path.Ext(href) {
+	case ".gz":
+		return gzip.NewReader(r)
+	case ".bz2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case ".xz":
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+// readRPMPrimaryXML returns the packages of the primary.xml metadata matching match.
+func readRPMPrimaryXML(r io.Reader, match func(rpmPackage) bool) ([]rpmPackage, error) {
+	var packages []rpmPackage
+	d := xml.NewDecoder(r)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return packages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+		var pp rpmPrimaryPackage
+		if err := d.DecodeElement(&pp, &start); err != nil {
+			return nil, err
+		}
+		p := rpmPackage{
+			Name:         pp.Name,
+			Arch:         pp.Arch,
+			Version:      pp.Version.Ver,
+			Release:      pp.Version.Rel,
+			Location:     pp.Location.Href,
+			ChecksumType: pp.Checksum.Type,
+			Checksum:     strings.TrimSpace(pp.Checksum.Value),
+		}
+		if match(p) {
+			packages = append(packages, p)
+		}
+	}
+}
+
+// readRPMPrimaryDB returns the packages of the primary.sqlite metadata matching match.
+func readRPMPrimaryDB(r io.Reader, match func(rpmPackage) bool) ([]rpmPackage, error) {
+	// Create the temporary database file
+	dbFile, err := os.CreateTemp(os.TempDir(), "primary-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(dbFile.Name())
+	if _, err := io.Copy(dbFile, r); err != nil {
+		dbFile.Close()
+		return nil, err
+	}
+	if err := dbFile.Close(); err != nil {
+		return nil, err
+	}
+
+	// Open and query the database
+	db, err := sql.Open("sqlite", dbFile.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT name, arch, version, release, location_href, IFNULL(checksum_type, ''), IFNULL(pkgId, '') FROM packages")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packages []rpmPackage
+	for rows.Next() {
+		var p rpmPackage
+		if err := rows.Scan(&p.Name, &p.Arch, &p.Version, &p.Release, &p.Location, &p.ChecksumType, &p.Checksum); err != nil {
+			return nil, err
+		}
+		if match(p) {
+			packages = append(packages, p)
+		}
+	}
+	return packages, rows.Err()
+}
+
+// checksumAlgos are the checksum types verified by verifyChecksum, each one through the "<type>sum" tool.
+var checksumAlgos = []string{"md5", "sha1", "sha224", "sha256", "sha384", "sha512"}
+
+// verifyChecksum returns the shell command verifying the checksum of file, when the url it was downloaded from
+// carries one as fragment, eg: "https://example.com/kernel-devel.rpm#sha256=<hex>"; it is empty otherwise.
+func verifyChecksum(url, file string) string {
+	_, fragment, _ := strings.Cut(url, "#")
+	algo, sum, ok := strings.Cut(fragment, "=")
+	if !ok || sum == "" || strings.Trim(sum, "0123456789abcdef") != "" {
+		return ""
+	}
+	// old repositories name sha1 just "sha"
+	if algo == "sha" {
+		algo = "sha1"
+	}
+	if slices.Contains(checksumAlgos, algo) {
+		return fmt.Sprintf("echo '%s  %s' | %ssum -c -", sum, file, algo)
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"gotest.tools/assert"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+const testRPMPrimaryXML = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="3">
+<package type="rpm">
+  <name>kernel-default-devel</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="5.14.21" rel="150400.24.46.1"/>
+  <checksum type="sha256" pkgid="YES">0123456789abcdef</checksum>
+  <location href="x86_64/kernel-default-devel-5.14.21-150400.24.46.1.x86_64.rpm"/>
+  <format><rpm:license>GPL-2.0-only</rpm:license></format>
+</package>
+<package type="rpm">
+  <name>kernel-default-devel</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="5.14.21" rel="150400.24.41.1"/>
+  <checksum type="sha256" pkgid="YES">fedcba9876543210</checksum>
+  <location href="x86_64/kernel-default-devel-5.14.21-150400.24.41.1.x86_64.rpm"/>
+</package>
+<package type="rpm">
+  <name>kernel-devel</name>
+  <arch>noarch</arch>
+  <version epoch="0" ver="5.14.21" rel="150400.24.46.1"/>
+  <checksum type="sha256" pkgid="YES">00112233</checksum>
+  <location href="noarch/kernel-devel-5.14.21-150400.24.46.1.noarch.rpm"/>
+</package>
+</metadata>`
+
+// newTestRPMRepo registers a repository serving its primary metadata, of the given type, at href.
+func newTestRPMRepo(mux *http.ServeMux, repo, dataType, href string, primary []byte) {
+	mux.HandleFunc(repo+"/repodata/repomd.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo">
+  <data type="filelists"><location href="repodata/filelists.xml.gz"/></data>
+  <data type="` + dataType + `"><location href="` + href + `"/></data>
+</repomd>`))
+	})
+	mux.HandleFunc(repo+"/"+href, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(primary)
+	})
+}
+
+func TestResolveRPMKernelURLs(t *testing.T) {
+	var zstdXML bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdXML)
+	assert.NilError(t, err)
+	_, err = zw.Write([]byte(testRPMPrimaryXML))
+	assert.NilError(t, err)
+	assert.NilError(t, zw.Close())
+
+	// The sqlite metadata of the same packages
+	dbPath := filepath.Join(t.TempDir(), "primary.sqlite")
+	db, err := sql.Open("sqlite", dbPath)
+	assert.NilError(t, err)
+	_, err = db.Exec(`CREATE TABLE packages (pkgKey INTEGER PRIMARY KEY, pkgId TEXT, name TEXT, arch TEXT, version TEXT, epoch TEXT, release TEXT, location_href TEXT, checksum_type TEXT);
+INSERT INTO packages VALUES (1, '0123456789abcdef', 'kernel-devel', 'x86_64', '3.10.0', '0', '1160.el7', 'Packages/kernel-devel-3.10.0-1160.el7.x86_64.rpm', 'sha256');
+INSERT INTO packages VALUES (2, 'fedcba9876543210', 'kernel-headers', 'x86_64', '3.10.0', '0', '1160.el7', 'Packages/kernel-headers-3.10.0-1160.el7.x86_64.rpm', 'sha256');`)
+	assert.NilError(t, err)
+	assert.NilError(t, db.Close())
+	dbData, err := os.ReadFile(dbPath)
+	assert.NilError(t, err)
+	var xzDB bytes.Buffer
+	xw, err := xz.NewWriter(&xzDB)
+	assert.NilError(t, err)
+	_, err = xw.Write(dbData)
+	assert.NilError(t, err)
+	assert.NilError(t, xw.Close())
+
+	var gzipXML bytes.Buffer
+	gw := gzip.NewWriter(&gzipXML)
+	_, err = gw.Write([]byte(strings.ReplaceAll(testRPMPrimaryXML, "kernel-devel</name>", "kernel-source</name>")))
+	assert.NilError(t, err)
+	assert.NilError(t, gw.Close())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dead/", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	newTestRPMRepo(mux, "/nodevel", "primary", "repodata/primary.xml.gz", gzipXML.Bytes())
+	newTestRPMRepo(mux, "/opensuse", "primary", "repodata/primary.xml.zst", zstdXML.Bytes())
+	newTestRPMRepo(mux, "/centos", "primary_db", "repodata/primary.sqlite.xz", dbData)
+	newTestRPMRepo(mux, "/centos-xz", "primary_db", "repodata/primary.sqlite.xz", xzDB.Bytes())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The first repository providing a package wins, while missing repositories are skipped
+	kr := kernelrelease.FromString("5.14.21-150400.24.46-default")
+	kr.Architecture = kernelrelease.ArchitectureAmd64
+	urls, err := resolveRPMKernelURLs([]string{server.URL + "/missing", server.URL + "/nodevel", server.URL + "/opensuse/"},
+		[]string{"kernel-default-devel", "kernel-devel"}, kr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		server.URL + "/nodevel/x86_64/kernel-default-devel-5.14.21-150400.24.46.1.x86_64.rpm#sha256=0123456789abcdef",
+		server.URL + "/opensuse/noarch/kernel-devel-5.14.21-150400.24.46.1.noarch.rpm#sha256=00112233",
+	})
+
+	// The sqlite metadata is read too, while the repositories whose metadata cannot be read are skipped
+	kr = kernelrelease.FromString("3.10.0-1160.el7.x86_64")
+	kr.Architecture = kernelrelease.ArchitectureAmd64
+	_, err = resolveRPMKernelURLs([]string{server.URL + "/centos"}, []string{"kernel-devel"}, kr)
+	assert.Assert(t, errors.Is(err, HeadersNotFoundErr))
+	assert.ErrorContains(t, err, "repository "+server.URL+"/centos: ")
+	urls, err = resolveRPMKernelURLs([]string{server.URL + "/centos", server.URL + "/centos-xz"}, []string{"kernel-devel"}, kr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		server.URL + "/centos-xz/Packages/kernel-devel-3.10.0-1160.el7.x86_64.rpm#sha256=0123456789abcdef",
+	})
+
+	// A dead mirror does not hang the resolution
+	rpmRepoTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { rpmRepoTimeout = 2 * time.Minute })
+	urls, err = resolveRPMKernelURLs([]string{server.URL + "/dead", server.URL + "/centos-xz"}, []string{"kernel-devel"}, kr)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, urls, []string{
+		server.URL + "/centos-xz/Packages/kernel-devel-3.10.0-1160.el7.x86_64.rpm#sha256=0123456789abcdef",
+	})
+	_, err = resolveRPMKernelURLs([]string{server.URL + "/dead"}, []string{"kernel-devel"}, kr)
+	assert.Assert(t, errors.Is(err, HeadersNotFoundErr))
+	assert.ErrorContains(t, err, "context deadline exceeded")
+
+	// Nothing to look for
+	_, err = resolveRPMKernelURLs([]string{server.URL + "/centos-xz"}, nil, kr)
+	assert.Equal(t, err, HeadersNotFoundErr)
+}
+
+func TestOpensuseRPMPackages(t *testing.T) {
+	tests := map[string][]string{
+		"5.14.21-150400.24.46-default": {"kernel-default-devel", "kernel-devel"},
+		"6.4.0-150600.23.7-rt":         {"kernel-rt-devel", "kernel-devel"},
+		"5.14.21-150400.24.46":         nil,
+		"5.14.21-150400.24.46-":        nil,
+	}
+	o := &opensuse{}
+	for release, expected := range tests {
+		assert.DeepEqual(t, o.rpmPackages(kernelrelease.FromString(release)), expected)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/kernel-devel.rpm#sha256=0123abcd": "echo '0123abcd  kernel-devel.rpm' | sha256sum -c -",
+		"https://example.com/kernel-devel.rpm#sha=0123abcd":    "echo '0123abcd  kernel-devel.rpm' | sha1sum -c -",
+		"https://example.com/kernel-devel.rpm":                 "",
+		"https://example.com/kernel-devel.rpm#foo=0123abcd":    "",
+		"https://example.com/kernel-devel.rpm#sha256=$(id)":    "",
+	}
+	for url, expected := range tests {
+		assert.Equal(t, verifyChecksum(url, "kernel-devel.rpm"), expected)
+	}
+}
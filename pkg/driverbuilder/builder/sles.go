@@ -16,6 +16,7 @@ package builder
 
 import (
 	_ "embed"
+	"strings"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 )
@@ -73,3 +74,7 @@ func (v *sles) KernelTemplateData(kr kernelrelease.KernelRelease, _ []string) in
 func (v *sles) BuilderImageNetMode() string {
 	return "host"
 }
+
+func (v *sles) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return strings.HasSuffix(kr.FullExtraversion, "-default")
+}
@@ -41,3 +41,7 @@ func (b *talos) KernelTemplateData(kr kernelrelease.KernelRelease, urls []string
 		KernelLocalVersion: kr.FullExtraversion,
 	}
 }
+
+func (b *talos) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return kr.FullExtraversion == "-talos"
+}
@@ -14,6 +14,15 @@ limitations under the License.
 
 package builder
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
 // byTarget maps targets to their builder.
 var byTarget = map[Type]Builder{}
 
@@ -23,3 +32,63 @@ type Type string
 func (t Type) String() string {
 	return string(t)
 }
+
+// TargetTypeAuto is a pseudo target asking driverkit
+// to detect the real target from the kernel release string.
+const TargetTypeAuto Type = "auto"
+
+// KernelReleaseRecognizer is an optional interface implemented by builders
+// able to tell whether a kernel release belongs to their distro.
+type KernelReleaseRecognizer interface {
+	RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool
+}
+
+// ErrTargetNotDetected is returned by DetectTarget when no builder recognizes the kernel release.
+var ErrTargetNotDetected = errors.New("unable to detect target from kernel release")
+
+// AmbiguousTargetError is returned by DetectTarget
+// when multiple targets recognize the same kernel release.
+type AmbiguousTargetError struct {
+	KernelRelease string
+	Candidates    []Type
+}
+
+func (e *AmbiguousTargetError) Error() string {
+	candidates := make([]string, len(e.Candidates))
+	for i, c := range e.Candidates {
+		candidates[i] = c.String()
+	}
+	return fmt.Sprintf("ambiguous target for kernel release %s, candidates: [%s]",
+		e.KernelRelease, strings.Join(candidates, ","))
+}
+
+// DetectTarget returns the target whose builder recognizes the given kernel release.
+// It returns an *AmbiguousTargetError when more than one builder recognizes it.
+func DetectTarget(kernelRelease string) (Type, error) {
+	kr, err := kernelrelease.Parse(kernelRelease)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTargetNotDetected, err)
+	}
+
+	var candidates []Type
+	for target, b := range byTarget {
+		if r, ok := b.(KernelReleaseRecognizer); ok && r.RecognizeKernelRelease(kr) {
+			candidates = append(candidates, target)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("%w: %q", ErrTargetNotDetected, kernelRelease)
+	case 1:
+		return candidates[0], nil
+	default:
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i] < candidates[j]
+		})
+		return "", &AmbiguousTargetError{
+			KernelRelease: kernelRelease,
+			Candidates:    candidates,
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+var detectTargetTests = []struct {
+	kernelRelease string
+	expected      Type
+	candidates    []Type
+}{
+	{kernelRelease: "3.10.0-1160.el7.x86_64", expected: TargetTypeCentos},
+	{kernelRelease: "5.14.0-362.el9.x86_64", expected: TargetTypeCentos},
+	{kernelRelease: "5.14.0-70.13.1.el9_0.x86_64", candidates: []Type{TargetTypeAlma, TargetTypeRocky}},
+	{kernelRelease: "4.18.0-348.7.1.el8_5.x86_64", candidates: []Type{TargetTypeAlma, TargetTypeCentos, TargetTypeRocky}},
+	{kernelRelease: "5.15.0-101.103.2.1.el9uek.x86_64", expected: TargetTypeoracle},
+	{kernelRelease: "4.14.256-197.484.amzn1.x86_64", expected: TargetTypeAmazonLinux},
+	{kernelRelease: "4.14.171-136.231.amzn2.x86_64", expected: TargetTypeAmazonLinux2},
+	{kernelRelease: "5.15.29-16.111.amzn2022.x86_64", expected: TargetTypeAmazonLinux2022},
+	{kernelRelease: "6.1.55-75.123.amzn2023.x86_64", expected: TargetTypeAmazonLinux2023},
+	{kernelRelease: "6.5.6-300.fc39.x86_64", expected: TargetTypeFedora},
+	{kernelRelease: "5.10.134-13.al8.x86_64", expected: TargetTypeAlinux},
+	{kernelRelease: "5.10.118-14.ph4-esx", expected: TargetTypePhoton},
+	{kernelRelease: "6.8.1-arch1-1", expected: TargetTypeArchlinux},
+	{kernelRelease: "6.6.22-1-lts", expected: TargetTypeArchlinux},
+	{kernelRelease: "6.1.0-13-amd64", expected: TargetTypeDebian},
+	{kernelRelease: "6.1.0-13-cloud-arm64", expected: TargetTypeDebian},
+	{kernelRelease: "6.1.0-13-s390x", expected: TargetTypeDebian},
+	{kernelRelease: "6.1.0-13-powerpc64le", expected: TargetTypeDebian},
+	{kernelRelease: "5.15.0-1019-aws", expected: TargetTypeUbuntu},
+	{kernelRelease: "5.15.0-58-generic", expected: TargetTypeUbuntu},
+	{kernelRelease: "5.14.21-150500.55.31-default", candidates: []Type{TargetTypeOpenSUSE, TargetTypeSLES}},
+	{kernelRelease: "3510.2.0", expected: TargetTypeFlatcar},
+	{kernelRelease: "6.1.58-talos", expected: TargetTypeTalos},
+	{kernelRelease: "6.5.1", expected: TargetTypeVanilla},
+	{kernelRelease: "6.7.0-rc1", expected: TargetTypeVanilla},
+	{kernelRelease: "5.10.0-foo.bar"},
+	{kernelRelease: "not-a-release"},
+}
+
+func TestDetectTarget(t *testing.T) {
+	for _, test := range detectTargetTests {
+		t.Run(test.kernelRelease, func(t *testing.T) {
+			target, err := DetectTarget(test.kernelRelease)
+			switch {
+			case test.expected != "":
+				assert.NilError(t, err)
+				assert.Equal(t, test.expected, target)
+			case len(test.candidates) > 0:
+				var ambiguousErr *AmbiguousTargetError
+				assert.Assert(t, errors.As(err, &ambiguousErr))
+				assert.DeepEqual(t, test.candidates, ambiguousErr.Candidates)
+			default:
+				assert.Assert(t, errors.Is(err, ErrTargetNotDetected))
+			}
+		})
+	}
+}
@@ -27,6 +27,7 @@ mkdir /tmp/kernel-download
 cd /tmp/kernel-download
 {{ range $url := .KernelDownloadURLs }}
 curl --silent -o kernel.rpm -SL {{ $url }}
+{{ verifyChecksum $url "kernel.rpm" }}
 rpm2cpio kernel.rpm | cpio --extract --make-directories
 rm -rf kernel.rpm
 {{ end }}
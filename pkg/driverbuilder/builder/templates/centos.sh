@@ -22,6 +22,12 @@
 #
 set -xeuo pipefail
 
+{{ if .CrossCompilePrefix }}
+# Cross-compiling: rebuild the host tools of the kernel headers (eg: fixdep, modpost),
+# since they are shipped for the target architecture
+make -C ${KERNELDIR} modules_prepare
+{{ end }}
+
 cd {{ .DriverBuildDir }}
 sed -i 's/$(MAKE) -C $(KERNELDIR)/$(MAKE) KCFLAGS="-Wno-incompatible-pointer-types" -C $(KERNELDIR)/g' driver/Makefile.in
 mkdir -p build && cd build
@@ -29,8 +35,13 @@ mkdir -p build && cd build
 
 {{ if .BuildModule }}
 # Build the module
-make CC=/usr/bin/gcc-{{ .GCCVersion }} driver
-strip -g {{ .ModuleFullPath }}
+{{ template "make-driver" . }}
+{{ .CrossCompilePrefix }}strip -g {{ .ModuleFullPath }}
 # Print results
 modinfo {{ .ModuleFullPath }}
 {{ end }}
+
+{{ if .BuildProbe }}
+# Build the eBPF probe
+{{ template "make-probe" . }}
+{{ end }}
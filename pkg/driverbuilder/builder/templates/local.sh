@@ -22,7 +22,7 @@
 #
 set -xeo pipefail
 
-{{ if and .BuildModule (not .UseDKMS) }}
+{{ if or (and .BuildModule (not .UseDKMS)) .BuildProbe }}
 cd {{ .DriverBuildDir }}
 {{ if .DownloadSrc }}
 echo "* Configuring sources with cmake"
@@ -58,3 +58,16 @@ strip -g {{ .ModuleFullPath }}
 modinfo {{ .ModuleFullPath }}
 {{ end }}
 {{ end }}
+
+{{ if .BuildProbe }}
+echo "* Building eBPF probe"
+{{ if .DownloadSrc }}
+# Build the eBPF probe - cmake configured
+cd {{ .DriverBuildDir }}/build/driver/bpf
+{{ else }}
+# Build the eBPF probe - preconfigured sources
+cd {{ .DriverBuildDir }}/bpf
+{{ end }}
+make
+ls -l probe.o
+{{ end }}
@@ -0,0 +1,76 @@
+{{- /*
+SPDX-License-Identifier: Apache-2.0
+
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Templates shared by the builder scripts to build the kernel module,
+with the same compiler the kernel was built with, and the eBPF probe.
+*/ -}}
+
+{{ define "detect-gcc" -}}
+{{ if .DetectGCC -}}
+# Prefer the gcc the kernel was built with, as stated by the kernel headers, when provided by the image
+GCC=/usr/bin/{{ .CrossCompilePrefix }}gcc-{{ .GCCVersion }}
+KERNEL_GCC=$(sed -n -E 's/^CONFIG_GCC_VERSION=([1-9][0-9]*)$/\1/p' ${KERNELDIR}/include/config/auto.conf ${KERNELDIR}/.config 2>/dev/null | head -n 1 || true)
+if [ -n "${KERNEL_GCC}" ]; then
+  KERNEL_GCC="$((KERNEL_GCC / 10000)) $((KERNEL_GCC / 100 % 100))"
+else
+  KERNEL_GCC=$(sed -n -E 's/^#define LINUX_COMPILER "gcc[^,]*[^0-9.,]([0-9]+)\.([0-9]+)\.[0-9]+.*/\1 \2/p' ${KERNELDIR}/include/generated/compile.h 2>/dev/null | head -n 1 || true)
+fi
+if [ -n "${KERNEL_GCC}" ]; then
+  read -r KERNEL_GCC_MAJOR KERNEL_GCC_MINOR <<< "${KERNEL_GCC}"
+  for v in "${KERNEL_GCC_MAJOR}.${KERNEL_GCC_MINOR}.0" "${KERNEL_GCC_MAJOR}.0.0"; do
+    if [ -x "/usr/bin/{{ .CrossCompilePrefix }}gcc-${v}" ]; then
+      GCC="/usr/bin/{{ .CrossCompilePrefix }}gcc-${v}"
+      break
+    fi
+  done
+fi
+{{ end -}}
+{{- end }}
+
+{{ define "gcc" -}}
+{{ if .DetectGCC }}${GCC}{{ else }}/usr/bin/{{ .CrossCompilePrefix }}gcc-{{ .GCCVersion }}{{ end }}
+{{- end }}
+
+{{ define "make-driver-gcc" -}}
+make CC={{ template "gcc" . }} driver
+{{- end }}
+
+{{ define "make-driver-clang" -}}
+make LLVM=1 CC=/usr/bin/clang{{ if .ClangVersion }}-{{ .ClangVersion }}{{ end }} LD=/usr/bin/ld.lld{{ if .ClangVersion }}-{{ .ClangVersion }}{{ end }} driver
+{{- end }}
+
+{{ define "make-driver" -}}
+{{ if ne .Compiler "clang" }}{{ template "detect-gcc" . }}{{ end -}}
+{{ if eq .Compiler "clang" -}}
+{{ template "make-driver-clang" . }}
+{{- else if eq .Compiler "auto" -}}
+# Use the same compiler family the kernel was built with
+if grep -qs '^CONFIG_CC_IS_CLANG=y' ${KERNELDIR}/include/config/auto.conf ${KERNELDIR}/.config; then
+  {{ template "make-driver-clang" . }}
+else
+  {{ template "make-driver-gcc" . }}
+fi
+{{- else -}}
+{{ template "make-driver-gcc" . }}
+{{- end }}
+{{- end }}
+
+{{ define "make-probe" -}}
+cd {{ .DriverBuildDir }}/build/driver/bpf
+make{{ if .ClangVersion }} CLANG=/usr/bin/clang-{{ .ClangVersion }} LLC=/usr/bin/llc-{{ .ClangVersion }}{{ end }}
+ls -l probe.o
+{{- end }}
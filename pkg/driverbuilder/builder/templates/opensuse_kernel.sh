@@ -27,6 +27,7 @@ mkdir /tmp/kernel-download
 cd /tmp/kernel-download
 {{range $url := .KernelDownloadURLs}}
 curl --silent -o kernel-devel.rpm -SL {{ $url }}
+{{ verifyChecksum $url "kernel-devel.rpm" }}
 # cpio will warn *extremely verbose* when trying to duplicate over the same directory - redirect stderr to null
 rpm2cpio kernel-devel.rpm | cpio --quiet --extract --make-directories 2> /dev/null
 {{end}}
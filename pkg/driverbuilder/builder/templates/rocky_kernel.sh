@@ -26,6 +26,7 @@ set -xeuo pipefail
 mkdir /tmp/kernel-download
 cd /tmp/kernel-download
 curl --silent -o kernel-devel.rpm -SL {{ .KernelDownloadURL }}
+{{ verifyChecksum .KernelDownloadURL "kernel-devel.rpm" }}
 rpm2cpio kernel-devel.rpm | cpio --extract --make-directories
 rm -Rf /tmp/kernel
 mkdir -p /tmp/kernel
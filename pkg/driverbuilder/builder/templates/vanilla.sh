@@ -24,14 +24,25 @@ set -xeuo pipefail
 
 export KBUILD_MODPOST_WARN=1
 
+{{ if .CrossCompilePrefix }}
+# Cross-compiling: rebuild the host tools of the kernel headers (eg: fixdep, modpost),
+# since they are shipped for the target architecture
+make -C ${KERNELDIR} modules_prepare
+{{ end }}
+
 cd {{ .DriverBuildDir }}
 mkdir -p build && cd build
 {{ .CmakeCmd }}
 
 {{ if .BuildModule }}
 # Build the module
-make CC=/usr/bin/gcc-{{ .GCCVersion }} driver
-strip -g {{ .ModuleFullPath }}
+{{ template "make-driver" . }}
+{{ .CrossCompilePrefix }}strip -g {{ .ModuleFullPath }}
 # Print results
 modinfo {{ .ModuleFullPath }}
 {{ end }}
+
+{{ if .BuildProbe }}
+# Build the eBPF probe
+{{ template "make-probe" . }}
+{{ end }}
@@ -70,8 +70,7 @@ func (v *ubuntu) MinimumURLs() int {
 }
 
 func (v *ubuntu) KernelTemplateData(kr kernelrelease.KernelRelease, urls []string) interface{} {
-	// parse the flavor out of the kernelrelease extraversion
-	_, flavor := parseUbuntuExtraVersion(kr.Extraversion)
+	flavor := kr.Ubuntu().Flavor
 
 	// handle hwe kernels, which resolve to "generic" urls under /linux-hwe
 	// Example: http://mirrors.edge.kernel.org/ubuntu/pool/main/l/linux-hwe/linux-headers-4.18.0-24-generic_4.18.0-24.25~18.04.1_amd64.deb
@@ -126,8 +125,14 @@ func ubuntuHeadersURLFromRelease(kr kernelrelease.KernelRelease) ([]string, erro
 }
 
 func fetchUbuntuKernelURL(baseURL string, kr kernelrelease.KernelRelease) ([]string, error) {
-	// parse the extra number and flavor for the kernelrelease extraversion
-	firstExtra, ubuntuFlavor := parseUbuntuExtraVersion(kr.Extraversion)
+	ubuntu := kr.Ubuntu()
+	firstExtra, ubuntuFlavor := ubuntu.ABI, ubuntu.Flavor
+
+	// prefer the exact package version extracted from `uname -v`, eg: "58~20.04.1" for HWE kernels
+	packageVersion := kr.KernelVersion
+	if kr.PackageVersion != "" {
+		packageVersion = kr.PackageVersion
+	}
 
 	// piece together possible subdirs on Ubuntu base URLs for a given flavor
 	// these include the base (such as 'linux-azure') and the base + version/patch ('linux-azure-5.15')
@@ -159,8 +164,8 @@ func fetchUbuntuKernelURL(baseURL string, kr kernelrelease.KernelRelease) ([]str
 			kr.FullExtraversion,
 			kr.Fullversion,
 			firstExtra,
-			kr.KernelVersion,
-			kr.Architecture.String(),
+			packageVersion,
+			kr.Architecture.ToDeb(),
 		),
 		fmt.Sprintf(
 			"linux-headers-%s-%s-%s_%s-%s.%s_%s.deb",
@@ -169,8 +174,8 @@ func fetchUbuntuKernelURL(baseURL string, kr kernelrelease.KernelRelease) ([]str
 			ubuntuFlavor,
 			kr.Fullversion,
 			firstExtra,
-			kr.KernelVersion,
-			kr.Architecture.String(),
+			packageVersion,
+			kr.Architecture.ToDeb(),
 		),
 		fmt.Sprintf(
 			"linux-%s-headers-%s-%s_%s-%s.%s_all.deb",
@@ -179,7 +184,7 @@ func fetchUbuntuKernelURL(baseURL string, kr kernelrelease.KernelRelease) ([]str
 			firstExtra,
 			kr.Fullversion,
 			firstExtra,
-			kr.KernelVersion,
+			packageVersion,
 		),
 		fmt.Sprintf(
 			"linux-headers-%s%s_%s-%s.%s_%s.deb",
@@ -187,8 +192,8 @@ func fetchUbuntuKernelURL(baseURL string, kr kernelrelease.KernelRelease) ([]str
 			kr.FullExtraversion,
 			kr.Fullversion,
 			firstExtra,
-			kr.KernelVersion,
-			kr.Architecture.String(),
+			packageVersion,
+			kr.Architecture.ToDeb(),
 		),
 	}
 
@@ -200,7 +205,7 @@ func fetchUbuntuKernelURL(baseURL string, kr kernelrelease.KernelRelease) ([]str
 				firstExtra,
 				kr.Fullversion,
 				firstExtra,
-				kr.KernelVersion,
+				packageVersion,
 			))
 	}
 
@@ -237,31 +242,12 @@ func deduplicateURLs(urls []string) []string {
 	return dedupURLs
 }
 
-// parse the extraversion from the kernelrelease to retrieve the extraNumber and flavor
-// assume the flavor is "generic" if unable to parse the flavor
-// Example: Input -> "188-generic", Output -> "188", "generic"
-// NOTE: make sure the kernelrelease passed in appears *exactly* as `uname -r` output
-func parseUbuntuExtraVersion(extraversion string) (string, string) {
-	if strings.Contains(extraversion, "-") {
-		split := strings.Split(extraversion, "-")
-
-		extraNumber := split[0]
-		flavorText := strings.Join(split[1:], "-") // back half of text
-
-		// extract the flavor from the flavorText using a regex
-		// ubuntu has these named in 3 (known) styles, examples:
-		// 		1. "generic"
-		// 		2. "generic-5"
-		// 		3. "generic-5.15"
-		// but some come in with multi-part names, such as:
-		// 		"intel-iotg-5.15"
-		// which must be handled as well - easier to do with regex
-		r, _ := regexp.Compile("^([a-z-]+[a-z])-*\\d?.*$")
-		flavor := r.FindStringSubmatch(flavorText)[1] // match should be second index
-
-		return extraNumber, flavor
-	}
+var ubuntuReleaseRegex = regexp.MustCompile(`^-\d+-[a-z][a-z0-9-]*$`)
 
-	// if unable to parse a flavor assume "generic" and return back the extraversion passed in
-	return extraversion, "generic"
+// Ubuntu kernels are in the form "5.15.0-1019-aws";
+// debian and archlinux ones share the same shape and are excluded.
+func (v *ubuntu) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	return ubuntuReleaseRegex.MatchString(kr.FullExtraversion) &&
+		!debianReleaseRegex.MatchString(kr.FullExtraversion) &&
+		!archlinuxReleaseRegex.MatchString(kr.FullExtraversion)
 }
@@ -348,10 +348,11 @@ func TestFetchUbuntuKernelURL(t *testing.T) {
 	}
 }
 
-func TestParseUbuntuExtraVersion(t *testing.T) {
+func TestUbuntuRelease(t *testing.T) {
 	for _, test := range tests {
 		input := test.config.Extraversion
-		gotFirstExtra, gotFlavor := parseUbuntuExtraVersion(input)
+		ubuntu := test.config.Ubuntu()
+		gotFirstExtra, gotFlavor := ubuntu.ABI, ubuntu.Flavor
 		if gotFirstExtra != test.expected.firstExtra {
 			t.Errorf(
 				"Test Input: [ '%s' ] | Got: [ '%s' ] / Want: [ '%s' ]",
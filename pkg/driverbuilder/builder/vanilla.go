@@ -17,6 +17,7 @@ package builder
 import (
 	_ "embed"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
@@ -82,3 +83,14 @@ func fetchVanillaKernelURLFromKernelVersion(kv kernelrelease.KernelRelease) stri
 func isRC(kv kernelrelease.KernelRelease) bool {
 	return strings.Contains(kv.Extraversion, "rc")
 }
+
+var vanillaRCReleaseRegex = regexp.MustCompile(`^-rc\d+$`)
+
+// Vanilla kernel releases carry no extraversion, but for release candidates, eg: "6.7.0-rc1".
+// Large major versions are left to flatcar, whose kernel releases are its release versions.
+func (v *vanilla) RecognizeKernelRelease(kr kernelrelease.KernelRelease) bool {
+	if kr.FullExtraversion == "" {
+		return kr.Major < 1500
+	}
+	return vanillaRCReleaseRegex.MatchString(kr.FullExtraversion)
+}
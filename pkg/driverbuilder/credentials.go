@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+)
+
+// imageCredential returns the registry, and its credential, of the given image reference.
+func imageCredential(b *builder.Build, ref string) (string, auth.Credential, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", auth.EmptyCredential, err
+	}
+	domain := reference.Domain(named)
+	cred, err := b.RegistryCredential(context.Background(), domain)
+	return domain, cred, err
+}
+
+// registryAuth returns the docker API encoded registry auth to pull, or push, the given image;
+// it is empty when there are no credentials for its registry.
+func registryAuth(b *builder.Build, ref string) (string, error) {
+	domain, cred, err := imageCredential(b, ref)
+	if err != nil || cred == auth.EmptyCredential {
+		return "", err
+	}
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      cred.Username,
+		Password:      cred.Password,
+		IdentityToken: cred.RefreshToken,
+		RegistryToken: cred.AccessToken,
+		ServerAddress: domain,
+	})
+}
+
+// dockerConfigJSON returns a kubernetes.io/dockerconfigjson secret data,
+// holding the credentials to pull the given image.
+func dockerConfigJSON(b *builder.Build, ref string) ([]byte, error) {
+	domain, cred, err := imageCredential(b, ref)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Username == "" || cred.Password == "" {
+		return nil, fmt.Errorf("no username and password credentials for registry %s", domain)
+	}
+	type dockerAuth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	return json.Marshal(map[string]map[string]dockerAuth{
+		"auths": {
+			credentials.ServerAddressFromRegistry(domain): {
+				Username: cred.Username,
+				Password: cred.Password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password)),
+			},
+		},
+	})
+}
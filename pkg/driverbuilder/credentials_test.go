@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"gotest.tools/assert"
+)
+
+func TestRegistryCredentials(t *testing.T) {
+	b := &builder.Build{
+		RegistryName:     "registry.internal",
+		RegistryUser:     "foo",
+		RegistryPassword: "bar",
+	}
+
+	encoded, err := registryAuth(b, "registry.internal/falcosecurity/driverkit-builder:any-x86_64_gcc12.0.0-latest")
+	assert.NilError(t, err)
+	data, err := base64.URLEncoding.DecodeString(encoded)
+	assert.NilError(t, err)
+	var authConfig map[string]string
+	assert.NilError(t, json.Unmarshal(data, &authConfig))
+	assert.Equal(t, authConfig["username"], "foo")
+	assert.Equal(t, authConfig["password"], "bar")
+	assert.Equal(t, authConfig["serveraddress"], "registry.internal")
+
+	data, err = dockerConfigJSON(b, "registry.internal/falcosecurity/driverkit-builder:any-x86_64_gcc12.0.0-latest")
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), `{"auths":{"registry.internal":{"username":"foo","password":"bar","auth":"Zm9vOmJhcg=="}}}`)
+
+	// Images on registries without credentials are pulled anonymously
+	b.RegistryConfig = "/nonexistent/config.json"
+	encoded, err = registryAuth(b, "falcosecurity/driverkit-builder:any-x86_64_gcc12.0.0-latest")
+	assert.NilError(t, err)
+	assert.Equal(t, encoded, "")
+	_, err = dockerConfigJSON(b, "falcosecurity/driverkit-builder:any-x86_64_gcc12.0.0-latest")
+	assert.ErrorContains(t, err, "no username and password credentials for registry docker.io")
+}
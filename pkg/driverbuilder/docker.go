@@ -23,10 +23,13 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -46,18 +49,26 @@ import (
 // DockerBuildProcessorName is a constant containing the docker name.
 const DockerBuildProcessorName = "docker"
 
+const (
+	// DefaultEmulatorImage is the image used to register qemu binfmt handlers; it runs on both x86_64 and arm64 hosts.
+	DefaultEmulatorImage = "tonistiigi/binfmt"
+	// MultiarchEmulatorImage is the legacy emulator image, only available for x86_64 hosts.
+	MultiarchEmulatorImage = "multiarch/qemu-user-static"
+)
+
 type DockerBuildProcessor struct {
-	clean   bool
-	timeout int
-	proxy   string
+	timeout       int
+	proxy         string
+	emulatorImage string
 	*output.Printer
 }
 
 // NewDockerBuildProcessor ...
-func NewDockerBuildProcessor(timeout int, proxy string) *DockerBuildProcessor {
+func NewDockerBuildProcessor(timeout int, proxy, emulatorImage string) *DockerBuildProcessor {
 	return &DockerBuildProcessor{
-		timeout: timeout,
-		proxy:   proxy,
+		timeout:       timeout,
+		proxy:         proxy,
+		emulatorImage: emulatorImage,
 	}
 }
 
@@ -65,64 +76,105 @@ func (bp *DockerBuildProcessor) String() string {
 	return DockerBuildProcessorName
 }
 
-func (bp *DockerBuildProcessor) mustCheckArchUseQemu(ctx context.Context, b *builder.Build, cli *client.Client) {
+// binfmtMiscDir is where the kernel exposes the registered binfmt_misc handlers.
+var binfmtMiscDir = "/proc/sys/fs/binfmt_misc"
+
+// binfmtRegistered tells whether a qemu binfmt_misc handler for arch is already registered and enabled.
+func binfmtRegistered(arch kernelrelease.Architecture) bool {
+	data, err := os.ReadFile(filepath.Join(binfmtMiscDir, "qemu-"+arch.ToNonDeb()))
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(string(data), "enabled")
+}
+
+// emulatorCmd returns the arguments needed by emulatorImage to register the qemu handler for arch.
+func emulatorCmd(emulatorImage string, arch kernelrelease.Architecture) []string {
+	if strings.Contains(emulatorImage, MultiarchEmulatorImage) {
+		return []string{"--reset", "-p", "yes"}
+	}
+	// tonistiigi/binfmt and compatible images
+	return []string{"--install", arch.String()}
+}
+
+func (bp *DockerBuildProcessor) checkArchUseQemu(ctx context.Context, b *builder.Build, cli *client.Client) error {
 	var err error
-	if b.Architecture == runtime.GOARCH {
-		// Nothing to do
-		return
+	arch := kernelrelease.Architecture(b.BuilderImageArchitecture())
+	if arch.String() == runtime.GOARCH {
+		// Nothing to do: either a native build or a cross-compilation one
+		return nil
 	}
 
-	if runtime.GOARCH != kernelrelease.ArchitectureAmd64 {
-		bp.Logger.Fatal("qemu-user-static image is only available for x86_64 hosts: https://github.com/multiarch/qemu-user-static#supported-host-architectures")
+	if binfmtRegistered(arch) {
+		bp.Logger.Debug("using already registered qemu binfmt handler for cross build",
+			bp.Logger.Args("arch", arch.String()))
+		return nil
 	}
 
-	bp.Logger.Debug("using qemu for cross build")
-	if _, _, err = cli.ImageInspectWithRaw(ctx, "multiarch/qemu-user-static"); client.IsErrNotFound(err) {
-		bp.Logger.Debug("pulling qemu static image",
-			bp.Logger.Args("image", "multiarch/qemu-user-static"))
-		pullRes, err := cli.ImagePull(ctx, "multiarch/qemu-user-static", image.PullOptions{})
+	emulatorImage := bp.emulatorImage
+	if emulatorImage == "" {
+		emulatorImage = DefaultEmulatorImage
+	}
+	emulatorImage = b.MirroredImage(emulatorImage)
+	if strings.Contains(emulatorImage, MultiarchEmulatorImage) && runtime.GOARCH != kernelrelease.ArchitectureAmd64 {
+		return fmt.Errorf("%s image is only available for x86_64 hosts, "+
+			"see https://github.com/multiarch/qemu-user-static#supported-host-architectures", emulatorImage)
+	}
+
+	bp.Logger.Debug("using qemu for cross build",
+		bp.Logger.Args("arch", arch.String(), "image", emulatorImage))
+	if _, _, err = cli.ImageInspectWithRaw(ctx, emulatorImage); client.IsErrNotFound(err) {
+		bp.Logger.Debug("pulling emulator image",
+			bp.Logger.Args("image", emulatorImage))
+		auth, err := registryAuth(b, emulatorImage)
+		if err != nil {
+			return fmt.Errorf("failed to get emulator image %s credentials: %w", emulatorImage, err)
+		}
+		pullRes, err := cli.ImagePull(ctx, emulatorImage, image.PullOptions{RegistryAuth: auth})
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("failed to pull emulator image %s: %w", emulatorImage, err)
 		}
 		defer pullRes.Close()
 		_, err = io.Copy(io.Discard, pullRes)
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("failed to pull emulator image %s: %w", emulatorImage, err)
 		}
 	}
 
 	qemuImage, err := cli.ContainerCreate(ctx,
 		&container.Config{
-			Cmd:   []string{"--reset", "-p", "yes"},
-			Image: "multiarch/qemu-user-static",
+			Cmd:   emulatorCmd(emulatorImage, arch),
+			Image: emulatorImage,
 		},
 		&container.HostConfig{
 			AutoRemove: true,
 			Privileged: true,
 		}, nil, nil, "")
 	if err != nil {
-		bp.Logger.Fatal("failed to create qemu container",
-			bp.Logger.Args("err", err.Error()))
+		return fmt.Errorf("failed to create emulator container: %w", err)
 	}
 
 	if err = cli.ContainerStart(ctx, qemuImage.ID, container.StartOptions{}); err != nil {
-		panic(err)
+		return fmt.Errorf("failed to start emulator container: %w", err)
 	}
 
 	statusCh, errCh := cli.ContainerWait(ctx, qemuImage.ID, container.WaitConditionNotRunning)
 	select {
 	case err = <-errCh:
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("failed to wait for emulator container: %w", err)
+		}
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("emulator container exited with code %d", status.StatusCode)
 		}
-	case <-statusCh:
 	}
 
 	err = cli.ContainerStop(ctx, qemuImage.ID, container.StopOptions{})
 	if err != nil && !client.IsErrNotFound(err) {
-		bp.Logger.Fatal("failed to stop qemu container",
-			bp.Logger.Args("err", err.Error()))
+		return fmt.Errorf("failed to stop emulator container: %w", err)
 	}
+	return nil
 }
 
 // Start the docker processor
@@ -135,7 +187,14 @@ func (bp *DockerBuildProcessor) Start(b *builder.Build) error {
 	}
 	cli.NegotiateAPIVersion(context.Background())
 
-	kr := b.KernelReleaseFromBuildConfig()
+	kr, err := b.KernelReleaseFromBuildConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := b.CheckKernelConfig(kr); err != nil {
+		return err
+	}
 
 	// create a builder based on the choosen build type
 	v, err := builder.Factory(b.TargetType)
@@ -165,29 +224,158 @@ func (bp *DockerBuildProcessor) Start(b *builder.Build) error {
 		return err
 	}
 
-	builderImage := b.GetBuilderImage()
-
 	// Create the container
 	ctx := context.Background()
 	ctx = signals.WithStandardSignals(ctx)
 
-	bp.mustCheckArchUseQemu(ctx, b, cli)
+	if err = bp.checkArchUseQemu(ctx, b, cli); err != nil {
+		return err
+	}
+
+	// We make the scripts executables,
+	// then:
+	// * download libs at required version
+	// * download and extract headers
+	//   * each download-headers script will export KERNELDIR variable internally
+	//   * we source download-headers.sh and store KERNELDIR so that it is then visible to driverkit.sh
+	// This is only done once per container, even when the build is retried with another gcc.
+	prepareCmd := `
+#!/bin/bash
+
+set -e
+
+chmod +x /driverkit/download-libs.sh
+chmod +x /driverkit/download-headers.sh
+
+/driverkit/download-libs.sh
+. /driverkit/download-headers.sh
+echo "export KERNELDIR=${KERNELDIR}" > /driverkit/kerneldir.env
+`
+
+	// Then, we finally make the actual build of the drivers
+	runCmd := `
+#!/bin/bash
+
+chmod +x /driverkit/driverkit.sh
+
+. /driverkit/kerneldir.env
+/driverkit/driverkit.sh
+`
+
+	prepareFiles := []dockerCopyFile{
+		{"/driverkit/download-libs.sh", libsDownloadScript},
+		{"/driverkit/download-headers.sh", kernelDownloadScript},
+		{"/driverkit/prepare.sh", prepareCmd},
+		{"/driverkit/cmd.sh", runCmd},
+		{"/driverkit/kernel.config", string(configDecoded)},
+	}
+
+	// Construct environment variable array of string
+	var envs []string
+	// Add the kbuild variables needed to cross-compile, if any
+	envs = append(envs, b.CrossCompileEnv()...)
+	// Add http_proxy and https_proxy environment variable
+	if bp.proxy != "" {
+		envs = append(envs,
+			fmt.Sprintf("http_proxy=%s", bp.proxy),
+			fmt.Sprintf("https_proxy=%s", bp.proxy),
+		)
+	}
+
+	var (
+		containerID  string
+		builderImage string
+		stop         = func() {}
+	)
+	defer func() {
+		stop()
+	}()
+
+	// When the kernel module fails to build, retry with the next-best gcc versions, if any;
+	// the container, with its downloaded libs and kernel headers, is kept as long as the builder image stays the same.
+	fallbacks := b.GCCFallbacks()
+	for {
+		if image := b.GetBuilderImage(); image != builderImage {
+			stop()
+			builderImage = image
+			pinned := b.PinnedBuilderImage(builderImage)
+			if err = b.VerifyBuilderImage(pinned); err != nil {
+				return err
+			}
+			containerID, stop, err = bp.startContainer(ctx, cli, b, v, pinned)
+			if err != nil {
+				return err
+			}
+			if err = bp.exec(ctx, cli, containerID, envs, prepareFiles, "/driverkit/prepare.sh"); err != nil {
+				return err
+			}
+		}
+
+		err = bp.exec(ctx, cli, containerID, envs, []dockerCopyFile{{"/driverkit/driverkit.sh", driverkitScript}}, "/driverkit/cmd.sh")
+		if err == nil {
+			break
+		}
+		// Only retry when the kernel module is the one that failed to build
+		if len(fallbacks) == 0 || fileExistsInContainer(ctx, cli, containerID, c.ToDriverFullPath()) {
+			return err
+		}
+		failedGCC := b.GCCVersion
+		b.GCCVersion = fallbacks[0].String()
+		fallbacks = fallbacks[1:]
+		bp.Logger.Warn("kernel module build failed, retrying with another gcc",
+			bp.Logger.Args("failedGCC", failedGCC, "gcc", b.GCCVersion, "err", err.Error()))
+		if driverkitScript, err = builder.Script(v, c, kr); err != nil {
+			return err
+		}
+	}
 
+	if len(b.ModuleFilePath) > 0 {
+		if err := copyFromContainer(ctx, cli, containerID, c.ToDriverFullPath(), b.ModuleFilePath); err != nil {
+			return err
+		}
+		bp.Logger.Info("kernel module available", bp.Logger.Args("path", b.ModuleFilePath, "gcc", b.GCCVersion))
+	}
+
+	if len(b.ProbeFilePath) > 0 {
+		if err := copyFromContainer(ctx, cli, containerID, c.ToProbeFullPath(), b.ProbeFilePath); err != nil {
+			return err
+		}
+		bp.Logger.Info("eBPF probe available", bp.Logger.Args("path", b.ProbeFilePath))
+	}
+
+	return nil
+}
+
+// startContainer starts a container of the builder image, returning its ID along with the function stopping it.
+func (bp *DockerBuildProcessor) startContainer(ctx context.Context,
+	cli *client.Client,
+	b *builder.Build,
+	v builder.Builder,
+	builderImage string,
+) (string, func(), error) {
 	var inspect types.ImageInspect
+	var err error
 	if inspect, _, err = cli.ImageInspectWithRaw(ctx, builderImage); client.IsErrNotFound(err) ||
-		inspect.Architecture != b.Architecture {
+		inspect.Architecture != b.BuilderImageArchitecture() {
 
 		bp.Logger.Debug("pulling builder image",
-			bp.Logger.Args("image", builderImage, "arch", b.Architecture))
+			bp.Logger.Args("image", builderImage, "arch", b.BuilderImageArchitecture()))
 
-		pullRes, err := cli.ImagePull(ctx, builderImage, image.PullOptions{Platform: b.Architecture})
+		auth, err := registryAuth(b, builderImage)
 		if err != nil {
-			return err
+			return "", nil, err
+		}
+		pullRes, err := cli.ImagePull(ctx, builderImage, image.PullOptions{
+			Platform:     b.BuilderImageArchitecture(),
+			RegistryAuth: auth,
+		})
+		if err != nil {
+			return "", nil, err
 		}
 		defer pullRes.Close()
 		_, err = io.Copy(io.Discard, pullRes)
 		if err != nil {
-			return err
+			return "", nil, err
 		}
 	}
 
@@ -213,76 +401,57 @@ func (bp *DockerBuildProcessor) Start(b *builder.Build) error {
 	uid := uuid.NewUUID()
 	name := fmt.Sprintf("driverkit-%s", string(uid))
 
-	cdata, err := cli.ContainerCreate(ctx, containerCfg, hostCfg, nil, &v1.Platform{Architecture: b.Architecture, OS: "linux"}, name)
+	cdata, err := cli.ContainerCreate(ctx, containerCfg, hostCfg, nil, &v1.Platform{Architecture: b.BuilderImageArchitecture(), OS: "linux"}, name)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	defer bp.cleanup(cli, cdata.ID)
+	var once sync.Once
+	stopped := make(chan struct{})
+	stop := func() {
+		once.Do(func() {
+			close(stopped)
+			bp.cleanup(cli, cdata.ID)
+		})
+	}
 	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				bp.cleanup(cli, cdata.ID)
-				return
-			}
+		select {
+		case <-ctx.Done():
+			bp.Logger.Debug("context canceled")
+			stop()
+		case <-stopped:
 		}
 	}()
 
 	err = cli.ContainerStart(ctx, cdata.ID, container.StartOptions{})
 	if err != nil {
-		return err
-	}
-
-	// We make all 3 scripts executables,
-	// then:
-	// * download libs at required version
-	// * download and extract headers
-	//   * each download-headers script will export KERNELDIR variable internally
-	//   * we source download-headers.sh so that KERNELDIR is then visible to driverkit.sh
-	// * we finally make the actual build of the drivers
-	runCmd := `
-#!/bin/bash
-
-chmod +x /driverkit/download-libs.sh
-chmod +x /driverkit/download-headers.sh
-chmod +x /driverkit/driverkit.sh
-
-/driverkit/download-libs.sh
-. /driverkit/download-headers.sh
-/driverkit/driverkit.sh
-`
-
-	files := []dockerCopyFile{
-		{"/driverkit/download-libs.sh", libsDownloadScript},
-		{"/driverkit/download-headers.sh", kernelDownloadScript},
-		{"/driverkit/driverkit.sh", driverkitScript},
-		{"/driverkit/cmd.sh", runCmd},
-		{"/driverkit/kernel.config", string(configDecoded)},
+		stop()
+		return "", nil, err
 	}
+	return cdata.ID, stop, nil
+}
 
+// exec copies the files to the container, then runs the script, forwarding its logs;
+// it fails when the script exits with a non-zero code.
+func (bp *DockerBuildProcessor) exec(ctx context.Context,
+	cli *client.Client,
+	containerID string,
+	envs []string,
+	files []dockerCopyFile,
+	script string,
+) error {
 	var buf bytes.Buffer
-	err = tarWriterFiles(&buf, files)
+	err := tarWriterFiles(&buf, files)
 	if err != nil {
 		return err
 	}
 	// Copy the needed files to the container
-	err = cli.CopyToContainer(ctx, cdata.ID, "/", &buf, container.CopyToContainerOptions{})
+	err = cli.CopyToContainer(ctx, containerID, "/", &buf, container.CopyToContainerOptions{})
 	if err != nil {
 		return err
 	}
 
-	// Construct environment variable array of string
-	var envs []string
-	// Add http_proxy and https_proxy environment variable
-	if bp.proxy != "" {
-		envs = append(envs,
-			fmt.Sprintf("http_proxy=%s", bp.proxy),
-			fmt.Sprintf("https_proxy=%s", bp.proxy),
-		)
-	}
-
-	edata, err := cli.ContainerExecCreate(ctx, cdata.ID, container.ExecOptions{
+	edata, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
 		Privileged:   false,
 		Tty:          false,
 		AttachStdin:  false,
@@ -293,7 +462,7 @@ chmod +x /driverkit/driverkit.sh
 		Cmd: []string{
 			"/bin/bash",
 			"-l",
-			"/driverkit/cmd.sh",
+			script,
 		},
 	})
 	if err != nil {
@@ -316,14 +485,29 @@ chmod +x /driverkit/driverkit.sh
 		bp.forwardLogs(hr.Reader)
 	}
 
-	if len(b.ModuleFilePath) > 0 {
-		if err := copyFromContainer(ctx, cli, cdata.ID, c.ToDriverFullPath(), b.ModuleFilePath); err != nil {
+	// Logs are over, wait for the exec to be marked as exited
+	for {
+		inspect, err := cli.ContainerExecInspect(ctx, edata.ID)
+		if err != nil {
 			return err
 		}
-		bp.Logger.Info("kernel module available", bp.Logger.Args("path", b.ModuleFilePath))
+		if !inspect.Running {
+			if inspect.ExitCode != 0 {
+				return fmt.Errorf("%s exited with code %d", script, inspect.ExitCode)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
 	}
+}
 
-	return nil
+func fileExistsInContainer(ctx context.Context, cli *client.Client, ID, path string) bool {
+	_, err := cli.ContainerStatPath(ctx, ID, path)
+	return err == nil
 }
 
 func copyFromContainer(ctx context.Context, cli *client.Client, ID, from, to string) error {
@@ -343,14 +527,10 @@ func copyFromContainer(ctx context.Context, cli *client.Client, ID, from, to str
 }
 
 func (bp *DockerBuildProcessor) cleanup(cli *client.Client, ID string) {
-	if !bp.clean {
-		bp.clean = true
-		bp.Logger.Debug("context canceled")
-		duration := 1
-		if err := cli.ContainerStop(context.Background(), ID, container.StopOptions{Timeout: &duration}); err != nil && !client.IsErrNotFound(err) {
-			bp.Logger.Error("error stopping container",
-				bp.Logger.Args("err", err.Error()))
-		}
+	duration := 1
+	if err := cli.ContainerStop(context.Background(), ID, container.StopOptions{Timeout: &duration}); err != nil && !client.IsErrNotFound(err) {
+		bp.Logger.Error("error stopping container",
+			bp.Logger.Args("err", err.Error()))
 	}
 }
 
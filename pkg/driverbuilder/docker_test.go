@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+func TestBinfmtRegistered(t *testing.T) {
+	oldDir := binfmtMiscDir
+	binfmtMiscDir = t.TempDir()
+	t.Cleanup(func() {
+		binfmtMiscDir = oldDir
+	})
+
+	assert.NilError(t, os.WriteFile(filepath.Join(binfmtMiscDir, "qemu-aarch64"),
+		[]byte("enabled\ninterpreter /usr/bin/qemu-aarch64\nflags: F\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(binfmtMiscDir, "qemu-s390x"),
+		[]byte("disabled\ninterpreter /usr/bin/qemu-s390x\nflags: F\n"), 0644))
+
+	assert.Assert(t, binfmtRegistered(kernelrelease.ArchitectureArm64))
+	assert.Assert(t, !binfmtRegistered(kernelrelease.ArchitectureS390x))
+	assert.Assert(t, !binfmtRegistered(kernelrelease.ArchitectureAmd64))
+}
+
+func TestEmulatorCmd(t *testing.T) {
+	assert.DeepEqual(t, emulatorCmd(DefaultEmulatorImage, kernelrelease.ArchitectureAmd64),
+		[]string{"--install", "amd64"})
+	assert.DeepEqual(t, emulatorCmd("registry.internal/tonistiigi/binfmt:latest", kernelrelease.ArchitectureArm64),
+		[]string{"--install", "arm64"})
+	assert.DeepEqual(t, emulatorCmd(MultiarchEmulatorImage, kernelrelease.ArchitectureArm64),
+		[]string{"--reset", "-p", "yes"})
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/signals"
+)
+
+// DefaultCMakeVersion is the cmake version installed in the builder images.
+const DefaultCMakeVersion = "3.24.4"
+
+// ImagesBuildOptions are the options to build the builder images from their Dockerfiles.
+type ImagesBuildOptions struct {
+	// Repo is the repository the images are tagged for, eg: docker.io/falcosecurity/driverkit-builder.
+	Repo string
+	// Tag is the versioned tag suffix, eg: latest.
+	Tag          string
+	Push         bool
+	CMakeVersion string
+}
+
+// BuildBuilderImages builds the builder images of the given Dockerfiles through the Docker API,
+// tagging them following the builder images naming scheme and optionally pushing them,
+// authenticating with the registries credentials of b. It returns the built image references.
+func BuildBuilderImages(printer *output.Printer, b *builder.Build, dockerfiles []builder.BuilderDockerfile, opts ImagesBuildOptions) ([]string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := signals.WithStandardSignals(context.Background())
+	cli.NegotiateAPIVersion(ctx)
+
+	var refs []string
+	for _, d := range dockerfiles {
+		ref := d.ImageRef(opts.Repo, opts.Tag)
+		printer.Logger.Info("building builder image",
+			printer.Logger.Args("dockerfile", d.Path, "image", ref))
+		if err = buildBuilderImage(ctx, printer, cli, d, ref, opts.CMakeVersion); err != nil {
+			return refs, fmt.Errorf("failed to build image %s: %w", ref, err)
+		}
+		refs = append(refs, ref)
+
+		if !opts.Push {
+			continue
+		}
+		printer.Logger.Info("pushing builder image",
+			printer.Logger.Args("image", ref))
+		if err = pushBuilderImage(ctx, printer, b, cli, ref); err != nil {
+			return refs, fmt.Errorf("failed to push image %s: %w", ref, err)
+		}
+	}
+	return refs, nil
+}
+
+func buildBuilderImage(ctx context.Context, printer *output.Printer, cli *client.Client, d builder.BuilderDockerfile, ref, cmakeVersion string) error {
+	dockerfile, err := os.ReadFile(d.Path)
+	if err != nil {
+		return err
+	}
+	// Builder Dockerfiles do not copy any file: the build context is the Dockerfile only.
+	var buildContext bytes.Buffer
+	name := filepath.Base(d.Path)
+	if err = tarWriterFiles(&buildContext, []dockerCopyFile{{Name: name, Body: string(dockerfile)}}); err != nil {
+		return err
+	}
+
+	arch := d.Arch().String()
+	res, err := cli.ImageBuild(ctx, &buildContext, build.ImageBuildOptions{
+		Tags:       []string{ref},
+		Dockerfile: name,
+		Platform:   "linux/" + arch,
+		Remove:     true,
+		BuildArgs: map[string]*string{
+			"CMAKE_VERSION": &cmakeVersion,
+			"TARGETARCH":    &arch,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return forwardJSONMessages(printer, res.Body)
+}
+
+func pushBuilderImage(ctx context.Context, printer *output.Printer, b *builder.Build, cli *client.Client, ref string) error {
+	auth, err := registryAuth(b, ref)
+	if err != nil {
+		return err
+	}
+	res, err := cli.ImagePush(ctx, ref, image.PushOptions{RegistryAuth: auth})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	return forwardJSONMessages(printer, res)
+}
+
+// forwardJSONMessages forwards the Docker API json messages stream to the debug logs,
+// returning the first reported error.
+func forwardJSONMessages(printer *output.Printer, stream io.Reader) error {
+	dec := json.NewDecoder(stream)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.Stream != "" {
+			printer.Logger.Debug(strings.TrimSuffix(msg.Stream, "\n"))
+		} else if msg.Status != "" {
+			printer.Logger.Debug(msg.Status, printer.Logger.Args("id", msg.ID))
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/signals"
+)
+
+// ImageCheckResult is the conformance check result of a builder image.
+type ImageCheckResult struct {
+	Image   string
+	Arch    string
+	Checks  []builder.ImageCheck
+	Missing []builder.ImageCheck
+	// Err is set when the image could not be checked at all.
+	Err error
+}
+
+// Failed returns true when the image could not be checked or misses any binary.
+func (r ImageCheckResult) Failed() bool {
+	return r.Err != nil || len(r.Missing) > 0
+}
+
+// CheckBuilderImages starts a container for each of the given builder images,
+// verifying that it provides every advertised gcc and clang version, and every tool the builder templates need.
+// Images of a foreign architecture need qemu to be registered in binfmt_misc.
+func CheckBuilderImages(printer *output.Printer, b *builder.Build, images []builder.Image) ([]ImageCheckResult, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := signals.WithStandardSignals(context.Background())
+	cli.NegotiateAPIVersion(ctx)
+	if _, err = cli.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	// Group the images by name, since an image provides multiple gcc versions
+	var names []string
+	byName := make(map[string][]builder.Image)
+	for _, img := range images {
+		if _, ok := byName[img.Name]; !ok {
+			names = append(names, img.Name)
+		}
+		byName[img.Name] = append(byName[img.Name], img)
+	}
+	slices.Sort(names)
+
+	res := make([]ImageCheckResult, 0, len(names))
+	for _, name := range names {
+		imgs := byName[name]
+		result := ImageCheckResult{
+			Image:  name,
+			Arch:   imgs[0].Architecture().String(),
+			Checks: builder.ImageChecks(imgs),
+		}
+		printer.Logger.Info("checking builder image",
+			printer.Logger.Args("image", name, "arch", result.Arch))
+		out, err := runImageChecks(ctx, printer, b, cli, name, result.Arch, builder.ImageChecksScript(result.Checks))
+		if err != nil {
+			result.Err = err
+		} else {
+			result.Missing = builder.MissingImageChecks(result.Checks, out)
+		}
+		res = append(res, result)
+	}
+	return res, nil
+}
+
+// runImageChecks runs the checks script in a container of the image, returning its stdout.
+func runImageChecks(ctx context.Context, printer *output.Printer, b *builder.Build, cli *client.Client, ref, arch, script string) (string, error) {
+	if inspect, err := cli.ImageInspect(ctx, ref); client.IsErrNotFound(err) || inspect.Architecture != arch {
+		printer.Logger.Debug("pulling builder image",
+			printer.Logger.Args("image", ref, "arch", arch))
+		auth, err := registryAuth(b, ref)
+		if err != nil {
+			return "", err
+		}
+		pullRes, err := cli.ImagePull(ctx, ref, image.PullOptions{Platform: arch, RegistryAuth: auth})
+		if err != nil {
+			return "", err
+		}
+		defer pullRes.Close()
+		if _, err = io.Copy(io.Discard, pullRes); err != nil {
+			return "", err
+		}
+	}
+
+	cdata, err := cli.ContainerCreate(ctx,
+		&container.Config{
+			Entrypoint: []string{"/bin/sh", "-c"},
+			Cmd:        []string{script},
+			Image:      ref,
+		}, nil, nil, &v1.Platform{Architecture: arch, OS: "linux"}, "")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := cli.ContainerRemove(context.Background(), cdata.ID, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			printer.Logger.Error("error removing container",
+				printer.Logger.Args("err", err.Error()))
+		}
+	}()
+
+	if err = cli.ContainerStart(ctx, cdata.ID, container.StartOptions{}); err != nil {
+		return "", err
+	}
+	statusCh, errCh := cli.ContainerWait(ctx, cdata.ID, container.WaitConditionNotRunning)
+	select {
+	case err = <-errCh:
+		if err != nil {
+			return "", err
+		}
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return "", fmt.Errorf("checks exited with code %d", status.StatusCode)
+		}
+	}
+
+	logs, err := cli.ContainerLogs(ctx, cdata.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err = stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return "", err
+	}
+	if stderr.Len() > 0 {
+		printer.Logger.Debug(stderr.String())
+	}
+	return stdout.String(), nil
+}
@@ -20,7 +20,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/falcosecurity/falcoctl/pkg/output"
@@ -29,6 +31,7 @@ import (
 	"github.com/falcosecurity/driverkit/pkg/signals"
 
 	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -50,6 +53,7 @@ type KubernetesBuildProcessor struct {
 	runAsUser       int64
 	namespace       string
 	imagePullSecret string
+	createSecret    bool // create a pull secret for each builder pod, holding the builder image registry credentials
 	timeout         int
 	proxy           string
 	*output.Printer
@@ -64,6 +68,7 @@ func NewKubernetesBuildProcessor(corev1Client v1.CoreV1Interface,
 	runAsUser int64,
 	namespace string,
 	imagePullSecret string,
+	createPullSecret bool,
 	timeout int,
 	proxy string,
 ) *KubernetesBuildProcessor {
@@ -73,11 +78,39 @@ func NewKubernetesBuildProcessor(corev1Client v1.CoreV1Interface,
 		runAsUser:       runAsUser,
 		namespace:       namespace,
 		imagePullSecret: imagePullSecret,
+		createSecret:    createPullSecret,
 		timeout:         timeout,
 		proxy:           proxy,
 	}
 }
 
+// nodesArchitecture returns the architecture of the cluster nodes to run the builder pod on:
+// arch itself, when any node provides it, otherwise the first supported one of the other nodes,
+// to cross build there. When the nodes cannot be listed, eg: for lack of permissions, arch is assumed.
+func (bp *KubernetesBuildProcessor) nodesArchitecture(ctx context.Context, arch string) string {
+	nodes, err := bp.coreV1Client.Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		bp.Logger.Debug("cannot list the cluster nodes, assuming they provide the target architecture",
+			bp.Logger.Args("arch", arch, "err", err.Error()))
+		return arch
+	}
+	nodesArchs := make(map[string]bool)
+	for _, node := range nodes.Items {
+		nodesArchs[node.Labels[corev1.LabelArchStable]] = true
+	}
+	if nodesArchs[arch] {
+		return arch
+	}
+	for _, nodesArch := range kernelrelease.SupportedArchs.Strings() {
+		if nodesArchs[nodesArch] {
+			bp.Logger.Debug("no cluster node provides the target architecture, cross building",
+				bp.Logger.Args("arch", arch, "nodesArch", nodesArch))
+			return nodesArch
+		}
+	}
+	return arch
+}
+
 func (bp *KubernetesBuildProcessor) String() string {
 	return KubernetesBuildProcessorName
 }
@@ -85,15 +118,17 @@ func (bp *KubernetesBuildProcessor) String() string {
 func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 	bp.Printer = b.Printer
 
-	deadline := int64(bp.timeout)
-	namespace := bp.namespace
-	uid := uuid.NewUUID()
-	name := fmt.Sprintf("driverkit-%s", string(uid))
+	// The builder pod runs on a cluster node, not on the host running driverkit
+	b.HostArchitecture = bp.nodesArchitecture(context.Background(), b.Architecture)
 
-	podClient := bp.coreV1Client.Pods(namespace)
-	configClient := bp.coreV1Client.ConfigMaps(namespace)
+	kr, err := b.KernelReleaseFromBuildConfig()
+	if err != nil {
+		return err
+	}
 
-	kr := b.KernelReleaseFromBuildConfig()
+	if err := b.CheckKernelConfig(kr); err != nil {
+		return err
+	}
 
 	// create a builder based on the chosen build type
 	v, err := builder.Factory(b.TargetType)
@@ -119,6 +154,109 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 		return err
 	}
 
+	configDecoded, err := base64.StdEncoding.DecodeString(b.KernelConfigData)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = signals.WithStandardSignals(ctx)
+
+	// When the kernel module fails to build, retry with the next-best gcc versions, if any:
+	// each builder pod tries all the ones provided by its builder image, downloading libs and kernel headers once.
+	fallbacks := b.GCCFallbacks()
+	for {
+		builderImage := b.GetBuilderImage()
+		attempts := []gccBuildScript{{gcc: b.GCCVersion, script: res}}
+		switchImage := false
+		for len(fallbacks) > 0 && !switchImage {
+			b.GCCVersion = fallbacks[0].String()
+			fallbacks = fallbacks[1:]
+			if res, err = builder.Script(v, c, kr); err != nil {
+				return err
+			}
+			if b.GetBuilderImage() != builderImage {
+				switchImage = true
+			} else {
+				attempts = append(attempts, gccBuildScript{gcc: b.GCCVersion, script: res})
+			}
+		}
+
+		pinned := b.PinnedBuilderImage(builderImage)
+		if err = b.VerifyBuilderImage(pinned); err != nil {
+			return err
+		}
+		gcc, err := bp.runPod(ctx, b, c, pinned, libsDownloadScript, kernelDownloadScript, string(configDecoded), attempts)
+		if err == nil {
+			b.GCCVersion = gcc
+			return nil
+		}
+		if !switchImage {
+			return err
+		}
+		bp.Logger.Warn("kernel module build failed, retrying with another builder image",
+			bp.Logger.Args("failedImage", builderImage, "err", err.Error()))
+	}
+}
+
+// gccBuildScript is the build script for a gcc version.
+type gccBuildScript struct {
+	gcc    string
+	script string
+}
+
+// gccAttemptsScript runs the build scripts in order, until one of them builds the kernel module,
+// recording the gcc version it was built with.
+func gccAttemptsScript(c builder.Config, attempts []gccBuildScript) string {
+	var sb strings.Builder
+	for i, attempt := range attempts {
+		if i == 0 {
+			sb.WriteString("if ")
+		} else {
+			// Only retry when the kernel module is the one that failed to build
+			fmt.Fprintf(&sb, "elif [ ! -f %s ] && ", c.ToDriverFullPath())
+		}
+		fmt.Fprintf(&sb, "/bin/bash /driverkit/driverkit-%d.sh; then\n  echo %s > %s\n", i, attempt.gcc, gccVersionFile)
+	}
+	sb.WriteString("else\n  exit 1\nfi")
+	return sb.String()
+}
+
+// runPod builds the drivers in a pod of the builder image, trying the build scripts in order,
+// and copies them back; it returns the gcc version the kernel module was built with.
+func (bp *KubernetesBuildProcessor) runPod(ctx context.Context,
+	b *builder.Build,
+	c builder.Config,
+	builderImage string,
+	libsDownloadScript string,
+	kernelDownloadScript string,
+	kernelConfig string,
+	attempts []gccBuildScript,
+) (string, error) {
+	deadline := int64(bp.timeout)
+	namespace := bp.namespace
+	uid := uuid.NewUUID()
+	name := fmt.Sprintf("driverkit-%s", string(uid))
+
+	podClient := bp.coreV1Client.Pods(namespace)
+	configClient := bp.coreV1Client.ConfigMaps(namespace)
+
+	cmData := map[string]string{
+		"download-libs.sh":    libsDownloadScript,
+		"download-headers.sh": kernelDownloadScript,
+		"kernel.config":       kernelConfig,
+		"downloader.sh":       waitForLockAndCat,
+		"unlock.sh":           deleteLock,
+	}
+
+	res := attempts[0].script
+	if len(attempts) > 1 {
+		for i, attempt := range attempts {
+			cmData[fmt.Sprintf("driverkit-%d.sh", i)] = attempt.script
+		}
+		res = gccAttemptsScript(c, attempts)
+	}
+
 	// We run a script that downloads libs,
 	// then downloads and extracts kernelURLs exporting KERNELDIR env variable,
 	// then finally runs the build script.
@@ -128,6 +266,10 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 		res = fmt.Sprintf("%s\n%s", "touch "+moduleLockFile, res)
 		res = fmt.Sprintf("%s\n%s", res, "rm "+moduleLockFile)
 	}
+	if c.ProbeFilePath != "" {
+		res = fmt.Sprintf("%s\n%s", "touch "+probeLockFile, res)
+		res = fmt.Sprintf("%s\n%s", res, "rm "+probeLockFile)
+	}
 
 	// Append a script to the entrypoint to wait
 	// for the module to be ready before exiting PID 1
@@ -147,21 +289,10 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 		},
 	}
 
-	configDecoded, err := base64.StdEncoding.DecodeString(b.KernelConfigData)
-	if err != nil {
-		return err
-	}
-
+	cmData["driverkit.sh"] = res
 	cm := &corev1.ConfigMap{
 		ObjectMeta: commonMeta,
-		Data: map[string]string{
-			"download-libs.sh":    libsDownloadScript,
-			"download-headers.sh": kernelDownloadScript,
-			"driverkit.sh":        res,
-			"kernel.config":       string(configDecoded),
-			"downloader.sh":       waitForLockAndCat,
-			"unlock.sh":           deleteLock,
-		},
+		Data:       cmData,
 	}
 	// Construct environment variable array of corev1.EnvVar
 	var envs []corev1.EnvVar
@@ -178,8 +309,14 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 			},
 		)
 	}
-
-	builderImage := b.GetBuilderImage()
+	// Add the kbuild variables needed to cross-compile, if any
+	for _, env := range b.CrossCompileEnv() {
+		name, value, _ := strings.Cut(env, "=")
+		envs = append(envs, corev1.EnvVar{
+			Name:  name,
+			Value: value,
+		})
+	}
 
 	secuContext := corev1.PodSecurityContext{
 		RunAsUser: &bp.runAsUser,
@@ -189,6 +326,19 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 	if bp.imagePullSecret != "" {
 		imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{Name: bp.imagePullSecret})
 	}
+	var pullSecret *corev1.Secret
+	if bp.createSecret {
+		dockerConfig, err := dockerConfigJSON(b, builderImage)
+		if err != nil {
+			return "", fmt.Errorf("cannot create the builder image pull secret: %w", err)
+		}
+		pullSecret = &corev1.Secret{
+			ObjectMeta: commonMeta,
+			Type:       corev1.SecretTypeDockerConfigJson,
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+		}
+		imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{Name: pullSecret.Name})
+	}
 
 	pod := &corev1.Pod{
 		ObjectMeta: commonMeta,
@@ -197,7 +347,7 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 			RestartPolicy:         corev1.RestartPolicyNever,
 			SecurityContext:       &secuContext,
 			ImagePullSecrets:      imagePullSecrets,
-			NodeSelector:          map[string]string{corev1.LabelArchStable: kr.Architecture.String()},
+			NodeSelector:          map[string]string{corev1.LabelArchStable: b.BuilderImageArchitecture()},
 			Containers: []corev1.Container{
 				{
 					Name:            name,
@@ -243,28 +393,34 @@ func (bp *KubernetesBuildProcessor) Start(b *builder.Build) error {
 	bp.Logger.Debug("starting pod",
 		bp.Logger.Args("name", pod.Name, "spec", pod.Spec.String()))
 
-	ctx := context.Background()
-	ctx = signals.WithStandardSignals(ctx)
-	_, err = configClient.Create(ctx, cm, metav1.CreateOptions{})
+	_, err := configClient.Create(ctx, cm, metav1.CreateOptions{})
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer configClient.Delete(ctx, cm.Name, metav1.DeleteOptions{})
+	if pullSecret != nil {
+		secretClient := bp.coreV1Client.Secrets(namespace)
+		if _, err = secretClient.Create(ctx, pullSecret, metav1.CreateOptions{}); err != nil {
+			return "", err
+		}
+		defer secretClient.Delete(ctx, pullSecret.Name, metav1.DeleteOptions{})
+	}
 	_, err = podClient.Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer podClient.Delete(ctx, pod.Name, metav1.DeleteOptions{})
-	return bp.copyModuleFromPodWithUID(ctx, c, b, namespace, string(uid))
+	return bp.copyModuleFromPodWithUID(ctx, c, b, namespace, string(uid), attempts)
 }
 
-func (bp *KubernetesBuildProcessor) copyModuleFromPodWithUID(ctx context.Context, c builder.Config, build *builder.Build, namespace string, falcoBuilderUID string) error {
+func (bp *KubernetesBuildProcessor) copyModuleFromPodWithUID(ctx context.Context, c builder.Config, build *builder.Build, namespace string, falcoBuilderUID string, attempts []gccBuildScript) (string, error) {
+	gcc := attempts[0].gcc
 	namespacedClient := bp.coreV1Client.Pods(namespace)
 	watch, err := namespacedClient.Watch(ctx, metav1.ListOptions{
 		LabelSelector: fmt.Sprintf("%s=%s", falcoBuilderUIDLabel, falcoBuilderUID),
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 	// Give it ten minutes to complete, if it doesn't give an error
 	// TODO(fntlnz): maybe pass this from the outside?
@@ -273,7 +429,7 @@ func (bp *KubernetesBuildProcessor) copyModuleFromPodWithUID(ctx context.Context
 	for {
 		select {
 		case <-ctx.Done():
-			return errors.New("module copy from pod interrupted before the copy was complete")
+			return "", errors.New("module copy from pod interrupted before the copy was complete")
 		default:
 			event := <-watch.ResultChan()
 			p, ok := event.Object.(*corev1.Pod)
@@ -284,24 +440,43 @@ func (bp *KubernetesBuildProcessor) copyModuleFromPodWithUID(ctx context.Context
 			if p.Status.Phase == corev1.PodPending {
 				continue
 			}
+			if p.Status.Phase == corev1.PodFailed {
+				return "", errors.New("builder pod failed")
+			}
 			if p.Status.Phase == corev1.PodRunning {
 				bp.Logger.Info("start downloading module from pod",
 					bp.Logger.Args(falcoBuilderUIDLabel, falcoBuilderUID))
 				if c.ModuleFilePath != "" {
 					err = copySingleFileFromPod(c.ModuleFilePath, bp.coreV1Client, bp.clientConfig, p.Namespace, p.Name, c.ToDriverFullPath(), moduleLockFile)
 					if err != nil {
-						return err
+						return "", err
+					}
+					if len(attempts) > 1 {
+						// Find out the gcc version that finally built the kernel module
+						var out bytes.Buffer
+						err = streamSingleFileFromPod(&out, bp.coreV1Client, bp.clientConfig, p.Namespace, p.Name, gccVersionFile, moduleLockFile)
+						if err != nil {
+							return "", err
+						}
+						gcc = strings.TrimSpace(out.String())
+					}
+					bp.Logger.Info("Kernel Module extraction successful", bp.Logger.Args("gcc", gcc))
+				}
+				if c.ProbeFilePath != "" {
+					err = copySingleFileFromPod(c.ProbeFilePath, bp.coreV1Client, bp.clientConfig, p.Namespace, p.Name, c.ToProbeFullPath(), probeLockFile)
+					if err != nil {
+						return "", err
 					}
-					bp.Logger.Info("Kernel Module extraction successful")
+					bp.Logger.Info("eBPF probe extraction successful")
 				}
 				err = unlockPod(bp.coreV1Client, bp.clientConfig, p)
 				if err != nil {
-					return err
+					return "", err
 				}
 				bp.Logger.Info("completed downloading from pod",
 					bp.Logger.Args(falcoBuilderUIDLabel, falcoBuilderUID))
 			}
-			return nil
+			return gcc, nil
 		}
 	}
 }
@@ -350,6 +525,10 @@ func copySingleFileFromPod(dstFile string, podClient v1.PodsGetter, clientConfig
 	}
 	defer out.Close()
 
+	return streamSingleFileFromPod(out, podClient, clientConfig, namespace, podName, fileNameToCopy, lockFilename)
+}
+
+func streamSingleFileFromPod(out io.Writer, podClient v1.PodsGetter, clientConfig *restclient.Config, namespace string, podName string, fileNameToCopy string, lockFilename string) error {
 	options := &exec.ExecOptions{
 		PodClient: podClient,
 		Config:    clientConfig,
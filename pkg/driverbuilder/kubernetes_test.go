@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+)
+
+func TestGCCAttemptsScript(t *testing.T) {
+	c := (&builder.Build{ModuleDriverName: "falco"}).ToConfig()
+	script := gccAttemptsScript(c, []gccBuildScript{
+		{gcc: "12.0.0", script: "#!/bin/bash"},
+		{gcc: "11.0.0", script: "#!/bin/bash"},
+	})
+	assert.Equal(t, script, `if /bin/bash /driverkit/driverkit-0.sh; then
+  echo 12.0.0 > /tmp/driverkit-gcc
+elif [ ! -f /tmp/driver/build/driver/falco.ko ] && /bin/bash /driverkit/driverkit-1.sh; then
+  echo 11.0.0 > /tmp/driverkit-gcc
+else
+  exit 1
+fi`)
+}
+
+func TestNodesArchitecture(t *testing.T) {
+	node := func(name, arch string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{corev1.LabelArchStable: arch},
+		}}
+	}
+	tests := map[string]struct {
+		nodes    []*corev1.Node
+		arch     string
+		expected string
+	}{
+		"target arch node": {
+			nodes:    []*corev1.Node{node("a", "amd64"), node("b", "arm64")},
+			arch:     "arm64",
+			expected: "arm64",
+		},
+		"foreign arch nodes": {
+			nodes:    []*corev1.Node{node("a", "s390x"), node("b", "amd64")},
+			arch:     "arm64",
+			expected: "amd64",
+		},
+		"no nodes": {
+			arch:     "arm64",
+			expected: "arm64",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewClientset()
+			for _, n := range test.nodes {
+				_, err := client.CoreV1().Nodes().Create(context.Background(), n, metav1.CreateOptions{})
+				assert.NilError(t, err)
+			}
+			bp := NewKubernetesBuildProcessor(client.CoreV1(), nil, 0, "default", "", false, 0, "")
+			bp.Printer = output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout)
+			assert.Equal(t, bp.nodesArchitecture(context.Background(), test.arch), test.expected)
+		})
+	}
+}
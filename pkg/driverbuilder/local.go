@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
 	"github.com/falcosecurity/falcoctl/pkg/output"
 )
 
@@ -69,7 +70,14 @@ func (lbp *LocalBuildProcessor) Start(b *builder.Build) error {
 	}
 
 	// We don't want to download headers
-	kr := b.KernelReleaseFromBuildConfig()
+	kr, err := b.KernelReleaseFromBuildConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := b.CheckKernelConfig(kr); err != nil {
+		return err
+	}
 
 	if lbp.downloadHeaders {
 		// Download headers for current distro
@@ -156,8 +164,9 @@ func (lbp *LocalBuildProcessor) Start(b *builder.Build) error {
 	vv.SrcDir = lbp.srcDir
 	vv.UseDKMS = lbp.useDKMS
 
-	// Fetch paths were kmod will be built
+	// Fetch paths were kmod and probe will be built
 	srcModulePath := vv.GetModuleFullPath(c, kr)
+	srcProbePath := vv.GetProbeFullPath(c)
 
 	if len(lbp.srcDir) == 0 {
 		lbp.Logger.Info("Downloading driver sources")
@@ -172,27 +181,44 @@ func (lbp *LocalBuildProcessor) Start(b *builder.Build) error {
 		}
 	}
 
+	return lbp.buildWithGCCs(vv, c, kr, gccs, srcModulePath, srcProbePath)
+}
+
+// runLocalScript runs a build script on the host, returning its combined output.
+var runLocalScript = func(ctx context.Context, script string, env []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "/bin/bash", "-c", script)
+	cmd.Env = env
+	return cmd.CombinedOutput()
+}
+
+// buildWithGCCs builds the requested drivers, trying each gcc until the kernel module is built.
+func (lbp *LocalBuildProcessor) buildWithGCCs(vv *builder.LocalBuilder, c builder.Config, kr kernelrelease.KernelRelease,
+	gccs []string, srcModulePath, srcProbePath string,
+) error {
+	probeFailed := false
 	for _, gcc := range gccs {
 		vv.GccPath = gcc
 		if c.ModuleFilePath != "" {
 			lbp.Logger.Info("Trying to dkms install module.", lbp.Logger.Args("gcc", gcc))
 		}
+		if c.ProbeFilePath != "" {
+			lbp.Logger.Info("Trying to build eBPF probe.")
+		}
 
 		// Generate the build script from the builder
-		driverkitScript, err := builder.Script(v, c, kr)
+		driverkitScript, err := builder.Script(vv, c, kr)
 		if err != nil {
 			return err
 		}
 		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Duration(lbp.timeout)*time.Second)
 		defer cancelFunc()
-		cmd := exec.CommandContext(ctx, "/bin/bash", "-c", driverkitScript)
-		cmd.Env = os.Environ()
+		env := os.Environ()
 		// Append requested env variables to the command env
 		for key, val := range lbp.envMap {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+			env = append(env, fmt.Sprintf("%s=%s", key, val))
 		}
 
-		out, err := cmd.CombinedOutput()
+		out, err := runLocalScript(ctx, driverkitScript, env)
 		if !lbp.printOnError || err != nil {
 			// Only print on error
 			lbp.DefaultText.Print(string(out))
@@ -200,17 +226,17 @@ func (lbp *LocalBuildProcessor) Start(b *builder.Build) error {
 
 		// If we received an error, perhaps we just need to try another build for the kmod.
 		// Check if we were able to build anything.
+		moduleFailed := false
 		if c.ModuleFilePath != "" {
 			koFiles, err := filepath.Glob(srcModulePath)
 			if err == nil && len(koFiles) > 0 {
-				// Since only kmod might need to get rebuilt with another gcc, break here if we actually built the kmod.
 				if err = copyDataToLocalPath(koFiles[0], c.ModuleFilePath); err != nil {
 					return err
 				}
-				lbp.Logger.Info("kernel module available.", lbp.Logger.Args("path", b.ModuleFilePath))
+				lbp.Logger.Info("kernel module available.", lbp.Logger.Args("path", c.ModuleFilePath))
 				c.ModuleFilePath = ""
-				break
 			} else {
+				moduleFailed = true
 				// print dkms build log
 				dkmsLogFile := fmt.Sprintf("/var/lib/dkms/%s/%s/build/make.log", c.DriverName, c.DriverVersion)
 				logs, err := os.ReadFile(filepath.Clean(dkmsLogFile))
@@ -227,9 +253,29 @@ func (lbp *LocalBuildProcessor) Start(b *builder.Build) error {
 				}
 			}
 		}
+		// The script stops at the first failure and builds the probe after the kmod:
+		// the probe was only attempted when the kmod was built, or not requested.
+		if c.ProbeFilePath != "" && !moduleFailed {
+			// The probe does not depend on gcc,
+			// so there is no need to try building it again on failure.
+			if _, err = os.Stat(srcProbePath); err == nil {
+				if err = copyDataToLocalPath(srcProbePath, c.ProbeFilePath); err != nil {
+					return err
+				}
+				lbp.Logger.Info("eBPF probe available.", lbp.Logger.Args("path", c.ProbeFilePath))
+			} else {
+				lbp.Logger.Warn("Failed to build eBPF probe.")
+				probeFailed = true
+			}
+			c.ProbeFilePath = ""
+		}
+		// Since only kmod might need to get rebuilt with another gcc, break here if we actually built the kmod.
+		if !moduleFailed {
+			break
+		}
 	}
 
-	if c.ModuleFilePath != "" {
+	if c.ModuleFilePath != "" || c.ProbeFilePath != "" || probeFailed {
 		return errors.New("failed to build requested driver")
 	}
 	return nil
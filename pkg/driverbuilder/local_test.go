@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driverbuilder
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/falcosecurity/falcoctl/pkg/output"
+	"github.com/pterm/pterm"
+	"gotest.tools/assert"
+
+	"github.com/falcosecurity/driverkit/pkg/driverbuilder/builder"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+func TestBuildWithGCCsProbeAfterModuleFailure(t *testing.T) {
+	srcDir := t.TempDir()
+	srcModulePath := filepath.Join(srcDir, "falco.ko")
+	srcProbePath := filepath.Join(srcDir, "probe.o")
+
+	// Like local.sh, the script stops when the kmod fails, before building the probe:
+	// only gcc-13 builds the kmod, then the probe.
+	var attempts []string
+	oldRun := runLocalScript
+	runLocalScript = func(_ context.Context, script string, _ []string) ([]byte, error) {
+		for _, gcc := range []string{"gcc-12", "gcc-13"} {
+			if strings.Contains(script, "make CC="+gcc) {
+				attempts = append(attempts, gcc)
+			}
+		}
+		if !strings.Contains(script, "make CC=gcc-13") {
+			return nil, errors.New("make failed")
+		}
+		if err := os.WriteFile(srcModulePath, []byte("kmod"), 0o644); err != nil {
+			return nil, err
+		}
+		return nil, os.WriteFile(srcProbePath, []byte("probe"), 0o644)
+	}
+	t.Cleanup(func() {
+		runLocalScript = oldRun
+	})
+
+	outDir := t.TempDir()
+	b := &builder.Build{
+		ModuleDriverName: "falco",
+		ModuleFilePath:   filepath.Join(outDir, "falco.ko"),
+		ProbeFilePath:    filepath.Join(outDir, "probe.o"),
+		DriverVersion:    "1.0.0",
+		Printer:          output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+	}
+	lbp := NewLocalBuildProcessor(false, false, true, srcDir, nil, 10)
+	lbp.Printer = b.Printer
+	kr := kernelrelease.FromString("6.1.0-13-amd64")
+
+	err := lbp.buildWithGCCs(&builder.LocalBuilder{SrcDir: srcDir}, b.ToConfig(), kr,
+		[]string{"gcc-12", "gcc-13"}, srcModulePath, srcProbePath)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, attempts, []string{"gcc-12", "gcc-13"})
+	_, err = os.Stat(filepath.Join(outDir, "falco.ko"))
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(outDir, "probe.o"))
+	assert.NilError(t, err)
+}
+
+func TestLocalStartChecksKernelConfig(t *testing.T) {
+	b := &builder.Build{
+		KernelRelease:    "6.1.0-13-amd64",
+		Architecture:     kernelrelease.ArchitectureAmd64,
+		KernelConfigData: base64.StdEncoding.EncodeToString([]byte("CONFIG_BPF_SYSCALL=y\n")),
+		ModuleFilePath:   filepath.Join(t.TempDir(), "falco.ko"),
+		Printer:          output.NewPrinter(pterm.LogLevelInfo, pterm.LogFormatterColorful, os.Stdout),
+	}
+	err := NewLocalBuildProcessor(false, false, true, "", nil, 10).Start(b)
+	assert.ErrorContains(t, err, "kmod cannot work on this kernel: CONFIG_MODULES is not enabled")
+}
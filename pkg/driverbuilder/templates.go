@@ -32,6 +32,10 @@ rm -f /tmp/download.lock
 `
 
 const moduleLockFile = "/tmp/module.lock"
+const probeLockFile = "/tmp/probe.lock"
+
+// gccVersionFile stores the gcc version the kernel module was built with, when multiple ones are tried.
+const gccVersionFile = "/tmp/driverkit-gcc"
 
 // waitForLockAndCat MUST only output the file, any other output will break the download file itself because it goes
 // through stdout.
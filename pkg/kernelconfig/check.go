@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+// Driver is a Falco driver.
+type Driver string
+
+const (
+	DriverModernBPF Driver = "modern_bpf"
+	DriverKmod      Driver = "kmod"
+	DriverEBPF      Driver = "ebpf"
+)
+
+// Drivers lists the Falco drivers in order of preference.
+var Drivers = []Driver{DriverModernBPF, DriverKmod, DriverEBPF}
+
+// The modern eBPF probe needs the BPF ring buffer, available since 5.8.
+var modernBPFMinKernelVersion = semver.MustParse("5.8.0")
+
+// Kernel config options each driver needs.
+// The BPF ring buffer has no option of its own: it comes with CONFIG_BPF_SYSCALL.
+var driverOptions = map[Driver][]string{
+	DriverModernBPF: {"CONFIG_BPF_SYSCALL", "CONFIG_DEBUG_INFO_BTF", "CONFIG_HAVE_SYSCALL_TRACEPOINTS"},
+	DriverKmod:      {"CONFIG_MODULES", "CONFIG_TRACEPOINTS", "CONFIG_HAVE_SYSCALL_TRACEPOINTS"},
+	DriverEBPF:      {"CONFIG_BPF_SYSCALL", "CONFIG_TRACEPOINTS", "CONFIG_HAVE_SYSCALL_TRACEPOINTS"},
+}
+
+func (d Driver) String() string {
+	return string(d)
+}
+
+// Result is the outcome of checking a driver against a kernel.
+type Result struct {
+	Driver Driver
+	// Problems explains why the driver cannot work: it is empty when it can.
+	Problems []string
+}
+
+// Supported tells whether the driver can work on the checked kernel.
+func (r Result) Supported() bool {
+	return len(r.Problems) == 0
+}
+
+// Err returns an error explaining why the driver cannot work, if any.
+func (r Result) Err() error {
+	if r.Supported() {
+		return nil
+	}
+	return fmt.Errorf("%s cannot work on this kernel: %s", r.Driver, strings.Join(r.Problems, ", "))
+}
+
+// Check tells whether the driver can work on a kernel release with the given config.
+func Check(kr kernelrelease.KernelRelease, cfg Config, driver Driver) Result {
+	res := Result{Driver: driver}
+
+	var supported bool
+	switch driver {
+	case DriverModernBPF:
+		supported = kr.GTE(modernBPFMinKernelVersion)
+	case DriverKmod:
+		supported = kr.SupportsModule()
+	case DriverEBPF:
+		supported = kr.SupportsProbe()
+	}
+	if !supported {
+		res.Problems = append(res.Problems, fmt.Sprintf("kernel release %s is too old", kr.Fullversion))
+	}
+
+	for _, option := range driverOptions[driver] {
+		if !cfg.Enabled(option) {
+			res.Problems = append(res.Problems, fmt.Sprintf("%s is not enabled", option))
+		}
+	}
+	return res
+}
+
+// CheckAll checks every driver, in order of preference.
+func CheckAll(kr kernelrelease.KernelRelease, cfg Config) []Result {
+	results := make([]Result, 0, len(Drivers))
+	for _, driver := range Drivers {
+		results = append(results, Check(kr, cfg, driver))
+	}
+	return results
+}
+
+// Recommend returns the preferred driver among the supported ones,
+// or false if none of them can work.
+func Recommend(results []Result) (Driver, bool) {
+	for _, res := range results {
+		if res.Supported() {
+			return res.Driver, true
+		}
+	}
+	return "", false
+}
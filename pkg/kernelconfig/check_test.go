@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelconfig
+
+import (
+	"testing"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+	"gotest.tools/assert"
+)
+
+func TestCheckAll(t *testing.T) {
+	full := Config{
+		"CONFIG_MODULES":                  "y",
+		"CONFIG_TRACEPOINTS":              "y",
+		"CONFIG_HAVE_SYSCALL_TRACEPOINTS": "y",
+		"CONFIG_BPF_SYSCALL":              "y",
+		"CONFIG_DEBUG_INFO_BTF":           "y",
+	}
+	noBTF := Config{
+		"CONFIG_MODULES":                  "y",
+		"CONFIG_TRACEPOINTS":              "y",
+		"CONFIG_HAVE_SYSCALL_TRACEPOINTS": "y",
+		"CONFIG_BPF_SYSCALL":              "y",
+	}
+	noModules := Config{
+		"CONFIG_TRACEPOINTS":              "y",
+		"CONFIG_HAVE_SYSCALL_TRACEPOINTS": "y",
+	}
+
+	tests := map[string]struct {
+		kernelRelease string
+		config        Config
+		wantSupported []Driver
+		wantRecommend Driver
+		wantOk        bool
+	}{
+		"recent kernel with btf": {
+			kernelRelease: "6.1.0-13-amd64",
+			config:        full,
+			wantSupported: []Driver{DriverModernBPF, DriverKmod, DriverEBPF},
+			wantRecommend: DriverModernBPF,
+			wantOk:        true,
+		},
+		"kernel older than 5.8": {
+			kernelRelease: "5.4.0-150-generic",
+			config:        full,
+			wantSupported: []Driver{DriverKmod, DriverEBPF},
+			wantRecommend: DriverKmod,
+			wantOk:        true,
+		},
+		"kernel without btf": {
+			kernelRelease: "6.1.0-13-amd64",
+			config:        noBTF,
+			wantSupported: []Driver{DriverKmod, DriverEBPF},
+			wantRecommend: DriverKmod,
+			wantOk:        true,
+		},
+		"kernel without modules nor bpf": {
+			kernelRelease: "6.1.0-13-amd64",
+			config:        noModules,
+			wantSupported: []Driver{},
+			wantOk:        false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			kr := kernelrelease.FromString(test.kernelRelease)
+			kr.Architecture = kernelrelease.ArchitectureAmd64
+			results := CheckAll(kr, test.config)
+			supported := []Driver{}
+			for _, res := range results {
+				if res.Supported() {
+					assert.NilError(t, res.Err())
+					supported = append(supported, res.Driver)
+				} else {
+					assert.Assert(t, res.Err() != nil)
+				}
+			}
+			assert.DeepEqual(t, supported, test.wantSupported)
+			driver, ok := Recommend(results)
+			assert.Equal(t, ok, test.wantOk)
+			assert.Equal(t, driver, test.wantRecommend)
+		})
+	}
+}
+
+func TestCheckExplains(t *testing.T) {
+	kr := kernelrelease.FromString("6.1.0-13-amd64")
+	kr.Architecture = kernelrelease.ArchitectureAmd64
+	res := Check(kr, Config{"CONFIG_TRACEPOINTS": "y"}, DriverKmod)
+	assert.Error(t, res.Err(), "kmod cannot work on this kernel: CONFIG_MODULES is not enabled, CONFIG_HAVE_SYSCALL_TRACEPOINTS is not enabled")
+
+	kr = kernelrelease.FromString("5.4.0-150-generic")
+	kr.Architecture = kernelrelease.ArchitectureAmd64
+	res = Check(kr, Config{"CONFIG_BPF_SYSCALL": "y", "CONFIG_DEBUG_INFO_BTF": "y", "CONFIG_HAVE_SYSCALL_TRACEPOINTS": "y"}, DriverModernBPF)
+	assert.Error(t, res.Err(), "modern_bpf cannot work on this kernel: kernel release 5.4.0 is too old")
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelconfig
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	// ErrNotFound is returned when no kernel config can be found in the given data.
+	ErrNotFound = errors.New("no kernel config found")
+
+	// Markers around the gzip compressed config embedded by CONFIG_IKCONFIG,
+	// see scripts/extract-ikconfig in the kernel tree.
+	ikconfigStart = []byte("IKCFG_ST")
+
+	gzipMagic  = []byte{0x1f, 0x8b, 0x08}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+// A decompressor for the streams a kernel image may be compressed with.
+type decompressor struct {
+	magic     []byte
+	newReader func(r io.Reader) (io.Reader, error)
+}
+
+var decompressors = []decompressor{
+	{
+		magic: gzipMagic,
+		newReader: func(r io.Reader) (io.Reader, error) {
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			// Kernel images are followed by trailing data: stop at the first stream
+			gr.Multistream(false)
+			return gr, nil
+		},
+	},
+	{
+		magic: xzMagic,
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		},
+	},
+	{
+		magic: zstdMagic,
+		newReader: func(r io.Reader) (io.Reader, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+	{
+		magic: bzip2Magic,
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+	},
+}
+
+// ReadFile returns the kernel config contained in the named file,
+// that can be either a plain or gzip compressed config (eg: a copy of /proc/config.gz),
+// or a vmlinux/vmlinuz kernel image built with CONFIG_IKCONFIG.
+func ReadFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Extract(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, name)
+	}
+	return cfg, nil
+}
+
+// Extract returns the kernel config contained in data,
+// see ReadFile for the supported formats.
+func Extract(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		decompressed, err := decompress(decompressors[0], data)
+		if err != nil {
+			return nil, err
+		}
+		return Extract(decompressed)
+	}
+
+	if isConfig(data) {
+		return data, nil
+	}
+
+	// vmlinux
+	if cfg, ok := extractIkconfig(data); ok {
+		return cfg, nil
+	}
+
+	// vmlinuz: the kernel image is compressed somewhere after the boot code,
+	// hence try each occurrence of every known magic.
+	for _, d := range decompressors {
+		for offset := bytes.Index(data, d.magic); offset >= 0; {
+			// Errors are expected here, since not every occurrence is an actual stream;
+			// anyway the decompressed data might be enough to find the config.
+			decompressed, _ := decompress(d, data[offset:])
+			if cfg, ok := extractIkconfig(decompressed); ok {
+				return cfg, nil
+			}
+			next := bytes.Index(data[offset+1:], d.magic)
+			if next < 0 {
+				break
+			}
+			offset += next + 1
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func decompress(d decompressor, data []byte) ([]byte, error) {
+	r, err := d.newReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return io.ReadAll(r)
+}
+
+// extractIkconfig returns the config embedded in a vmlinux by CONFIG_IKCONFIG, if any.
+func extractIkconfig(data []byte) ([]byte, bool) {
+	start := bytes.Index(data, ikconfigStart)
+	if start < 0 {
+		return nil, false
+	}
+	cfg, err := decompress(decompressors[0], data[start+len(ikconfigStart):])
+	if err != nil || !isConfig(cfg) {
+		return nil, false
+	}
+	return cfg, true
+}
+
+// isConfig tells whether data looks like a plain kernel config.
+func isConfig(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	cfg, err := Parse(bytes.NewReader(data))
+	return err == nil && !cfg.Empty()
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelconfig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"gotest.tools/assert"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(data)
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	return buf.Bytes()
+}
+
+func xzData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	assert.NilError(t, err)
+	_, err = w.Write(data)
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	assert.NilError(t, err)
+	_, err = w.Write(data)
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	return buf.Bytes()
+}
+
+// fakeVmlinux mimics the layout of a kernel image built with CONFIG_IKCONFIG.
+func fakeVmlinux(t *testing.T) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x7f, 'E', 'L', 'F', 0x02, 0x01, 0x01, 0x00, 0xff, 0xfe})
+	buf.WriteString("IKCFG_ST")
+	buf.Write(gzipData(t, []byte(testConfig)))
+	buf.WriteString("IKCFG_ED")
+	buf.Write([]byte{0x00, 0xff, 0xfe, 0x00})
+	return buf.Bytes()
+}
+
+// fakeVmlinuz mimics a compressed kernel image, preceded by boot code and followed by trailing data.
+func fakeVmlinuz(compressed []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x4d, 0x5a, 0xff, 0x00, 0x1f, 0x8b, 0x00, 'B', 'Z', 'h', 0xfe})
+	buf.Write(compressed)
+	buf.Write([]byte{0x00, 0x00, 0xff, 0xff})
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	tests := map[string][]byte{
+		"plain":        []byte(testConfig),
+		"gzip":         gzipData(t, []byte(testConfig)),
+		"vmlinux":      fakeVmlinux(t),
+		"gzip vmlinux": gzipData(t, fakeVmlinux(t)),
+		"gzip vmlinuz": fakeVmlinuz(gzipData(t, fakeVmlinux(t))),
+		"xz vmlinuz":   fakeVmlinuz(xzData(t, fakeVmlinux(t))),
+		"zstd vmlinuz": fakeVmlinuz(zstdData(t, fakeVmlinux(t))),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Extract(data)
+			assert.NilError(t, err)
+			assert.Equal(t, string(cfg), testConfig)
+		})
+	}
+}
+
+func TestExtractNotFound(t *testing.T) {
+	_, err := Extract([]byte{0x7f, 'E', 'L', 'F', 0x02, 0x01, 0xff, 0xfe})
+	assert.Assert(t, errors.Is(err, ErrNotFound))
+}
+
+func TestReadFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.gz")
+	assert.NilError(t, os.WriteFile(name, gzipData(t, []byte(testConfig)), 0o644))
+	cfg, err := ReadFile(name)
+	assert.NilError(t, err)
+	assert.Equal(t, string(cfg), testConfig)
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Assert(t, err != nil)
+}
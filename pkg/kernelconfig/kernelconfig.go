@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kernelconfig parses kernel configurations and checks them
+// against the requirements of the Falco drivers.
+package kernelconfig
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// Config maps the options of a kernel configuration to their values,
+// eg: "CONFIG_MODULES" to "y".
+// Options that are explicitly not set are not part of it.
+type Config map[string]string
+
+// Parse reads a kernel configuration in the .config format.
+func Parse(r io.Reader) (Config, error) {
+	cfg := Config{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip blank lines and comments, including "# CONFIG_FOO is not set"
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		option, value, found := strings.Cut(line, "=")
+		if !found || !strings.HasPrefix(option, "CONFIG_") {
+			continue
+		}
+		cfg[option] = strings.Trim(value, `"`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading kernel config: %w", err)
+	}
+	return cfg, nil
+}
+
+// FromBase64 parses a base64 encoded kernel configuration.
+func FromBase64(data string) (Config, error) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding kernel config: %w", err)
+	}
+	return Parse(strings.NewReader(string(decoded)))
+}
+
+// Enabled tells whether the option is built-in or built as a module.
+func (c Config) Enabled(option string) bool {
+	value := c[option]
+	return value == "y" || value == "m"
+}
+
+// Empty tells whether the configuration holds no options at all,
+// eg: when no kernel config was provided.
+func (c Config) Empty() bool {
+	return len(c) == 0
+}
+
+// gccVersionTextRegex matches the gcc version in CONFIG_CC_VERSION_TEXT,
+// eg: "gcc (Debian 12.2.0-14) 12.2.0" or "gcc (GCC) 8.5.0 20210514 (Red Hat 8.5.0-18)".
+var gccVersionTextRegex = regexp.MustCompile(`^gcc.*?[^0-9.]([0-9]+\.[0-9]+\.[0-9]+)`)
+
+// GCCVersion returns the version of gcc the kernel was built with, as stored in
+// CONFIG_GCC_VERSION (eg: 120200 for 12.2.0) or, for older kernels, CONFIG_CC_VERSION_TEXT;
+// ok is false when the kernel was not built with gcc or the version is unknown.
+func (c Config) GCCVersion() (semver.Version, bool) {
+	if v, err := strconv.ParseUint(c["CONFIG_GCC_VERSION"], 10, 64); err == nil && v > 0 {
+		return semver.Version{Major: v / 10000, Minor: v / 100 % 100, Patch: v % 100}, true
+	}
+	if match := gccVersionTextRegex.FindStringSubmatch(c["CONFIG_CC_VERSION_TEXT"]); match != nil {
+		if v, err := semver.Parse(match[1]); err == nil {
+			return v, true
+		}
+	}
+	return semver.Version{}, false
+}
+
+// ClangVersion tells whether the kernel was built with clang and, if known,
+// which version, as stored in CONFIG_CLANG_VERSION (eg: 140006 for 14.0.6).
+// The returned version is empty when the kernel does not state it.
+func (c Config) ClangVersion() (semver.Version, bool) {
+	if c["CONFIG_CC_IS_CLANG"] != "y" {
+		return semver.Version{}, false
+	}
+	v, err := strconv.ParseUint(c["CONFIG_CLANG_VERSION"], 10, 64)
+	if err != nil || v == 0 {
+		return semver.Version{}, true
+	}
+	return semver.Version{Major: v / 10000, Minor: v / 100 % 100, Patch: v % 100}, true
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelconfig
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"gotest.tools/assert"
+)
+
+const testConfig = `#
+# Automatically generated file; DO NOT EDIT.
+# Linux/x86 6.1.0 Kernel Configuration
+#
+CONFIG_CC_VERSION_TEXT="gcc (Debian 12.2.0-14) 12.2.0"
+CONFIG_MODULES=y
+CONFIG_TRACEPOINTS=y
+CONFIG_HAVE_SYSCALL_TRACEPOINTS=y
+CONFIG_BPF_SYSCALL=y
+CONFIG_DEBUG_INFO_BTF=y
+CONFIG_EXT4_FS=m
+# CONFIG_KPROBES is not set
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(testConfig))
+	assert.NilError(t, err)
+	assert.Equal(t, len(cfg), 7)
+	assert.Equal(t, cfg["CONFIG_CC_VERSION_TEXT"], "gcc (Debian 12.2.0-14) 12.2.0")
+	assert.Assert(t, cfg.Enabled("CONFIG_MODULES"))
+	assert.Assert(t, cfg.Enabled("CONFIG_EXT4_FS"))
+	assert.Assert(t, !cfg.Enabled("CONFIG_KPROBES"))
+	assert.Assert(t, !cfg.Enabled("CONFIG_MISSING"))
+}
+
+func TestFromBase64(t *testing.T) {
+	cfg, err := FromBase64(base64.StdEncoding.EncodeToString([]byte(testConfig)))
+	assert.NilError(t, err)
+	assert.Assert(t, cfg.Enabled("CONFIG_DEBUG_INFO_BTF"))
+
+	// The placeholder used when no kernel config data is provided
+	cfg, err = FromBase64("bm8tZGF0YQ==")
+	assert.NilError(t, err)
+	assert.Assert(t, cfg.Empty())
+
+	_, err = FromBase64("not base64!")
+	assert.ErrorContains(t, err, "error decoding kernel config")
+}
+
+func TestClangVersion(t *testing.T) {
+	_, ok := Config{"CONFIG_CC_IS_GCC": "y", "CONFIG_GCC_VERSION": "120200"}.ClangVersion()
+	assert.Assert(t, !ok)
+
+	v, ok := Config{"CONFIG_CC_IS_CLANG": "y", "CONFIG_CLANG_VERSION": "140006"}.ClangVersion()
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, v, semver.Version{Major: 14, Patch: 6})
+
+	v, ok = Config{"CONFIG_CC_IS_CLANG": "y"}.ClangVersion()
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, v, semver.Version{})
+}
+
+func TestGCCVersion(t *testing.T) {
+	v, ok := Config{"CONFIG_CC_IS_GCC": "y", "CONFIG_GCC_VERSION": "120200"}.GCCVersion()
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, v, semver.Version{Major: 12, Minor: 2})
+
+	v, ok = Config{"CONFIG_GCC_VERSION": "40805"}.GCCVersion()
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, v, semver.Version{Major: 4, Minor: 8, Patch: 5})
+
+	cfg, err := Parse(strings.NewReader(testConfig))
+	assert.NilError(t, err)
+	v, ok = cfg.GCCVersion()
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, v, semver.Version{Major: 12, Minor: 2})
+
+	v, ok = Config{"CONFIG_CC_VERSION_TEXT": "gcc (GCC) 8.5.0 20210514 (Red Hat 8.5.0-18)"}.GCCVersion()
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, v, semver.Version{Major: 8, Minor: 5})
+
+	_, ok = Config{"CONFIG_CC_IS_CLANG": "y", "CONFIG_GCC_VERSION": "0",
+		"CONFIG_CC_VERSION_TEXT": "Debian clang version 14.0.6"}.GCCVersion()
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelrelease
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	enterpriseLinuxPattern = regexp.MustCompile(`\.el(?P<major>\d+)(_(?P<minor>\d+))?(?P<uek>uek)?\.`)
+	fedoraPattern          = regexp.MustCompile(`\.fc(\d+)\.`)
+	amazonLinuxPattern     = regexp.MustCompile(`\.amzn(\d+)\.`)
+	ubuntuFlavorPattern    = regexp.MustCompile(`^([a-z-]+[a-z])-*\d?.*$`)
+	debianPattern          = regexp.MustCompile(`^-(?P<abi>\d+(\.bpo\.\d+)?)-(?P<flavor>[a-z][0-9a-z-]*)$`)
+)
+
+// EnterpriseLinuxRelease holds the metadata embedded in the release of
+// RHEL-like kernels, eg: "el9_1" in "5.14.0-162.6.1.el9_1.x86_64".
+type EnterpriseLinuxRelease struct {
+	Major uint64
+	// Minor is only meaningful when HasMinor is true:
+	// eg: it is not part of "el7" kernels.
+	Minor    uint64
+	HasMinor bool
+	// UEK is true for Oracle Unbreakable Enterprise Kernels, eg: "el7uek".
+	UEK bool
+}
+
+// UbuntuRelease holds the metadata of Ubuntu kernels,
+// eg: ABI "1019" and flavor "aws" for "5.15.0-1019-aws".
+type UbuntuRelease struct {
+	ABI    string
+	Flavor string
+}
+
+// DebianRelease holds the metadata of Debian kernels,
+// eg: ABI "13" and flavor "cloud-amd64" for "6.1.0-13-cloud-amd64".
+type DebianRelease struct {
+	ABI    string
+	Flavor string
+}
+
+// EnterpriseLinux returns the RHEL-like metadata of the kernel release,
+// or false if it is not a RHEL-like kernel release.
+func (k *KernelRelease) EnterpriseLinux() (EnterpriseLinuxRelease, bool) {
+	el := EnterpriseLinuxRelease{}
+	match := enterpriseLinuxPattern.FindStringSubmatch(k.FullExtraversion)
+	if match == nil {
+		return el, false
+	}
+	var err error
+	for i, name := range enterpriseLinuxPattern.SubexpNames() {
+		switch name {
+		case "major":
+			el.Major, err = strconv.ParseUint(match[i], 10, 64)
+		case "minor":
+			if len(match[i]) > 0 {
+				el.Minor, err = strconv.ParseUint(match[i], 10, 64)
+				el.HasMinor = true
+			}
+		case "uek":
+			el.UEK = len(match[i]) > 0
+		}
+		if err != nil {
+			return EnterpriseLinuxRelease{}, false
+		}
+	}
+	return el, true
+}
+
+// Fedora returns the Fedora release of the kernel release, eg: 36 for "5.17.5-300.fc36.x86_64",
+// or false if it is not a Fedora kernel release.
+func (k *KernelRelease) Fedora() (uint64, bool) {
+	return parseReleaseNumber(fedoraPattern, k.FullExtraversion)
+}
+
+// AmazonLinux returns the Amazon Linux generation of the kernel release,
+// eg: 2 for "4.14.171-136.231.amzn2.x86_64" or 2023 for "6.1.55-75.123.amzn2023.x86_64",
+// or false if it is not an Amazon Linux kernel release.
+func (k *KernelRelease) AmazonLinux() (uint64, bool) {
+	return parseReleaseNumber(amazonLinuxPattern, k.FullExtraversion)
+}
+
+// Ubuntu returns the Ubuntu metadata of the kernel release.
+// The flavor is assumed to be "generic" when it cannot be parsed.
+// NOTE: make sure the kernel release appears *exactly* as `uname -r` output.
+func (k *KernelRelease) Ubuntu() UbuntuRelease {
+	if !strings.Contains(k.Extraversion, "-") {
+		// if unable to parse a flavor assume "generic" and return back the extraversion
+		return UbuntuRelease{ABI: k.Extraversion, Flavor: "generic"}
+	}
+
+	abi, flavorText, _ := strings.Cut(k.Extraversion, "-")
+
+	// ubuntu names flavors in 3 (known) styles, examples:
+	// 		1. "generic"
+	// 		2. "generic-5"
+	// 		3. "generic-5.15"
+	// but some come in with multi-part names, such as:
+	// 		"intel-iotg-5.15"
+	match := ubuntuFlavorPattern.FindStringSubmatch(flavorText)
+	if match == nil {
+		return UbuntuRelease{ABI: abi, Flavor: "generic"}
+	}
+	return UbuntuRelease{ABI: abi, Flavor: match[1]}
+}
+
+// Debian returns the Debian metadata of the kernel release,
+// or false if it is not a Debian kernel release.
+func (k *KernelRelease) Debian() (DebianRelease, bool) {
+	match := debianPattern.FindStringSubmatch(k.FullExtraversion)
+	if match == nil {
+		return DebianRelease{}, false
+	}
+	return DebianRelease{
+		ABI:    match[debianPattern.SubexpIndex("abi")],
+		Flavor: match[debianPattern.SubexpIndex("flavor")],
+	}, true
+}
+
+func parseReleaseNumber(pattern *regexp.Regexp, s string) (uint64, bool) {
+	match := pattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelrelease
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestEnterpriseLinux(t *testing.T) {
+	tests := map[string]struct {
+		kernelVersionStr string
+		want             EnterpriseLinuxRelease
+		wantOk           bool
+	}{
+		"centos 7": {
+			kernelVersionStr: "3.10.0-1160.el7.x86_64",
+			want:             EnterpriseLinuxRelease{Major: 7},
+			wantOk:           true,
+		},
+		"rocky 9.1": {
+			kernelVersionStr: "5.14.0-162.6.1.el9_1.x86_64",
+			want:             EnterpriseLinuxRelease{Major: 9, Minor: 1, HasMinor: true},
+			wantOk:           true,
+		},
+		"oracle uek": {
+			kernelVersionStr: "5.4.17-2136.307.3.1.el7uek.x86_64",
+			want:             EnterpriseLinuxRelease{Major: 7, UEK: true},
+			wantOk:           true,
+		},
+		"fedora": {
+			kernelVersionStr: "5.17.5-300.fc36.x86_64",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			kr := FromString(tt.kernelVersionStr)
+			got, ok := kr.EnterpriseLinux()
+			assert.Equal(t, tt.wantOk, ok)
+			assert.DeepEqual(t, tt.want, got)
+		})
+	}
+}
+
+func TestReleaseNumbers(t *testing.T) {
+	kr := FromString("5.17.5-300.fc36.x86_64")
+	fedora, ok := kr.Fedora()
+	assert.Assert(t, ok)
+	assert.Equal(t, uint64(36), fedora)
+	_, ok = kr.AmazonLinux()
+	assert.Assert(t, !ok)
+
+	kr = FromString("6.1.55-75.123.amzn2023.x86_64")
+	generation, ok := kr.AmazonLinux()
+	assert.Assert(t, ok)
+	assert.Equal(t, uint64(2023), generation)
+	_, ok = kr.Fedora()
+	assert.Assert(t, !ok)
+}
+
+func TestUbuntu(t *testing.T) {
+	tests := map[string]UbuntuRelease{
+		"4.15.0-188":               {ABI: "188", Flavor: "generic"},
+		"5.15.0-1019-aws":          {ABI: "1019", Flavor: "aws"},
+		"5.15.0-1004-intel-iotg":   {ABI: "1004", Flavor: "intel-iotg"},
+		"4.18.0-24-lowlatency-hwe": {ABI: "24", Flavor: "lowlatency-hwe"},
+		"5.4.0-1006-azure-fde-5.4": {ABI: "1006", Flavor: "azure-fde"},
+	}
+	for kernelVersionStr, want := range tests {
+		t.Run(kernelVersionStr, func(t *testing.T) {
+			kr := FromString(kernelVersionStr)
+			assert.DeepEqual(t, want, kr.Ubuntu())
+		})
+	}
+}
+
+func TestDebian(t *testing.T) {
+	tests := map[string]struct {
+		want   DebianRelease
+		wantOk bool
+	}{
+		"6.1.0-13-amd64":       {want: DebianRelease{ABI: "13", Flavor: "amd64"}, wantOk: true},
+		"6.1.0-13-cloud-arm64": {want: DebianRelease{ABI: "13", Flavor: "cloud-arm64"}, wantOk: true},
+		"4.19.0-0.bpo.6-amd64": {want: DebianRelease{ABI: "0.bpo.6", Flavor: "amd64"}, wantOk: true},
+		"6.5.1":                {},
+	}
+	for kernelVersionStr, tt := range tests {
+		t.Run(kernelVersionStr, func(t *testing.T) {
+			kr := FromString(kernelVersionStr)
+			got, ok := kr.Debian()
+			assert.Equal(t, tt.wantOk, ok)
+			assert.DeepEqual(t, tt.want, got)
+		})
+	}
+}
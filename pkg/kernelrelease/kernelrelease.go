@@ -16,8 +16,8 @@ package kernelrelease
 
 import (
 	"fmt"
-	"log"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -29,8 +29,10 @@ var (
 )
 
 const (
-	ArchitectureAmd64 = "amd64"
-	ArchitectureArm64 = "arm64"
+	ArchitectureAmd64   = "amd64"
+	ArchitectureArm64   = "arm64"
+	ArchitectureS390x   = "s390x"
+	ArchitecturePpc64le = "ppc64le"
 )
 
 // Architectures is a Map [Architecture] -> non-deb-ArchitectureString
@@ -38,8 +40,15 @@ type Architectures map[Architecture]string
 
 // SupportedArchs enforces the duality of architecture->non-deb one when adding a new one
 var SupportedArchs = Architectures{
-	ArchitectureAmd64: "x86_64",
-	ArchitectureArm64: "aarch64",
+	ArchitectureAmd64:   "x86_64",
+	ArchitectureArm64:   "aarch64",
+	ArchitectureS390x:   "s390x",
+	ArchitecturePpc64le: "ppc64le",
+}
+
+// debArchs holds the deb names of the architectures that differ from the Go ones.
+var debArchs = map[Architecture]string{
+	ArchitecturePpc64le: "ppc64el",
 }
 
 // Privately cached at startup for quicker access
@@ -49,8 +58,20 @@ var supportedArchsSlice []string
 // is supported, depending on the architecture.
 // See compatibility matrix: https://falco.org/docs/event-sources/drivers/
 var moduleMinKernelVersion = map[Architecture]semver.Version{
-	ArchitectureAmd64: semver.MustParse("3.10.0"),
-	ArchitectureArm64: semver.MustParse("3.16.0"),
+	ArchitectureAmd64:   semver.MustParse("3.10.0"),
+	ArchitectureArm64:   semver.MustParse("3.16.0"),
+	ArchitectureS390x:   semver.MustParse("3.10.0"),
+	ArchitecturePpc64le: semver.MustParse("3.10.0"),
+}
+
+// Represents the minimum kernel version for which building the eBPF probe
+// is supported, depending on the architecture.
+// See compatibility matrix: https://falco.org/docs/event-sources/drivers/
+var probeMinKernelVersion = map[Architecture]semver.Version{
+	ArchitectureAmd64:   semver.MustParse("4.14.0"),
+	ArchitectureArm64:   semver.MustParse("4.17.0"),
+	ArchitectureS390x:   semver.MustParse("5.5.0"),
+	ArchitecturePpc64le: semver.MustParse("4.18.0"),
 }
 
 func init() {
@@ -60,6 +81,7 @@ func init() {
 		supportedArchsSlice[i] = k.String()
 		i++
 	}
+	sort.Strings(supportedArchsSlice)
 }
 
 func (aa Architectures) String() string {
@@ -79,6 +101,14 @@ func (a Architecture) ToNonDeb() string {
 	panic(fmt.Errorf("missing non-deb name for arch: %s", a.String()))
 }
 
+// ToDeb returns the name of the architecture used by deb packages, eg: "ppc64el" for ppc64le.
+func (a Architecture) ToDeb() string {
+	if val, ok := debArchs[a]; ok {
+		return val
+	}
+	return a.String()
+}
+
 func (a Architecture) String() string {
 	return string(a)
 }
@@ -95,12 +125,19 @@ type KernelRelease struct {
 	FullExtraversion string
 	Architecture     Architecture
 	KernelVersion    string
+	// PackageVersion is the distro package version of the kernel,
+	// when it is embedded in the `uname -v` output (see ParseUnameVersion).
+	PackageVersion string
 }
 
-// FromString extracts a KernelRelease object from string.
-func FromString(kernelVersionStr string) KernelRelease {
+// Parse extracts a KernelRelease object from string,
+// returning an error when the string is not a valid kernel release.
+func Parse(kernelVersionStr string) (KernelRelease, error) {
 	kv := KernelRelease{}
 	match := kernelVersionPattern.FindStringSubmatch(kernelVersionStr)
+	if match == nil {
+		return kv, fmt.Errorf("invalid kernel release: %q", kernelVersionStr)
+	}
 	for i, name := range kernelVersionPattern.SubexpNames() {
 		if i > 0 && i <= len(match) {
 			var err error
@@ -126,10 +163,18 @@ func FromString(kernelVersionStr string) KernelRelease {
 			}
 
 			if err != nil {
-				log.Fatal(err)
+				return KernelRelease{}, fmt.Errorf("invalid kernel release %q: %w", kernelVersionStr, err)
 			}
 		}
 	}
+	return kv, nil
+}
+
+// FromString extracts a KernelRelease object from string.
+// An empty KernelRelease is returned when the string cannot be parsed:
+// use Parse to get the error.
+func FromString(kernelVersionStr string) KernelRelease {
+	kv, _ := Parse(kernelVersionStr)
 	return kv
 }
 
@@ -137,6 +182,10 @@ func (k *KernelRelease) SupportsModule() bool {
 	return k.GTE(moduleMinKernelVersion[k.Architecture])
 }
 
+func (k *KernelRelease) SupportsProbe() bool {
+	return k.GTE(probeMinKernelVersion[k.Architecture])
+}
+
 func (k *KernelRelease) String() string {
 	return fmt.Sprintf("%s%s", k.Fullversion, k.FullExtraversion)
 }
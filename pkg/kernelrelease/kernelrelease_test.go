@@ -283,6 +283,22 @@ func TestFromString(t *testing.T) {
 	}
 }
 
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":           "",
+		"no version":      "generic",
+		"leading zero":    "05.4.0",
+		"version too big": "99999999999999999999.1.0",
+	}
+	for name, kernelVersionStr := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(kernelVersionStr)
+			assert.Assert(t, err != nil)
+			assert.DeepEqual(t, KernelRelease{}, got)
+		})
+	}
+}
+
 func TestSupportsModule(t *testing.T) {
 	unsupported := []KernelRelease{
 		{
@@ -344,3 +360,74 @@ func TestSupportsModule(t *testing.T) {
 		}
 	}
 }
+
+func TestSupportsProbe(t *testing.T) {
+	unsupported := []KernelRelease{
+		{
+			Version:      semver.Version{Major: 3, Minor: 10, Patch: 0},
+			Architecture: ArchitectureAmd64,
+		},
+		{
+			Version:      semver.Version{Major: 4, Minor: 13, Patch: 99},
+			Architecture: ArchitectureAmd64,
+		},
+		{
+			Version:      semver.Version{Major: 4, Minor: 14, Patch: 0},
+			Architecture: ArchitectureArm64,
+		},
+		{
+			Version:      semver.Version{Major: 5, Minor: 4, Patch: 0},
+			Architecture: ArchitectureS390x,
+		},
+	}
+	supported := []KernelRelease{
+		{
+			Version:      semver.Version{Major: 4, Minor: 14, Patch: 0},
+			Architecture: ArchitectureAmd64,
+		},
+		{
+			Version:      semver.Version{Major: 5, Minor: 5, Patch: 0},
+			Architecture: ArchitectureS390x,
+		},
+		{
+			Version:      semver.Version{Major: 4, Minor: 18, Patch: 0},
+			Architecture: ArchitecturePpc64le,
+		},
+		{
+			Version:      semver.Version{Major: 4, Minor: 17, Patch: 0},
+			Architecture: ArchitectureArm64,
+		},
+		{
+			Version:      semver.Version{Major: 6, Minor: 1, Patch: 0},
+			Architecture: ArchitectureArm64,
+		},
+	}
+
+	for _, r := range unsupported {
+		if r.SupportsProbe() {
+			t.Errorf("building probe should not be supported in kernel version %s", r.String())
+		}
+	}
+	for _, r := range supported {
+		if !r.SupportsProbe() {
+			t.Errorf("building probe should be supported in kernel version %s", r.String())
+		}
+	}
+}
+
+func TestArchitectureNames(t *testing.T) {
+	tests := map[Architecture]struct {
+		nonDeb string
+		deb    string
+	}{
+		ArchitectureAmd64:   {nonDeb: "x86_64", deb: "amd64"},
+		ArchitectureArm64:   {nonDeb: "aarch64", deb: "arm64"},
+		ArchitectureS390x:   {nonDeb: "s390x", deb: "s390x"},
+		ArchitecturePpc64le: {nonDeb: "ppc64le", deb: "ppc64el"},
+	}
+	for arch, test := range tests {
+		assert.Equal(t, arch.ToNonDeb(), test.nonDeb)
+		assert.Equal(t, arch.ToDeb(), test.deb)
+	}
+	assert.DeepEqual(t, SupportedArchs.Strings(), []string{"amd64", "arm64", "ppc64le", "s390x"})
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelrelease
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	unameBuildPattern         = regexp.MustCompile(`^#(?P<build>\d+)(?P<suffix>~[0-9a-zA-Z.+~]+)?(-Ubuntu)?$`)
+	unameDebianPackagePattern = regexp.MustCompile(`\sDebian\s+(?P<version>[0-9][0-9a-zA-Z.+~:-]*)`)
+)
+
+// UnameVersion holds the info extracted from the `uname -v` output.
+type UnameVersion struct {
+	// Build is the build number after the hash, eg: "1" for "#1 SMP ...".
+	Build string
+	// PackageVersion is the distro package version embedded in the string, if any,
+	// eg: "6.1.55-1" for "#1 SMP PREEMPT_DYNAMIC Debian 6.1.55-1 (2023-09-29)"
+	// or "58~20.04.1" for "#58~20.04.1-Ubuntu SMP ...".
+	PackageVersion string
+}
+
+// ParseUnameVersion extracts the build number and the distro package version
+// from the full `uname -v` output.
+// Strings not starting with a hash are considered to be the sole build number,
+// eg: "58", for backward compatibility.
+func ParseUnameVersion(s string) UnameVersion {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "#") {
+		return UnameVersion{Build: s}
+	}
+
+	fields := strings.Fields(s)
+	match := unameBuildPattern.FindStringSubmatch(fields[0])
+	if match == nil {
+		return UnameVersion{Build: strings.TrimPrefix(fields[0], "#")}
+	}
+
+	uv := UnameVersion{Build: match[unameBuildPattern.SubexpIndex("build")]}
+	if suffix := match[unameBuildPattern.SubexpIndex("suffix")]; suffix != "" {
+		// Ubuntu HWE kernels, eg: "#58~20.04.1-Ubuntu"
+		uv.PackageVersion = uv.Build + suffix
+	}
+	if match := unameDebianPackagePattern.FindStringSubmatch(s); match != nil {
+		uv.PackageVersion = match[unameDebianPackagePattern.SubexpIndex("version")]
+	}
+	return uv
+}
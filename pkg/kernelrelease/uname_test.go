@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelrelease
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestParseUnameVersion(t *testing.T) {
+	tests := map[string]struct {
+		unameVersion string
+		want         UnameVersion
+	}{
+		"build number only": {
+			unameVersion: "58",
+			want:         UnameVersion{Build: "58"},
+		},
+		"ubuntu": {
+			unameVersion: "#58-Ubuntu SMP Thu Oct 13 08:03:55 UTC 2022",
+			want:         UnameVersion{Build: "58"},
+		},
+		"ubuntu hwe": {
+			unameVersion: "#58~20.04.1-Ubuntu SMP Thu Oct 13 13:09:46 UTC 2022",
+			want:         UnameVersion{Build: "58", PackageVersion: "58~20.04.1"},
+		},
+		"debian": {
+			unameVersion: "#1 SMP PREEMPT_DYNAMIC Debian 6.1.55-1 (2023-09-29)",
+			want:         UnameVersion{Build: "1", PackageVersion: "6.1.55-1"},
+		},
+		"debian backports": {
+			unameVersion: "#1 SMP Debian 5.10.70-1~bpo10+1 (2021-10-10)",
+			want:         UnameVersion{Build: "1", PackageVersion: "5.10.70-1~bpo10+1"},
+		},
+		"archlinux": {
+			unameVersion: "#1 SMP PREEMPT_DYNAMIC Sat, 16 Mar 2024 17:15:35 +0000",
+			want:         UnameVersion{Build: "1"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.DeepEqual(t, tt.want, ParseUnameVersion(tt.unameVersion))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validate
+
+import (
+	"github.com/go-playground/validator/v10"
+
+	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+)
+
+func isKernelRelease(fl validator.FieldLevel) bool {
+	_, err := kernelrelease.Parse(fl.Field().String())
+	return err == nil
+}
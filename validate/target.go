@@ -27,6 +27,10 @@ func isTargetSupported(fl validator.FieldLevel) bool {
 
 	switch field.Kind() {
 	case reflect.String:
+		// auto gets resolved to a real target once the kernel release is known
+		if builder.Type(field.String()) == builder.TargetTypeAuto {
+			return true
+		}
 		_, err := builder.Factory(builder.Type(field.String()))
 		return err == nil
 	}
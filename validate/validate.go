@@ -56,6 +56,9 @@ func init() {
 	V.RegisterValidation("semvertolerant", isSemVerTolerant)
 	V.RegisterValidation("proxy", isProxy)
 	V.RegisterValidation("imagename", isImageName)
+	V.RegisterValidation("imagemirror", isImageMirror)
+	V.RegisterValidation("gccrule", isGCCRule)
+	V.RegisterValidation("kernelrelease", isKernelRelease)
 
 	eng := en.New()
 	uni := ut.New(eng, eng)
@@ -114,6 +117,45 @@ func init() {
 		},
 	)
 
+	V.RegisterTranslation(
+		"imagemirror",
+		T,
+		func(ut ut.Translator) error {
+			return ut.Add("imagemirror", "{0} must be a valid image mirror, as <from>=<to>", true)
+		},
+		func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(fe.Tag(), fe.Field())
+
+			return t
+		},
+	)
+
+	V.RegisterTranslation(
+		"gccrule",
+		T,
+		func(ut ut.Translator) error {
+			return ut.Add("gccrule", "{0} must be a valid gcc rule, as [<target>:]<kernel range>=<gcc>", true)
+		},
+		func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(fe.Tag(), fe.Field())
+
+			return t
+		},
+	)
+
+	V.RegisterTranslation(
+		"kernelrelease",
+		T,
+		func(ut ut.Translator) error {
+			return ut.Add("kernelrelease", "{0} must be a valid kernel release, eg: 6.1.0-13-amd64", true)
+		},
+		func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(fe.Tag(), fe.Field())
+
+			return t
+		},
+	)
+
 	V.RegisterTranslation(
 		"required_kernelconfigdata_with_target_vanilla",
 		T,
@@ -121,7 +163,7 @@ func init() {
 			return ut.Add("required_kernelconfigdata_with_target_vanilla", "{0} is a required field when target is vanilla/minikube/flatcar", true)
 		},
 		func(ut ut.Translator, fe validator.FieldError) string {
-			t, _ := ut.T("required_kernelconfigdata_with_target_vanilla", "kernel config data") // fixme ? tag "name" does not work when used at struct level
+			t, _ := ut.T("required_kernelconfigdata_with_target_vanilla", "kernel config data or file") // fixme ? tag "name" does not work when used at struct level
 
 			return t
 		},